@@ -0,0 +1,383 @@
+// Package attachment downloads the documents attached to notices of the portal.
+// Files are stored under a content-addressed directory, so the same document linked from several notices
+// is stored once, and a manifest maps notices to their files.
+package attachment
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+const (
+	// DefaultMaxSize is the largest attachment downloaded when zero is given.
+	DefaultMaxSize   = 100 << 20
+	manifestFileName = "manifest.json"
+	objectsDirName   = "objects"
+	sniffLen         = 512
+)
+
+// Doer sends HTTP requests. *http.Client and *kitwalk.ReloginClient satisfy it.
+type Doer interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// File is a downloaded attachment.
+type File struct {
+	URL string `json:"url"`
+	// Path is the path of the content relative to the directory of Downloader.
+	Path        string `json:"path"`
+	SHA256      string `json:"sha256"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	// ETag and LastModified are sent to skip unchanged files.
+	ETag         string    `json:"etag,omitempty"`
+	LastModified string    `json:"last_modified,omitempty"`
+	FetchedAt    time.Time `json:"fetched_at"`
+	// TextPath is the path of the plain text extracted from the content. It is empty when not extracted.
+	TextPath string `json:"text_path,omitempty"`
+}
+
+// Manifest maps notices to their attachments.
+type Manifest struct {
+	// Notices maps Notice.ID to the urls of its attachments.
+	Notices map[string][]string `json:"notices"`
+	// Files maps the url to the downloaded file.
+	Files map[string]*File `json:"files"`
+}
+
+// Result is the result of downloading an attachment.
+type Result struct {
+	NoticeID string
+	URL      string
+	File     *File
+	// Changed is true when the content is new or differs from the last download.
+	Changed bool
+	Err     error
+}
+
+// Downloader downloads attachments into Dir.
+type Downloader struct {
+	Client Doer
+	Dir    string
+	// ExtractText extracts plain text from PDF files for search.
+	ExtractText bool
+	// MaxSize is the largest attachment to download.
+	MaxSize int64
+
+	mu sync.Mutex
+}
+
+// NewDownloader create new downloader which stores files into given directory with the logged-in client.
+func NewDownloader(client Doer, dir string) *Downloader {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Downloader{Client: client, Dir: dir, MaxSize: DefaultMaxSize}
+}
+
+// Manifest reads the manifest. It is empty before the first download.
+func (d *Downloader) Manifest() (*Manifest, error) {
+	m := &Manifest{Notices: make(map[string][]string), Files: make(map[string]*File)}
+	b, err := ioutil.ReadFile(filepath.Join(d.Dir, manifestFileName))
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (d *Downloader) saveManifest(m *Manifest) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(d.Dir, manifestFileName), b)
+}
+
+// Path returns the absolute path of the relative path in File.
+func (d *Downloader) Path(rel string) string {
+	return filepath.Join(d.Dir, filepath.FromSlash(rel))
+}
+
+// Download fetches the attachments of the notices, and updates the manifest.
+// A failure of an attachment is reported in its Result and does not stop the others.
+// The returned error is about the manifest or the context.
+func (d *Downloader) Download(ctx context.Context, notices []portal.Notice) ([]Result, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	m, err := d.Manifest()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		results []Result
+		fetched = make(map[string]Result)
+	)
+	for _, n := range notices {
+		if len(n.Attachments) == 0 {
+			continue
+		}
+		id := n.ID()
+		m.Notices[id] = append([]string(nil), n.Attachments...)
+		for _, u := range n.Attachments {
+			if err := ctx.Err(); err != nil {
+				return results, err
+			}
+			r, ok := fetched[u]
+			if !ok {
+				r = Result{URL: u}
+				r.File, r.Changed, r.Err = d.download(ctx, u, m.Files[u])
+				if r.Err == nil {
+					m.Files[u] = r.File
+				}
+				fetched[u] = r
+			}
+			r.NoticeID = id
+			results = append(results, r)
+		}
+	}
+	if err := d.saveManifest(m); err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+func (d *Downloader) download(ctx context.Context, rawurl string, prev *File) (*File, bool, error) {
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	req = req.WithContext(ctx)
+	if prev != nil {
+		if prev.ETag != "" {
+			req.Header.Set("If-None-Match", prev.ETag)
+		}
+		if prev.LastModified != "" {
+			req.Header.Set("If-Modified-Since", prev.LastModified)
+		}
+	}
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return nil, false, err
+	}
+	defer resp.Body.Close()
+	if resp.Request != nil && resp.Request.URL.Host == kitwalk.DefaultAuthDomain {
+		return nil, false, &kitwalk.SessionExpiredError{URL: rawurl}
+	}
+	if resp.StatusCode == http.StatusNotModified && prev != nil {
+		return prev, false, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, false, &StatusError{URL: rawurl, StatusCode: resp.StatusCode}
+	}
+
+	f, err := d.store(rawurl, resp)
+	if err != nil {
+		return nil, false, err
+	}
+	if d.ExtractText && f.ContentType == "application/pdf" {
+		// Text is optional, so a broken PDF is still stored.
+		f.TextPath, _ = d.extract(f)
+	}
+	changed := prev == nil || prev.SHA256 != f.SHA256
+	return f, changed, nil
+}
+
+// store writes the body into the content-addressed directory.
+func (d *Downloader) store(rawurl string, resp *http.Response) (*File, error) {
+	maxSize := d.MaxSize
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	objects := filepath.Join(d.Dir, objectsDirName)
+	if err := os.MkdirAll(objects, 0700); err != nil {
+		return nil, err
+	}
+	tmp, err := ioutil.TempFile(objects, "download")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	h := sha256.New()
+	head := &prefixWriter{n: sniffLen}
+	size, err := io.Copy(io.MultiWriter(tmp, h, head), io.LimitReader(resp.Body, maxSize+1))
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if size > maxSize {
+		return nil, &TooLargeError{URL: rawurl, MaxSize: maxSize}
+	}
+
+	sum := hex.EncodeToString(h.Sum(nil))
+	contentType := DetectType(resp.Header.Get("Content-Type"), head.b, rawurl)
+	rel := path.Join(objectsDirName, sum[:2], sum+extension(contentType, rawurl))
+	dst := d.Path(rel)
+	if _, err := os.Stat(dst); os.IsNotExist(err) {
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return nil, err
+		}
+		if err := os.Rename(tmp.Name(), dst); err != nil {
+			return nil, err
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return &File{
+		URL:          rawurl,
+		Path:         rel,
+		SHA256:       sum,
+		ContentType:  contentType,
+		Size:         size,
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+		FetchedAt:    time.Now(),
+	}, nil
+}
+
+// extract writes the text of the PDF next to it, and returns the relative path of the text.
+func (d *Downloader) extract(f *File) (string, error) {
+	rel := strings.TrimSuffix(f.Path, path.Ext(f.Path)) + ".txt"
+	if _, err := os.Stat(d.Path(rel)); err == nil {
+		return rel, nil
+	}
+	text, err := PDFText(d.Path(f.Path))
+	if err != nil {
+		return "", err
+	}
+	if err := writeFileAtomic(d.Path(rel), []byte(text)); err != nil {
+		return "", err
+	}
+	return rel, nil
+}
+
+// prefixWriter keeps the first n bytes written.
+type prefixWriter struct {
+	n int
+	b []byte
+}
+
+func (w *prefixWriter) Write(p []byte) (int, error) {
+	if rest := w.n - len(w.b); rest > 0 {
+		if len(p) < rest {
+			rest = len(p)
+		}
+		w.b = append(w.b, p[:rest]...)
+	}
+	return len(p), nil
+}
+
+// officeTypes are the types of Office Open XML documents, which are detected as zip from the content.
+var officeTypes = map[string]string{
+	".docx": "application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	".xlsx": "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	".pptx": "application/vnd.openxmlformats-officedocument.presentationml.presentation",
+}
+
+// extensions are the file extensions of common types of attachments.
+var extensions = map[string]string{
+	"application/pdf":               ".pdf",
+	"application/zip":               ".zip",
+	"application/msword":            ".doc",
+	"application/vnd.ms-excel":      ".xls",
+	"application/vnd.ms-powerpoint": ".ppt",
+	"image/png":                     ".png",
+	"image/jpeg":                    ".jpg",
+	"image/gif":                     ".gif",
+	"text/plain":                    ".txt",
+	"text/html":                     ".html",
+	"text/csv":                      ".csv",
+}
+
+// DetectType returns the media type of the content from its first bytes, the declared Content-Type and the url.
+// The content wins over the declaration, because servers often send application/octet-stream for documents.
+func DetectType(declared string, head []byte, rawurl string) string {
+	ext := urlExt(rawurl)
+	sniffed, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	switch sniffed {
+	case "application/zip":
+		if t, ok := officeTypes[ext]; ok {
+			return t
+		}
+		return sniffed
+	case "application/octet-stream", "text/plain":
+		// Not conclusive.
+	default:
+		return sniffed
+	}
+	if t, _, err := mime.ParseMediaType(declared); err == nil && t != "application/octet-stream" {
+		return t
+	}
+	if t, _, err := mime.ParseMediaType(mime.TypeByExtension(ext)); err == nil {
+		return t
+	}
+	return sniffed
+}
+
+func extension(contentType, rawurl string) string {
+	if ext, ok := extensions[contentType]; ok {
+		return ext
+	}
+	for ext, t := range officeTypes {
+		if t == contentType {
+			return ext
+		}
+	}
+	return urlExt(rawurl)
+}
+
+func urlExt(rawurl string) string {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	for _, r := range strings.TrimPrefix(ext, ".") {
+		if !('a' <= r && r <= 'z' || '0' <= r && r <= '9') {
+			return ""
+		}
+	}
+	return ext
+}
+
+func writeFileAtomic(name string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(name), filepath.Base(name))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), name)
+}
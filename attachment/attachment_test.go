@@ -0,0 +1,235 @@
+package attachment
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// minimalPDF builds a one-page PDF showing given text.
+func minimalPDF(text string) []byte {
+	stream := fmt.Sprintf("BT /F1 12 Tf 72 720 Td (%s) Tj ET", text)
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R /Resources << /Font << /F1 5 0 R >> >> >>",
+		fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(stream), stream),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = b.Len()
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return b.Bytes()
+}
+
+func minimalDocx(t *testing.T) []byte {
+	var b bytes.Buffer
+	w := zip.NewWriter(&b)
+	f, err := w.Create("[Content_Types].xml")
+	check(t, err)
+	f.Write([]byte("<Types/>"))
+	check(t, w.Close())
+	return b.Bytes()
+}
+
+// fileServer serves attachments with ETag, and counts full responses.
+type fileServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	files map[string][]byte
+	sent  map[string]int
+}
+
+func newFileServer(files map[string][]byte) *fileServer {
+	s := &fileServer{files: files, sent: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		b, ok := s.files[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		etag := fmt.Sprintf(`"%x"`, len(b))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		s.sent[r.URL.Path]++
+		w.Header().Set("ETag", etag)
+		// The portal sends documents as octet-stream.
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(b)
+	}))
+	return s
+}
+
+func TestDownloader_Download(t *testing.T) {
+	t.Parallel()
+	server := newFileServer(map[string][]byte{
+		"/files/a.pdf":     minimalPDF("Scholarship application"),
+		"/files/copy.pdf":  minimalPDF("Scholarship application"),
+		"/files/form.docx": minimalDocx(t),
+		"/files/notes.txt": []byte("plain notes"),
+	})
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "attachment")
+	check(t, err)
+	defer os.RemoveAll(dir)
+
+	d := NewDownloader(server.Client(), dir)
+	d.ExtractText = true
+	notices := []portal.Notice{
+		{Title: "奨学金", Attachments: []string{server.URL + "/files/a.pdf", server.URL + "/files/form.docx"}},
+		{Title: "再掲", Attachments: []string{server.URL + "/files/copy.pdf", server.URL + "/files/a.pdf"}},
+		{Title: "メモ", Attachments: []string{server.URL + "/files/notes.txt", server.URL + "/files/missing.pdf"}},
+		{Title: "添付なし"},
+	}
+	ctx := context.Background()
+
+	t.Run("Download and detect types", func(t *testing.T) {
+		results, err := d.Download(ctx, notices)
+		check(t, err)
+		if len(results) != 6 {
+			t.Fatalf("Expect: 6 results\nActual: %d results\n", len(results))
+		}
+		expect := []string{
+			"application/pdf",
+			"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+			"application/pdf",
+			"application/pdf",
+			"text/plain",
+		}
+		for i, typ := range expect {
+			r := results[i]
+			if r.Err != nil {
+				t.Fatalf("Unexpected error for %s: %s", r.URL, r.Err)
+			}
+			if r.File.ContentType != typ || !r.Changed {
+				t.Errorf("Expect: %s, changed\nActual: %s, %v\n", typ, r.File.ContentType, r.Changed)
+			}
+		}
+		switch err := results[5].Err.(type) {
+		case *StatusError:
+			if err.StatusCode != http.StatusNotFound {
+				t.Errorf("Expect: 404\nActual: %d\n", err.StatusCode)
+			}
+		default:
+			t.Errorf("Expect: StatusError\nActual: %v\n", err)
+		}
+		// The same content is stored once.
+		if results[0].File.Path != results[2].File.Path || !strings.HasSuffix(results[0].File.Path, ".pdf") {
+			t.Errorf("Expect: deduplicated pdf\nActual: %s, %s\n", results[0].File.Path, results[2].File.Path)
+		}
+		if !strings.HasSuffix(results[1].File.Path, ".docx") {
+			t.Errorf("Expect: .docx\nActual: %s\n", results[1].File.Path)
+		}
+		if server.sent["/files/a.pdf"] != 1 {
+			t.Errorf("Expect: a.pdf fetched once\nActual: %d times\n", server.sent["/files/a.pdf"])
+		}
+		text, err := ioutil.ReadFile(d.Path(results[0].File.TextPath))
+		check(t, err)
+		if !strings.Contains(string(text), "Scholarship application") {
+			t.Errorf("Unexpected text %q", text)
+		}
+		objects, err := filepath.Glob(filepath.Join(dir, objectsDirName, "*", "*"))
+		check(t, err)
+		// pdf, txt of the pdf, docx and txt.
+		if len(objects) != 4 {
+			t.Errorf("Expect: 4 objects\nActual: %v\n", objects)
+		}
+	})
+	t.Run("Skip unchanged files", func(t *testing.T) {
+		results, err := d.Download(ctx, notices[:1])
+		check(t, err)
+		for _, r := range results {
+			if r.Err != nil || r.Changed {
+				t.Errorf("Expect: unchanged\nActual: %+v\n", r)
+			}
+		}
+		if server.sent["/files/a.pdf"] != 1 {
+			t.Errorf("Expect: 304 for a.pdf\nActual: fetched %d times\n", server.sent["/files/a.pdf"])
+		}
+	})
+	t.Run("Write manifest", func(t *testing.T) {
+		m, err := d.Manifest()
+		check(t, err)
+		id := notices[1].ID()
+		if len(m.Notices[id]) != 2 || m.Notices[id][0] != server.URL+"/files/copy.pdf" {
+			t.Errorf("Unexpected attachments of notice %v", m.Notices[id])
+		}
+		if f := m.Files[server.URL+"/files/copy.pdf"]; f == nil || f.ETag == "" || f.TextPath == "" {
+			t.Errorf("Unexpected file %+v", f)
+		}
+		if _, ok := m.Files[server.URL+"/files/missing.pdf"]; ok {
+			t.Error("Expect: failed download is not in the manifest")
+		}
+	})
+}
+
+func TestDownloader_MaxSize(t *testing.T) {
+	t.Parallel()
+	server := newFileServer(map[string][]byte{"/big.pdf": minimalPDF("large")})
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "attachment")
+	check(t, err)
+	defer os.RemoveAll(dir)
+
+	d := NewDownloader(server.Client(), dir)
+	d.MaxSize = 16
+	results, err := d.Download(context.Background(), []portal.Notice{{Attachments: []string{server.URL + "/big.pdf"}}})
+	check(t, err)
+	if _, ok := results[0].Err.(*TooLargeError); !ok {
+		t.Errorf("Expect: TooLargeError\nActual: %v\n", results[0].Err)
+	}
+}
+
+func TestDetectType(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		declared string
+		head     []byte
+		url      string
+		expect   string
+	}{
+		{"application/octet-stream", []byte("%PDF-1.4"), "https://example.com/download?id=1", "application/pdf"},
+		{"text/html", []byte("%PDF-1.4"), "https://example.com/a.pdf", "application/pdf"},
+		{"", []byte("PK\x03\x04"), "https://example.com/a.xlsx", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+		{"", []byte("PK\x03\x04"), "https://example.com/a.zip", "application/zip"},
+		{"text/csv; charset=Shift_JIS", []byte("a,b\n"), "https://example.com/list", "text/csv"},
+		{"application/octet-stream", []byte{0xd0, 0xcf, 0x11, 0xe0}, "https://example.com/a.doc", "application/msword"},
+	}
+	for _, c := range cases {
+		if actual := DetectType(c.declared, c.head, c.url); actual != c.expect {
+			t.Errorf("Expect: %s\nActual: %s\n", c.expect, actual)
+		}
+	}
+}
@@ -0,0 +1,23 @@
+package attachment
+
+import "fmt"
+
+// StatusError will be return when the server responds with unexpected status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Unexpected status code %d from '%s'.", e.StatusCode, e.URL)
+}
+
+// TooLargeError will be return when the attachment exceeds Downloader.MaxSize.
+type TooLargeError struct {
+	URL     string
+	MaxSize int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("The attachment '%s' is larger than %d bytes.", e.URL, e.MaxSize)
+}
@@ -0,0 +1,42 @@
+package attachment
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/ledongthuc/pdf"
+)
+
+// PDFText extracts the plain text of the PDF file.
+// Scanned documents without text layer give an empty string.
+func PDFText(name string) (text string, err error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return "", err
+	}
+	// The parser panics on some malformed documents.
+	defer func() {
+		if r := recover(); r != nil {
+			text, err = "", fmt.Errorf("Failed to parse PDF '%s': %v", name, r)
+		}
+	}()
+	r, err := pdf.NewReader(f, info.Size())
+	if err != nil {
+		return "", err
+	}
+	plain, err := r.GetPlainText()
+	if err != nil {
+		return "", err
+	}
+	b, err := ioutil.ReadAll(plain)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
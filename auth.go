@@ -3,8 +3,9 @@ package kitwalk
 import (
 	"context"
 	"net/http"
-	"net/http/cookiejar"
 	"strings"
+	"sync"
+	"time"
 )
 
 const (
@@ -29,7 +30,14 @@ type User struct {
 type SamlAuthenticator struct {
 	User   *User
 	Config Config
-	ctx    context.Context
+	// Limiter protects the account from being locked. NewAuthenticator sets DefaultLoginLimiter.
+	// Logins are not limited when it is nil.
+	Limiter *LoginLimiter
+	ctx     context.Context
+
+	mu           sync.Mutex
+	cookies      []*http.Cookie
+	notOnOrAfter time.Time
 }
 
 func (c *SamlAuthenticator) auth(client *http.Client, resp *http.Response) error {
@@ -54,6 +62,12 @@ func (c *SamlAuthenticator) auth(client *http.Client, resp *http.Response) error
 	}
 
 	// Post auth info to auth page
+	limiter := c.Limiter
+	if limiter != nil {
+		if err := limiter.Wait(c.ctx, c.User.Username); err != nil {
+			return err
+		}
+	}
 	param := strings.NewReader(c.Config.ShibbolethHiddenParams.Encode())
 	authReq, err := http.NewRequest(http.MethodPost, tmpResp.Request.URL.String(), param)
 	if err != nil {
@@ -68,9 +82,30 @@ func (c *SamlAuthenticator) auth(client *http.Client, resp *http.Response) error
 	defer authResp.Body.Close()
 	// Extract SAML response
 	actionURL, data, err := parseSamlResp(authResp.Body)
-	if err != nil {
+	switch e := err.(type) {
+	case nil:
+		if limiter != nil {
+			limiter.report(limiter.Success(c.User.Username))
+		}
+	case *AccountLockedError:
+		e.username = c.User.Username
+		if limiter != nil {
+			limiter.report(limiter.Locked(c.User.Username))
+		}
+		return e
+	case *ShibbolethAuthError:
+		e.username = c.User.Username
+		// A page the parser does not understand is not a wrong password, and must not lock the user out.
+		if limiter != nil && e.rejected {
+			limiter.report(limiter.Failure(c.User.Username))
+		}
+		return e
+	default:
 		return err
 	}
+	c.mu.Lock()
+	c.notOnOrAfter = sessionNotOnOrAfter(data.Get(DefaultSAMLResponseKey))
+	c.mu.Unlock()
 	// Redirect to target resource, and respond with target resource.
 	param = strings.NewReader(data.Encode())
 	authResReq, err := http.NewRequest(http.MethodPost, actionURL, param)
@@ -97,29 +132,80 @@ func (c *SamlAuthenticator) LoginWith(client *http.Client) error {
 		client = http.DefaultClient
 	}
 	if client.Jar == nil {
-		jar, err := cookiejar.New(nil)
-		if err != nil {
-			return err
-		}
-		client.Jar = jar
+		client.Jar = NewJar()
 	}
+	// Record the cookies obtained during login, because the jar cannot enumerate them.
+	recorder := &recordingJar{CookieJar: client.Jar}
+	defer c.track(recorder)
+	loginClient := *client
+	loginClient.Jar = recorder
 	getReq, err := http.NewRequest(http.MethodGet, ShibbolethLoginURL, nil)
 	if err != nil {
 		return err
 	}
 	getReq = getReq.WithContext(c.ctx)
-	resp, err := client.Do(getReq)
+	resp, err := loginClient.Do(getReq)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 	if resp.Request.URL.Host == c.Config.ShibbolethAuthDomain {
-		err = c.auth(client, resp)
+		err = c.auth(&loginClient, resp)
 		return err
 	}
 	return nil
 }
 
+func (c *SamlAuthenticator) track(recorder *recordingJar) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, cookie := range recorder.recorded() {
+		c.cookies = mergeCookie(c.cookies, cookie)
+	}
+}
+
+// Cookies returns the cookies obtained during login with their domain, path and expiry.
+// The domain starts with '.' for the cookies shared with subdomains.
+func (c *SamlAuthenticator) Cookies() []*http.Cookie {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cookies := make([]*http.Cookie, 0, len(c.cookies))
+	for _, cookie := range c.cookies {
+		if cookie.Expires.IsZero() || cookie.Expires.After(time.Now()) {
+			copied := *cookie
+			cookies = append(cookies, &copied)
+		}
+	}
+	return cookies
+}
+
+// SessionNotOnOrAfter returns the time until which the auth server allows the website to keep the session.
+// It is zero when the assertion of the last login is encrypted or does not have it.
+func (c *SamlAuthenticator) SessionNotOnOrAfter() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.notOnOrAfter
+}
+
+// Session returns the session of the user with the cookies obtained during login.
+// The cookies for the website in given jar are also included, since they may be set after login.
+func (c *SamlAuthenticator) Session(jar http.CookieJar) *Session {
+	s := &Session{
+		Username:     c.User.Username,
+		LoggedInAt:   time.Now(),
+		NotOnOrAfter: c.SessionNotOnOrAfter(),
+		Cookies:      c.Cookies(),
+	}
+	if jar != nil {
+		for _, cookie := range CaptureSession(c.User.Username, jar).Cookies {
+			if !containsCookie(s.Cookies, cookie) {
+				s.Cookies = append(s.Cookies, cookie)
+			}
+		}
+	}
+	return s
+}
+
 // SetupWith attach given configuration to authenticator.
 func (c *SamlAuthenticator) SetupWith(config Config) error {
 	// Set auth info
@@ -163,9 +249,10 @@ func NewAuthenticator(ctx context.Context, username string, password string) (Au
 	user := &User{Username: username, Password: password}
 	defaultConfig := GetDefaultConfig()
 	authenticator := &SamlAuthenticator{
-		User:   user,
-		Config: *defaultConfig,
-		ctx:    ctx,
+		User:    user,
+		Config:  *defaultConfig,
+		Limiter: DefaultLoginLimiter,
+		ctx:     ctx,
 	}
 	err := authenticator.SetupWith(*defaultConfig)
 	if err != nil {
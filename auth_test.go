@@ -27,6 +27,16 @@ func check(t *testing.T, err error) {
 	}
 }
 
+// newTestAuthenticator opts out of DefaultLoginLimiter, since tests log in many times as the same user.
+func newTestAuthenticator(ctx context.Context, username string, password string) (Auth, error) {
+	auth, err := NewAuthenticator(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	auth.(*SamlAuthenticator).Limiter = nil
+	return auth, nil
+}
+
 func TestNewAuthenticator(t *testing.T) {
 	t.Run("Get new authenticator", func(t *testing.T) {
 		ctx := context.Background()
@@ -158,7 +168,7 @@ func TestSamlAuthenticator_LoginWith(t *testing.T) {
 	)
 	t.Run("Login with valid username and password", func(t *testing.T) {
 		ctx := context.Background()
-		authenticator, err := NewAuthenticator(ctx, validUsername, validPasswd)
+		authenticator, err := newTestAuthenticator(ctx, validUsername, validPasswd)
 		check(t, err)
 		client := &http.Client{
 			Transport: &samlMock{Authenticated: false},
@@ -177,7 +187,7 @@ func TestSamlAuthenticator_LoginWith(t *testing.T) {
 	})
 	t.Run("Login with invalid password", func(t *testing.T) {
 		ctx := context.Background()
-		authenticator, err := NewAuthenticator(ctx, validUsername, invalidPasswd)
+		authenticator, err := newTestAuthenticator(ctx, validUsername, invalidPasswd)
 		check(t, err)
 		client := &http.Client{
 			Transport: &samlMock{Authenticated: false},
@@ -192,7 +202,7 @@ func TestSamlAuthenticator_LoginWith(t *testing.T) {
 	})
 	t.Run("Login with nil client", func(t *testing.T) {
 		ctx := context.Background()
-		authenticator, err := NewAuthenticator(ctx, validUsername, validPasswd)
+		authenticator, err := newTestAuthenticator(ctx, validUsername, validPasswd)
 		check(t, err)
 		client := http.DefaultClient
 		client.Transport = &samlMock{Authenticated: false}
@@ -210,7 +220,7 @@ func TestSamlAuthenticator_LoginWith(t *testing.T) {
 	})
 	t.Run("Skip WebStorage Confirmation", func(t *testing.T) {
 		ctx := context.Background()
-		authenticator, err := NewAuthenticator(ctx, validUsername, validPasswd)
+		authenticator, err := newTestAuthenticator(ctx, validUsername, validPasswd)
 		check(t, err)
 		client := &http.Client{
 			Transport: &samlMock{
@@ -227,7 +237,7 @@ func TestSamlAuthenticator_LoginAs(t *testing.T) {
 	t.Parallel()
 	t.Run("Login as valid user", func(t *testing.T) {
 		ctx := context.Background()
-		authenticator, err := NewAuthenticator(ctx, validUsername, validPasswd)
+		authenticator, err := newTestAuthenticator(ctx, validUsername, validPasswd)
 		check(t, err)
 		if err := authenticator.LoginAs(validUsername, validPasswd); err != nil {
 			t.Error(err)
@@ -235,7 +245,7 @@ func TestSamlAuthenticator_LoginAs(t *testing.T) {
 	})
 	t.Run("Login as invalid user", func(t *testing.T) {
 		ctx := context.Background()
-		authenticator, err := NewAuthenticator(ctx, validUsername, invalidPasswd)
+		authenticator, err := newTestAuthenticator(ctx, validUsername, invalidPasswd)
 		check(t, err)
 		if err := authenticator.LoginAs(invalidUsername, validPasswd); err == nil {
 			t.Error("Expect: InvalidUsernameError\nActual: (nil)")
@@ -247,7 +257,7 @@ func TestSamlAuthenticator_SetupWith(t *testing.T) {
 	t.Parallel()
 	t.Run("Setup with nil config", func(t *testing.T) {
 		ctx := context.Background()
-		authenticator, err := NewAuthenticator(ctx, validUsername, validPasswd)
+		authenticator, err := newTestAuthenticator(ctx, validUsername, validPasswd)
 		check(t, err)
 		testConf := Config{
 			ShibbolethPasswordKey: DefaultPasswdKey,
@@ -0,0 +1,266 @@
+package classchange
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/ical"
+	"github.com/StudioAquatan/kitwalk/internal/text"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+// Summary prefixes of the events changed by class changes.
+const (
+	CancelledPrefix  = "【休講】"
+	MakeUpPrefix     = "【補講】"
+	RoomChangePrefix = "【教室変更】"
+)
+
+// Merge returns the changes of the courses registered in the timetable.
+// The timetable code, the instructor, the period and the room missing in the notices are complemented from the timetable.
+func Merge(tt *portal.Timetable, changes []Change) []Change {
+	var merged []Change
+	for _, c := range changes {
+		course, ok := findCourse(tt, c)
+		if !ok {
+			continue
+		}
+		c.Code = course.Code
+		if c.Instructor == "" {
+			c.Instructor = course.Instructor
+		}
+		if !c.Date.IsZero() && c.Period == 0 && c.Date.Weekday() == course.Day {
+			c.Period, c.Length = course.Period, course.Length
+		}
+		if c.Room == "" && c.Kind != MakeUp {
+			c.Room = course.Room
+		}
+		if c.Kind == MakeUp && c.NewRoom == "" {
+			c.NewRoom = course.Room
+		}
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// findCourse finds the course of the change by the code or the title.
+// When the title matches courses on several days, the one on the day of the change is chosen.
+func findCourse(tt *portal.Timetable, c Change) (portal.Course, bool) {
+	if tt == nil {
+		return portal.Course{}, false
+	}
+	var candidates []portal.Course
+	for _, course := range tt.Courses {
+		switch {
+		case c.Code != "" && course.Code == c.Code:
+			candidates = append(candidates, course)
+		case c.Code == "" && text.Normalize(course.Title) == text.Normalize(c.Course):
+			candidates = append(candidates, course)
+		}
+	}
+	for _, course := range candidates {
+		if course.Scheduled() && !c.Date.IsZero() && course.Day == c.Date.Weekday() &&
+			(c.Period == 0 || course.Period == c.Period) {
+			return course, true
+		}
+	}
+	if len(candidates) == 0 {
+		return portal.Course{}, false
+	}
+	return candidates[0], true
+}
+
+// Events returns the events of the timetable with the changes applied.
+// A cancelled class and a class in another room override the occurrence of the weekly event,
+// so that calendar clients show them in place. A make-up class is added as a separate event.
+// Changes of courses not found in the timetable are added as separate events. tt may be nil.
+func Events(tt *portal.Timetable, cal ical.AcademicCalendar, changes []Change) ([]ical.Event, error) {
+	var events []ical.Event
+	periods := portal.DefaultPeriods
+	year := 0
+	if tt != nil {
+		var err error
+		if events, err = ical.TimetableEvents(tt, cal); err != nil {
+			return nil, err
+		}
+		periods, year = tt.Periods, tt.Year
+	}
+	overrides := make(map[string]int)
+	for _, c := range changes {
+		course, found := findCourse(tt, c)
+		base := -1
+		if found && course.Scheduled() {
+			uid := ical.CourseUID(year, course)
+			for i := range events {
+				if events[i].UID == uid && events[i].RecurrenceID.IsZero() {
+					base = i
+				}
+			}
+		}
+		e := ical.Event{
+			UID:         fmt.Sprintf("change-%s@%s", c.NoticeID, ical.UIDDomain),
+			Description: description(c),
+		}
+		switch c.Kind {
+		case Cancelled, RoomChange:
+			if c.Kind == RoomChange && c.Date.IsZero() {
+				// The room is changed from the notice on.
+				if base >= 0 {
+					events = changeRoom(events, base, c, overrides)
+				}
+				continue
+			}
+			period, length := c.Period, c.Length
+			if period == 0 && found && course.Day == c.Date.Weekday() {
+				period, length = course.Period, course.Length
+			}
+			start, end, ok := span(periods, c.Date, period, length)
+			if !ok {
+				continue
+			}
+			e.Start, e.End, e.AllDay = start, end, period == 0
+			e.Location = c.Room
+			e.Summary = CancelledPrefix + c.Course
+			e.Status = ical.StatusCancelled
+			if c.Kind == RoomChange {
+				e.Location = c.NewRoom
+				e.Summary = RoomChangePrefix + c.Course
+				e.Status = ical.StatusConfirmed
+			}
+			if base >= 0 && c.Date.Weekday() == course.Day && period == course.Period {
+				orig := events[base]
+				e.UID, e.RecurrenceID = orig.UID, start
+				if e.Location == "" {
+					e.Location = orig.Location
+				}
+				// A later notice about the same class wins.
+				key := e.UID + e.RecurrenceID.String()
+				if i, ok := overrides[key]; ok {
+					events[i] = e
+					continue
+				}
+				overrides[key] = len(events)
+			}
+		case MakeUp:
+			start, end, ok := span(periods, c.NewDate, c.NewPeriod, c.NewLength)
+			if !ok {
+				continue
+			}
+			e.Start, e.End, e.AllDay = start, end, c.NewPeriod == 0
+			e.Location = c.NewRoom
+			e.Summary = MakeUpPrefix + c.Course
+			e.Status = ical.StatusConfirmed
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// changeRoom moves the occurrences of the weekly event at base to the new room from the notice date onward.
+// The whole series is moved when the notice is posted before it starts or the date is unknown,
+// and the later occurrences are overridden otherwise.
+func changeRoom(events []ical.Event, base int, c Change, overrides map[string]int) []ical.Event {
+	orig := events[base]
+	from := c.NoticeDate
+	if from.IsZero() || !orig.Start.Before(date(from)) {
+		events[base].Location = c.NewRoom
+		return events
+	}
+	if orig.Rule == nil {
+		return events
+	}
+	interval := orig.Rule.Interval
+	if interval < 1 {
+		interval = 1
+	}
+	excluded := make(map[time.Time]bool)
+	for _, t := range orig.ExDates {
+		excluded[t.UTC()] = true
+	}
+	duration := orig.End.Sub(orig.Start)
+	for t := orig.Start; !t.After(orig.Rule.Until); t = t.AddDate(0, 0, 7*interval) {
+		if t.Before(date(from)) || excluded[t.UTC()] {
+			continue
+		}
+		key := orig.UID + t.String()
+		if i, ok := overrides[key]; ok {
+			events[i].Location = c.NewRoom
+			continue
+		}
+		e := ical.Event{
+			UID:          orig.UID,
+			Summary:      orig.Summary,
+			Location:     c.NewRoom,
+			Description:  description(c),
+			Start:        t,
+			End:          t.Add(duration),
+			RecurrenceID: t,
+			Status:       ical.StatusConfirmed,
+		}
+		overrides[key] = len(events)
+		events = append(events, e)
+	}
+	return events
+}
+
+// WriteCalendar writes the timetable with the changes applied as an iCalendar document. tt may be nil.
+func WriteCalendar(w io.Writer, tt *portal.Timetable, cal ical.AcademicCalendar, changes []Change) error {
+	events, err := Events(tt, cal, changes)
+	if err != nil {
+		return err
+	}
+	name := "休講・補講"
+	if tt != nil {
+		name = fmt.Sprintf("%d年度 %s 時間割", tt.Year, tt.Term)
+	}
+	return ical.Write(w, &ical.Calendar{Name: name, Events: events})
+}
+
+// span returns the time of the periods on the date. A class without period lasts all day long,
+// and its event is written with AllDay.
+func span(periods []portal.Period, day time.Time, period, length int) (time.Time, time.Time, bool) {
+	if day.IsZero() {
+		return time.Time{}, time.Time{}, false
+	}
+	day = date(day)
+	if period == 0 {
+		return day, day.AddDate(0, 0, 1), true
+	}
+	if length < 1 {
+		length = 1
+	}
+	var start, end time.Duration
+	for _, p := range periods {
+		if p.Number == period {
+			start = p.Start
+		}
+		if p.Number == period+length-1 {
+			end = p.End
+		}
+	}
+	if start == 0 || end == 0 {
+		return time.Time{}, time.Time{}, false
+	}
+	return day.Add(start), day.Add(end), true
+}
+
+func description(c Change) string {
+	var lines []string
+	if c.Instructor != "" {
+		lines = append(lines, c.Instructor)
+	}
+	if c.Kind == RoomChange && c.Room != "" {
+		lines = append(lines, c.Room+" → "+c.NewRoom)
+	}
+	if c.Reason != "" {
+		lines = append(lines, c.Reason)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func date(t time.Time) time.Time {
+	t = t.In(portal.JST)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, portal.JST)
+}
@@ -0,0 +1,309 @@
+// Package classchange extracts class cancellations ("休講"), make-up classes ("補講") and room changes ("教室変更")
+// from the notices on the portal, and applies them to the timetable calendar.
+package classchange
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+var (
+	fieldPattern   = regexp.MustCompile(`^([^：:]{1,20})[：:]\s*(.*)$`)
+	datePattern    = regexp.MustCompile(`(?:(\d{4})\s*年\s*)?(\d{1,2})\s*[月/]\s*(\d{1,2})`)
+	periodPattern  = regexp.MustCompile(`(\d)\s*限?\s*(?:[・,、~～〜\-－]\s*(\d)\s*)?限`)
+	bracketPattern = regexp.MustCompile(`^[【\[［].*?[】\]］]\s*`)
+)
+
+// Kind is the kind of a class change.
+type Kind int
+
+const (
+	// Cancelled means the class is not held.
+	Cancelled Kind = iota + 1
+	// MakeUp means an extra class is held for a cancelled one.
+	MakeUp
+	// RoomChange means the class is held in another room.
+	RoomChange
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Cancelled:
+		return "cancelled"
+	case MakeUp:
+		return "make_up"
+	case RoomChange:
+		return "room_change"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// kindKeywords are the words in the category or the title of a notice telling the kind.
+// Room changes come first, because their notices often mention the cancelled room.
+var kindKeywords = []struct {
+	word string
+	kind Kind
+}{
+	{"教室変更", RoomChange},
+	{"補講", MakeUp},
+	{"休講", Cancelled},
+}
+
+// Change is a class change announced by a notice.
+type Change struct {
+	Kind Kind `json:"kind"`
+	// NoticeID is the ID of the notice, and Fingerprint is its fingerprint to detect modification.
+	NoticeID    string `json:"notice_id"`
+	Fingerprint string `json:"fingerprint"`
+	// NoticeDate is the posting date of the notice. A room change without Date applies from it onward.
+	NoticeDate time.Time `json:"notice_date,omitempty"`
+	Course     string    `json:"course"`
+	// Code is the timetable code. Notices rarely have it, but Merge fills it from the timetable.
+	Code       string `json:"code,omitempty"`
+	Instructor string `json:"instructor,omitempty"`
+	// Date, Period and Length are the affected class. For MakeUp, they are the cancelled class if the notice tells it.
+	// Date is the midnight in JST, and Period is zero when the notice does not tell it.
+	Date   time.Time `json:"date,omitempty"`
+	Period int       `json:"period,omitempty"`
+	Length int       `json:"length,omitempty"`
+	Room   string    `json:"room,omitempty"`
+	// NewDate, NewPeriod and NewLength are the time of the make-up class.
+	NewDate   time.Time `json:"new_date,omitempty"`
+	NewPeriod int       `json:"new_period,omitempty"`
+	NewLength int       `json:"new_length,omitempty"`
+	// NewRoom is the room of the make-up class or the room changed to.
+	NewRoom string `json:"new_room,omitempty"`
+	Reason  string `json:"reason,omitempty"`
+}
+
+// ParseError will be return when a notice of a class change does not have the date.
+type ParseError struct {
+	title  string
+	errMsg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Could not parse the class change '%s'. %s", e.title, e.errMsg)
+}
+
+// KindOf returns the kind of the class change announced by the notice. It returns false for other notices.
+func KindOf(n portal.Notice) (Kind, bool) {
+	for _, text := range []string{n.Category, n.Title} {
+		for _, k := range kindKeywords {
+			if strings.Contains(text, k.word) {
+				return k.kind, true
+			}
+		}
+	}
+	return 0, false
+}
+
+// Parse extracts the class change from the notice. Each line of the body is expected to be "label：value"
+// such as "休講日時：5月14日（月）1限". The year is inferred from the posting date when omitted.
+func Parse(n portal.Notice) (*Change, error) {
+	kind, ok := KindOf(n)
+	if !ok {
+		return nil, &ParseError{title: n.Title, errMsg: "The notice is not a class change."}
+	}
+	c := &Change{Kind: kind, NoticeID: n.ID(), Fingerprint: n.Fingerprint(), NoticeDate: n.Date}
+	var reasons []string
+	for _, line := range strings.Split(n.Body, "\n") {
+		m := fieldPattern.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		label, value := strings.TrimSpace(m[1]), strings.TrimSpace(m[2])
+		switch {
+		case strings.Contains(label, "コード"):
+			c.Code = value
+		case strings.Contains(label, "科目"):
+			c.Course = value
+		case strings.Contains(label, "教員"):
+			c.Instructor = value
+		case strings.Contains(label, "教室") || strings.Contains(label, "講義室"):
+			if strings.Contains(label, "前") || (kind == Cancelled && !strings.Contains(label, "後")) {
+				c.Room = value
+			} else {
+				c.NewRoom = value
+			}
+		case strings.Contains(label, "日") || strings.Contains(label, "時限"):
+			date, period, length := parseSchedule(value, n.Date)
+			original := kind != MakeUp || strings.Contains(label, "休講")
+			if strings.Contains(label, "補講") {
+				original = false
+			}
+			if original {
+				c.Date, c.Period, c.Length = merge(c.Date, c.Period, c.Length, date, period, length)
+			} else {
+				c.NewDate, c.NewPeriod, c.NewLength = merge(c.NewDate, c.NewPeriod, c.NewLength, date, period, length)
+			}
+		case strings.Contains(label, "理由") || strings.Contains(label, "事由") || strings.Contains(label, "備考"):
+			reasons = append(reasons, value)
+		}
+	}
+	c.Reason = strings.Join(reasons, "\n")
+	if c.Course == "" {
+		c.Course = strings.TrimSpace(bracketPattern.ReplaceAllString(n.Title, ""))
+	}
+	switch {
+	case kind == MakeUp && c.NewDate.IsZero():
+		return nil, &ParseError{title: n.Title, errMsg: "The date of the make-up class is not found."}
+	case kind == Cancelled && c.Date.IsZero():
+		return nil, &ParseError{title: n.Title, errMsg: "The date of the cancelled class is not found."}
+	}
+	return c, nil
+}
+
+// merge keeps the date and the period found in an earlier line, such as "日付" followed by "時限".
+func merge(date time.Time, period, length int, newDate time.Time, newPeriod, newLength int) (time.Time, int, int) {
+	if !newDate.IsZero() {
+		date = newDate
+	}
+	if newPeriod != 0 {
+		period, length = newPeriod, newLength
+	}
+	return date, period, length
+}
+
+// parseSchedule parses the text such as "2018年5月14日（月）1限" or "5/15(火)3・4限".
+func parseSchedule(s string, posted time.Time) (time.Time, int, int) {
+	var (
+		date           time.Time
+		period, length int
+	)
+	if m := datePattern.FindStringSubmatch(s); m != nil {
+		month, _ := strconv.Atoi(m[2])
+		day, _ := strconv.Atoi(m[3])
+		posted = posted.In(portal.JST)
+		year := posted.Year()
+		if m[1] != "" {
+			year, _ = strconv.Atoi(m[1])
+		}
+		date = time.Date(year, time.Month(month), day, 0, 0, 0, 0, portal.JST)
+		// A notice in December tells about January of the next year.
+		if m[1] == "" && !posted.IsZero() && date.Before(posted.AddDate(0, -6, 0)) {
+			date = date.AddDate(1, 0, 0)
+		}
+	}
+	if m := periodPattern.FindStringSubmatch(s); m != nil {
+		period, _ = strconv.Atoi(m[1])
+		length = 1
+		if last, err := strconv.Atoi(m[2]); err == nil && last > period {
+			length = last - period + 1
+		}
+	}
+	return date, period, length
+}
+
+// Client fetches the class changes from the portal.
+type Client struct {
+	Portal *portal.Client
+	// Query filters the notices to look into. All notices are looked into when it is empty.
+	Query portal.NoticeQuery
+	// OnError is called with the notice of a class change which could not be parsed. Such notices are skipped.
+	OnError func(error)
+}
+
+// NewClient create new client with given http.Client logged in with kitwalk.
+func NewClient(client *http.Client) *Client {
+	return &Client{Portal: portal.NewClient(client)}
+}
+
+// Changes fetches the notices and returns the class changes in them, in order of posting.
+func (c *Client) Changes(ctx context.Context) ([]Change, error) {
+	notices, err := c.Portal.AllNotices(ctx, c.Query)
+	if err != nil {
+		return nil, err
+	}
+	return Extract(notices, c.OnError), nil
+}
+
+// Extract returns the class changes in the notices, in order of posting.
+// onError is called with each notice which could not be parsed. It may be nil.
+func Extract(notices []portal.Notice, onError func(error)) []Change {
+	sorted := append([]portal.Notice(nil), notices...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	var changes []Change
+	for _, n := range sorted {
+		if _, ok := KindOf(n); !ok {
+			continue
+		}
+		c, err := Parse(n)
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			continue
+		}
+		changes = append(changes, *c)
+	}
+	return changes
+}
+
+// DiffType is the kind of a Diff.
+type DiffType int
+
+const (
+	// Added means a new class change appeared.
+	Added DiffType = iota + 1
+	// Changed means a class change seen before has been modified.
+	Changed
+	// Removed means a class change seen before disappeared.
+	Removed
+)
+
+func (t DiffType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Changed:
+		return "changed"
+	case Removed:
+		return "removed"
+	}
+	return fmt.Sprintf("DiffType(%d)", int(t))
+}
+
+// Diff is a difference between class changes seen before and now.
+type Diff struct {
+	Type   DiffType
+	Change Change
+	// Previous is the change seen before. It is nil when Type is Added.
+	Previous *Change
+}
+
+// Compare returns the class changes added, modified and removed since the previous ones.
+func Compare(previous, current []Change) []Diff {
+	seen := make(map[string]Change, len(previous))
+	for _, c := range previous {
+		seen[c.NoticeID] = c
+	}
+	var diffs []Diff
+	found := make(map[string]bool, len(current))
+	for _, c := range current {
+		found[c.NoticeID] = true
+		prev, ok := seen[c.NoticeID]
+		switch {
+		case !ok:
+			diffs = append(diffs, Diff{Type: Added, Change: c})
+		case prev.Fingerprint != c.Fingerprint:
+			p := prev
+			diffs = append(diffs, Diff{Type: Changed, Change: c, Previous: &p})
+		}
+	}
+	for _, c := range previous {
+		if !found[c.NoticeID] {
+			p := c
+			diffs = append(diffs, Diff{Type: Removed, Change: c, Previous: &p})
+		}
+	}
+	return diffs
+}
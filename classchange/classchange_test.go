@@ -0,0 +1,185 @@
+package classchange
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/ical"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func jst(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, portal.JST)
+}
+
+func sampleChanges(t *testing.T) []Change {
+	f, err := os.Open("../samples/class_changes.html")
+	check(t, err)
+	defer f.Close()
+	notices, err := portal.ParseNotices(f, nil)
+	check(t, err)
+	return Extract(notices, func(err error) { t.Error(err) })
+}
+
+func sampleTimetable(t *testing.T) *portal.Timetable {
+	f, err := os.Open("../samples/timetable.html")
+	check(t, err)
+	defer f.Close()
+	tt, err := portal.ParseTimetable(f, "table.timetable", "table.timetable_other")
+	check(t, err)
+	return tt
+}
+
+func TestExtract(t *testing.T) {
+	t.Parallel()
+	changes := sampleChanges(t)
+	if len(changes) != 4 {
+		t.Fatalf("Expect: 4 changes\nActual: %+v\n", changes)
+	}
+	cancelled := changes[0]
+	if cancelled.Kind != Cancelled || cancelled.Course != "線形代数学I" || cancelled.Instructor != "山田 太郎" ||
+		!cancelled.Date.Equal(jst(2018, time.May, 14)) || cancelled.Period != 1 || cancelled.Reason != "出張のため" {
+		t.Errorf("Unexpected cancellation %+v", cancelled)
+	}
+	makeUp := changes[1]
+	if makeUp.Kind != MakeUp || !makeUp.NewDate.Equal(jst(2018, time.May, 19)) || makeUp.NewPeriod != 2 ||
+		makeUp.NewRoom != "1-111" || !makeUp.Date.IsZero() {
+		t.Errorf("Unexpected make-up class %+v", makeUp)
+	}
+	room := changes[2]
+	if room.Kind != RoomChange || !room.Date.Equal(jst(2018, time.May, 15)) || room.Period != 3 || room.Length != 2 ||
+		room.Room != "8-301" || room.NewRoom != "8-205" {
+		t.Errorf("Unexpected room change %+v", room)
+	}
+
+	t.Run("Infer the year", func(t *testing.T) {
+		n := portal.Notice{
+			Date:     jst(2018, time.December, 20),
+			Category: "休講",
+			Title:    "【休講】確率統計",
+			Body:     "休講日時：1月11日（金）4限",
+		}
+		c, err := Parse(n)
+		check(t, err)
+		if !c.Date.Equal(jst(2019, time.January, 11)) || c.Course != "確率統計" {
+			t.Errorf("Unexpected change %+v", c)
+		}
+	})
+	t.Run("Fail without date", func(t *testing.T) {
+		_, err := Parse(portal.Notice{Category: "休講", Title: "休講のお知らせ", Body: "詳細は掲示板を確認してください。"})
+		switch err.(type) {
+		case *ParseError:
+		default:
+			t.Errorf("Expect: ParseError\nActual: %v\n", err)
+		}
+	})
+}
+
+func TestMerge(t *testing.T) {
+	t.Parallel()
+	merged := Merge(sampleTimetable(t), sampleChanges(t))
+	if len(merged) != 3 {
+		t.Fatalf("Expect: changes of the registered courses\nActual: %+v\n", merged)
+	}
+	if merged[0].Code != "10110101" || merged[0].Room != "1-111" {
+		t.Errorf("Expect: complemented from the timetable\nActual: %+v\n", merged[0])
+	}
+}
+
+func TestEvents(t *testing.T) {
+	t.Parallel()
+	tt := sampleTimetable(t)
+	cal := ical.AcademicCalendar{
+		"前期":      {Start: jst(2018, time.April, 9), End: jst(2018, time.August, 3)},
+		"第1クォーター": {Start: jst(2018, time.April, 9), End: jst(2018, time.June, 5)},
+		"第2クォーター": {Start: jst(2018, time.June, 6), End: jst(2018, time.August, 3)},
+	}
+	changes := sampleChanges(t)
+
+	t.Run("Override occurrences of the timetable", func(t *testing.T) {
+		events, err := Events(tt, cal, Merge(tt, changes))
+		check(t, err)
+		if len(events) != 8 {
+			t.Fatalf("Expect: 5 weekly events and 3 changes\nActual: %d events\n", len(events))
+		}
+		cancelled, makeUp, room := events[5], events[6], events[7]
+		if cancelled.UID != events[0].UID || !cancelled.RecurrenceID.Equal(jst(2018, time.May, 14).Add(8*time.Hour+50*time.Minute)) ||
+			cancelled.Status != ical.StatusCancelled || cancelled.Summary != "【休講】線形代数学I" {
+			t.Errorf("Unexpected cancellation %+v", cancelled)
+		}
+		if !makeUp.Start.Equal(jst(2018, time.May, 19).Add(10*time.Hour+30*time.Minute)) || !makeUp.RecurrenceID.IsZero() ||
+			makeUp.Location != "1-111" {
+			t.Errorf("Unexpected make-up class %+v", makeUp)
+		}
+		if room.UID != events[3].UID || room.Location != "8-205" || !room.End.Equal(jst(2018, time.May, 15).Add(16*time.Hour+10*time.Minute)) {
+			t.Errorf("Unexpected room change %+v", room)
+		}
+	})
+	t.Run("Write changes without timetable", func(t *testing.T) {
+		var b bytes.Buffer
+		check(t, WriteCalendar(&b, nil, nil, changes))
+		doc := b.String()
+		if strings.Count(doc, "BEGIN:VEVENT") != 4 || strings.Contains(doc, "RECURRENCE-ID") || !strings.Contains(doc, "STATUS:CANCELLED") {
+			t.Errorf("Unexpected calendar %s", doc)
+		}
+	})
+	t.Run("Change the room from the notice date", func(t *testing.T) {
+		room := Change{Kind: RoomChange, NoticeID: "9", Course: "確率統計", NewRoom: "2-201", NoticeDate: jst(2018, time.July, 16)}
+		events, err := Events(tt, cal, []Change{room})
+		check(t, err)
+		if len(events) != 8 || events[4].Location != "2-102" {
+			t.Fatalf("Expect: 3 occurrences from July 16 are overridden\nActual: %+v\n", events)
+		}
+		for i, day := range []int{20, 27} {
+			e := events[5+i]
+			if e.UID != events[4].UID || !e.RecurrenceID.Equal(jst(2018, time.July, day).Add(14*time.Hour+40*time.Minute)) || e.Location != "2-201" {
+				t.Errorf("Unexpected override %+v", e)
+			}
+		}
+
+		room.NoticeDate = jst(2018, time.April, 2)
+		events, err = Events(tt, cal, []Change{room})
+		check(t, err)
+		if len(events) != 5 || events[4].Location != "2-201" {
+			t.Errorf("Expect: the whole series is moved\nActual: %+v\n", events)
+		}
+	})
+	t.Run("Cancel all day without period", func(t *testing.T) {
+		events, err := Events(nil, nil, []Change{{Kind: Cancelled, NoticeID: "1", Course: "集中講義", Date: jst(2018, time.July, 2)}})
+		check(t, err)
+		if len(events) != 1 || !events[0].AllDay || !events[0].End.Equal(jst(2018, time.July, 3)) {
+			t.Errorf("Expect: an all-day cancellation\nActual: %+v\n", events)
+		}
+	})
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+	changes := sampleChanges(t)
+	modified := append([]Change(nil), changes[1:]...)
+	modified[0].Fingerprint = "modified"
+	modified = append(modified, Change{NoticeID: "new", Kind: Cancelled})
+	diffs := Compare(changes, modified)
+	expect := []DiffType{Changed, Added, Removed}
+	if len(diffs) != len(expect) {
+		t.Fatalf("Expect: %v\nActual: %+v\n", expect, diffs)
+	}
+	for i, typ := range expect {
+		if diffs[i].Type != typ {
+			t.Errorf("Expect: %s\nActual: %s\n", typ, diffs[i].Type)
+		}
+	}
+	if diffs[2].Change.NoticeID != changes[0].NoticeID {
+		t.Errorf("Unexpected removed change %+v", diffs[2].Change)
+	}
+}
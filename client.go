@@ -0,0 +1,197 @@
+package kitwalk
+
+import (
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ReloginClient sends requests with the logged-in http.Client.
+// When a request is redirected to the auth server because the session has been expired,
+// it logs in again with Auth and sends the request once more.
+// It is safe for concurrent use, and concurrent requests share one login.
+type ReloginClient struct {
+	Client *http.Client
+	// Auth is used to login again. When it is nil, SessionExpiredError is returned instead.
+	Auth Auth
+
+	mu        sync.Mutex
+	lastLogin time.Time
+}
+
+// NewReloginClient create new client with given authenticator and http.Client.
+func NewReloginClient(auth Auth, client *http.Client) *ReloginClient {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &ReloginClient{Client: client, Auth: auth}
+}
+
+// Do sends the request like http.Client.Do.
+// The request body must be replayable with GetBody to be sent again after login,
+// which is true for the requests created by http.NewRequest with bytes or strings.
+func (c *ReloginClient) Do(req *http.Request) (*http.Response, error) {
+	// Keep the url, because the request may be modified by the transport.
+	u := *req.URL
+	sentAt := time.Now()
+	resp, err := c.Client.Do(req)
+	if err != nil || !isBounced(resp) {
+		return resp, err
+	}
+	drain(resp)
+	expired := &SessionExpiredError{URL: u.String()}
+	if c.Auth == nil || (req.Body != nil && req.GetBody == nil) {
+		return nil, expired
+	}
+	// Login always follows redirects even if the client does not.
+	loginClient := *c.Client
+	loginClient.CheckRedirect = nil
+	if err := c.login(&loginClient, sentAt); err != nil {
+		return nil, err
+	}
+	retry, err := cloneRequest(req, u)
+	if err != nil {
+		return nil, err
+	}
+	if resp, err = c.Client.Do(retry); err != nil || !isBounced(resp) {
+		return resp, err
+	}
+	// The auth server still has the session, and responds with the SAML response for the website
+	// which is posted automatically by browsers.
+	if err := postSamlResp(&loginClient, req, resp); err != nil {
+		return nil, err
+	}
+	if retry, err = cloneRequest(req, u); err != nil {
+		return nil, err
+	}
+	if resp, err = c.Client.Do(retry); err != nil || !isBounced(resp) {
+		return resp, err
+	}
+	drain(resp)
+	return nil, expired
+}
+
+// login logs in unless another request has logged in after given time.
+func (c *ReloginClient) login(client *http.Client, since time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.lastLogin.After(since) {
+		return nil
+	}
+	if err := c.Auth.LoginWith(client); err != nil {
+		return err
+	}
+	c.lastLogin = time.Now()
+	return nil
+}
+
+// postSamlResp posts the SAML response in the page of the auth server to the website.
+func postSamlResp(client *http.Client, req *http.Request, resp *http.Response) error {
+	if resp.StatusCode/100 == 3 {
+		drain(resp)
+		loc, err := resp.Location()
+		if err != nil {
+			return err
+		}
+		getReq, err := http.NewRequest(http.MethodGet, loc.String(), nil)
+		if err != nil {
+			return err
+		}
+		if resp, err = client.Do(getReq.WithContext(req.Context())); err != nil {
+			return err
+		}
+	}
+	defer drain(resp)
+	actionURL, data, err := parseSamlResp(resp.Body)
+	if err != nil {
+		return err
+	}
+	postReq, err := http.NewRequest(http.MethodPost, actionURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	postReq = postReq.WithContext(req.Context())
+	postReq.Header.Add(contentTypeHead, contentTypeVal)
+	postResp, err := client.Do(postReq)
+	if err != nil {
+		return err
+	}
+	drain(postResp)
+	return nil
+}
+
+// Get issues a GET to the specified url.
+func (c *ReloginClient) Get(rawurl string) (*http.Response, error) {
+	req, err := http.NewRequest(http.MethodGet, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.Do(req)
+}
+
+// isBounced reports whether the response is the auth server, or a redirect to it.
+func isBounced(resp *http.Response) bool {
+	if resp.Request.URL.Host == DefaultAuthDomain {
+		return true
+	}
+	if resp.StatusCode/100 == 3 {
+		if loc, err := resp.Location(); err == nil {
+			return loc.Host == DefaultAuthDomain || strings.Contains(loc.Path, "/Shibboleth.sso/")
+		}
+	}
+	return false
+}
+
+func cloneRequest(req *http.Request, u url.URL) (*http.Request, error) {
+	r := req.WithContext(req.Context())
+	r.Header = make(http.Header, len(req.Header))
+	for k, v := range req.Header {
+		r.Header[k] = append([]string(nil), v...)
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		r.Body = body
+	}
+	r.URL = &u
+	return r, nil
+}
+
+func drain(resp *http.Response) {
+	io.Copy(ioutil.Discard, resp.Body)
+	resp.Body.Close()
+}
+
+// WithContext returns a copy of the client which sends every request with ctx instead of the context of the request.
+// It lets Auth.LoginWith, which takes no context, be canceled with ctx. The copy shares the jar with the client.
+func WithContext(ctx context.Context, client *http.Client) *http.Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	c := *client
+	c.Transport = &contextTransport{ctx: ctx, base: client.Transport}
+	return &c
+}
+
+type contextTransport struct {
+	ctx  context.Context
+	base http.RoundTripper
+}
+
+func (t *contextTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.ctx.Err(); err != nil {
+		return nil, err
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req.WithContext(t.ctx))
+}
@@ -0,0 +1,45 @@
+package kitwalk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestReloginClient_Do(t *testing.T) {
+	t.Parallel()
+	t.Run("Login again when session has been expired", func(t *testing.T) {
+		authenticator, err := newTestAuthenticator(context.Background(), validUsername, validPasswd)
+		check(t, err)
+		client := &http.Client{Transport: &samlMock{Authenticated: false}}
+		resp, err := NewReloginClient(authenticator, client).Get(ShibbolethLoginURL)
+		check(t, err)
+		defer drain(resp)
+		if resp.Request.URL.String() != ShibbolethLoginURL {
+			t.Errorf("Expect: %+v\nActual: %+v\n", ShibbolethLoginURL, resp.Request.URL)
+		}
+	})
+	t.Run("Session expired without authenticator", func(t *testing.T) {
+		client := &http.Client{Transport: &samlMock{Authenticated: false}}
+		_, err := NewReloginClient(nil, client).Get(ShibbolethLoginURL)
+		switch e := err.(type) {
+		case *SessionExpiredError:
+			// Expected
+		default:
+			t.Errorf("Expected: SessionExpiredError\nActual: %+v\n", e)
+		}
+	})
+}
+
+func TestWithContext(t *testing.T) {
+	t.Parallel()
+	authenticator, err := newTestAuthenticator(context.Background(), validUsername, validPasswd)
+	check(t, err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	client := WithContext(ctx, &http.Client{Transport: &samlMock{Authenticated: false}})
+	if err := authenticator.LoginWith(client); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected: %v\nActual: %+v\n", context.Canceled, err)
+	}
+}
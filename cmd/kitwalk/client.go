@@ -0,0 +1,128 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+// sessionClient returns a client with the cookies of the session.
+func sessionClient(session *kitwalk.Session) *http.Client {
+	jar := kitwalk.NewJar()
+	session.Restore(jar)
+	return &http.Client{Jar: jar}
+}
+
+// newAuthenticator create new authenticator which remembers login failures next to the session file,
+// so that repeated runs with a wrong password do not lock the account.
+func newAuthenticator(ctx context.Context, opts *options, username, password string) (kitwalk.Auth, error) {
+	auth, err := kitwalk.NewAuthenticator(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	if sa, ok := auth.(*kitwalk.SamlAuthenticator); ok {
+		sa.Limiter = kitwalk.NewLoginLimiter()
+		sa.Limiter.Store = opts.store()
+		sa.Limiter.OnError = func(err error) {
+			fmt.Fprintf(os.Stderr, "kitwalk: could not record the login: %v\n", err)
+		}
+	}
+	return auth, nil
+}
+
+// authClient is a client which reuses the saved session and logs in again when credentials are given.
+type authClient struct {
+	*kitwalk.ReloginClient
+	username string
+	store    *kitwalk.FileSessionStore
+	recorder *loginRecorder
+}
+
+// loginRecorder records whether login has been done.
+type loginRecorder struct {
+	kitwalk.Auth
+	loggedIn bool
+	// onLogin is called after login if it is set.
+	onLogin func()
+}
+
+func (r *loginRecorder) LoginWith(client *http.Client) error {
+	if err := r.Auth.LoginWith(client); err != nil {
+		return err
+	}
+	r.loggedIn = true
+	if r.onLogin != nil {
+		r.onLogin()
+	}
+	return nil
+}
+
+// newAuthClient restores the saved session if it exists.
+// Either a saved session or credentials is required.
+func newAuthClient(ctx context.Context, opts *options, creds *credentialFlags) (*authClient, error) {
+	c := &authClient{store: opts.store()}
+	session, loadErr := c.store.Load()
+	if _, ok := loadErr.(*kitwalk.SessionNotFoundError); ok {
+		session = &kitwalk.Session{}
+	} else if loadErr != nil {
+		return nil, loadErr
+	}
+	client := sessionClient(session)
+	c.username = session.Username
+	var auth kitwalk.Auth
+	uname, passwd, credErr := creds.resolve()
+	_, missing := credErr.(*credentialsError)
+	switch {
+	case credErr == nil:
+		var err error
+		if auth, err = newAuthenticator(ctx, opts, uname, passwd); err != nil {
+			return nil, err
+		}
+		if session.Username != "" && session.Username != uname {
+			// The saved session belongs to another user.
+			client = sessionClient(&kitwalk.Session{})
+		}
+		c.recorder = &loginRecorder{Auth: auth}
+		auth = c.recorder
+		c.username = uname
+	case !missing:
+		return nil, credErr
+	case loadErr != nil:
+		// Neither session nor credentials is available.
+		return nil, loadErr
+	}
+	c.ReloginClient = kitwalk.NewReloginClient(auth, client)
+	return c, nil
+}
+
+// save saves the cookies as the session when login has been done.
+func (c *authClient) save() error {
+	if c.recorder == nil || !c.recorder.loggedIn {
+		return nil
+	}
+	return c.store.Save(captureSession(c.recorder.Auth, c.username, c.Client.Jar))
+}
+
+// saveOnLogin saves the session every time login is done. It is used by long-running commands.
+func (c *authClient) saveOnLogin(stderr io.Writer) {
+	if c.recorder == nil {
+		return
+	}
+	c.recorder.onLogin = func() {
+		if err := c.save(); err != nil {
+			fmt.Fprintf(stderr, "kitwalk: could not save the session: %v\n", err)
+		}
+	}
+}
+
+// captureSession takes the cookies with their attributes from the authenticator if possible.
+func captureSession(auth kitwalk.Auth, username string, jar http.CookieJar) *kitwalk.Session {
+	if sa, ok := auth.(*kitwalk.SamlAuthenticator); ok {
+		return sa.Session(jar)
+	}
+	return kitwalk.CaptureSession(username, jar)
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+const (
+	usernameEnv = "KITWALK_USERNAME"
+	passwordEnv = "KITWALK_PASSWORD"
+)
+
+// credentialProvider looks up username and password. Empty values mean it has nothing.
+type credentialProvider interface {
+	lookup() (username string, password string, err error)
+}
+
+// flagProvider returns the username given by the flag.
+// Password is never taken from flags, because it would be visible in the process list.
+type flagProvider struct {
+	username string
+}
+
+func (p *flagProvider) lookup() (string, string, error) {
+	return p.username, "", nil
+}
+
+// envProvider reads KITWALK_USERNAME and KITWALK_PASSWORD.
+type envProvider struct{}
+
+func (p *envProvider) lookup() (string, string, error) {
+	return os.Getenv(usernameEnv), os.Getenv(passwordEnv), nil
+}
+
+// fileProvider reads the password from the first line of a file.
+type fileProvider struct {
+	path string
+}
+
+func (p *fileProvider) lookup() (string, string, error) {
+	if p.path == "" {
+		return "", "", nil
+	}
+	b, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return "", "", err
+	}
+	return "", firstLine(string(b)), nil
+}
+
+// readerProvider reads the password from the first line of a reader such as stdin.
+type readerProvider struct {
+	r io.Reader
+}
+
+func (p *readerProvider) lookup() (string, string, error) {
+	if p.r == nil {
+		return "", "", nil
+	}
+	line, err := bufio.NewReader(p.r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", "", err
+	}
+	return "", firstLine(line), nil
+}
+
+func firstLine(s string) string {
+	if i := strings.IndexAny(s, "\r\n"); i >= 0 {
+		return s[:i]
+	}
+	return s
+}
+
+// resolveCredentials asks the providers in order, and takes the first non-empty username and password.
+func resolveCredentials(providers ...credentialProvider) (string, string, error) {
+	var username, password string
+	for _, p := range providers {
+		if username != "" && password != "" {
+			break
+		}
+		u, pw, err := p.lookup()
+		if err != nil {
+			return "", "", err
+		}
+		if username == "" {
+			username = u
+		}
+		if password == "" {
+			password = pw
+		}
+	}
+	if username == "" {
+		return "", "", &credentialsError{errMsg: "username is not given. Use --username or " + usernameEnv + "."}
+	}
+	if password == "" {
+		return "", "", &credentialsError{errMsg: "password is not given. Use --password-file, --password-stdin or " + passwordEnv + "."}
+	}
+	return username, password, nil
+}
+
+// credentialFlags are the flags to give credentials.
+type credentialFlags struct {
+	username      string
+	passwordFile  string
+	passwordStdin bool
+}
+
+func newCredentialFlags(fs *flag.FlagSet) *credentialFlags {
+	c := &credentialFlags{}
+	fs.StringVar(&c.username, "username", "", "username such as b1234567 (default $"+usernameEnv+")")
+	fs.StringVar(&c.passwordFile, "password-file", "", "read password from the first line of the file")
+	fs.BoolVar(&c.passwordStdin, "password-stdin", false, "read password from stdin")
+	return c
+}
+
+// resolve looks up credentials from the flags, stdin and environment variables in this order.
+func (c *credentialFlags) resolve() (string, string, error) {
+	providers := []credentialProvider{&flagProvider{username: c.username}, &fileProvider{path: c.passwordFile}}
+	if c.passwordStdin {
+		providers = append(providers, &readerProvider{r: os.Stdin})
+	}
+	providers = append(providers, &envProvider{})
+	return resolveCredentials(providers...)
+}
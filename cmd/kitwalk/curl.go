@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+)
+
+// stringsFlag is a flag which can be given multiple times.
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ", ")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// httpStatusError will be return with --fail when the server responds with 4xx or 5xx.
+type httpStatusError struct {
+	status string
+}
+
+func (e *httpStatusError) Error() string {
+	return "the server responded with " + e.status
+}
+
+func runCurl(args []string, stdout io.Writer) error {
+	fs, opts := newFlagSet("curl")
+	creds := newCredentialFlags(fs)
+	var (
+		method   = fs.String("X", "", "request method (default GET, or POST with -d)")
+		headers  stringsFlag
+		data     stringsFlag
+		output   = fs.String("o", "", "write the body to the file instead of stdout")
+		location = fs.Bool("L", false, "follow redirects")
+		include  = fs.Bool("i", false, "include the response headers in the output")
+		head     = fs.Bool("I", false, "send HEAD and show the response headers only")
+		fail     = fs.Bool("f", false, "fail with exit code 7 when the server responds with 4xx or 5xx")
+	)
+	fs.Var(&headers, "H", "request header such as 'Accept: text/html' (repeatable)")
+	fs.Var(&data, "d", "form data such as 'key=value', or '@file' to read from the file (repeatable)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return &usageError{err: fmt.Errorf("exactly one url is required")}
+	}
+	target, err := url.Parse(fs.Arg(0))
+	if err != nil || target.Host == "" {
+		return &usageError{err: fmt.Errorf("invalid url '%s'", fs.Arg(0))}
+	}
+
+	body, err := formData(data)
+	if err != nil {
+		return err
+	}
+	m := *method
+	switch {
+	case m != "":
+	case *head:
+		m = http.MethodHead
+	case len(data) != 0:
+		m = http.MethodPost
+	default:
+		m = http.MethodGet
+	}
+	var reqBody io.Reader
+	if len(data) != 0 {
+		reqBody = strings.NewReader(body)
+	}
+	req, err := http.NewRequest(m, target.String(), reqBody)
+	if err != nil {
+		return err
+	}
+	if len(data) != 0 {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	for _, h := range headers {
+		kv := strings.SplitN(h, ":", 2)
+		if len(kv) != 2 {
+			return &usageError{err: fmt.Errorf("invalid header '%s'", h)}
+		}
+		req.Header.Add(strings.TrimSpace(kv[0]), strings.TrimSpace(kv[1]))
+	}
+
+	ctx, cancel := opts.context()
+	defer cancel()
+	client, err := newAuthClient(ctx, opts, creds)
+	if err != nil {
+		return err
+	}
+	if !*location {
+		client.Client.CheckRedirect = func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		}
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := client.save(); err != nil {
+		return err
+	}
+
+	out := stdout
+	if *output != "" {
+		f, err := os.Create(*output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	w := bufio.NewWriter(out)
+	if *include || *head {
+		writeHeader(w, resp)
+	}
+	if !*head {
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if *fail && resp.StatusCode >= http.StatusBadRequest {
+		return &httpStatusError{status: resp.Status}
+	}
+	return nil
+}
+
+// formData joins -d values with '&'. A value starting with '@' is read from the file.
+func formData(data []string) (string, error) {
+	parts := make([]string, 0, len(data))
+	for _, d := range data {
+		if strings.HasPrefix(d, "@") {
+			b, err := ioutil.ReadFile(d[1:])
+			if err != nil {
+				return "", err
+			}
+			d = strings.TrimRight(string(b), "\r\n")
+		}
+		parts = append(parts, d)
+	}
+	return strings.Join(parts, "&"), nil
+}
+
+func writeHeader(w io.Writer, resp *http.Response) {
+	fmt.Fprintf(w, "%s %s\r\n", resp.Proto, resp.Status)
+	keys := make([]string, 0, len(resp.Header))
+	for k := range resp.Header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range resp.Header[k] {
+			fmt.Fprintf(w, "%s: %s\r\n", k, v)
+		}
+	}
+	fmt.Fprint(w, "\r\n")
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+// Exit codes. Scripts can tell the class of the error from them.
+const (
+	exitOK = iota
+	// exitError is any other error.
+	exitError
+	// exitUsage is invalid command line.
+	exitUsage
+	// exitInvalidInput is invalid username or missing credentials.
+	exitInvalidInput
+	// exitAuthFailed is rejected username or password by the auth server, or locked account.
+	exitAuthFailed
+	// exitNoSession is no saved session, or the session has been expired.
+	exitNoSession
+	// exitNetwork is network error or timeout.
+	exitNetwork
+	// exitHTTPError is 4xx or 5xx response with --fail.
+	exitHTTPError
+)
+
+type usageError struct {
+	err error
+}
+
+func (e *usageError) Error() string {
+	return e.err.Error()
+}
+
+// credentialsError will be return when username or password is not given.
+type credentialsError struct {
+	errMsg string
+}
+
+func (e *credentialsError) Error() string {
+	return e.errMsg
+}
+
+// exitCode classifies the error, which may be wrapped by fmt.Errorf with %w or by url.Error.
+func exitCode(err error) int {
+	var (
+		usage       *usageError
+		username    *kitwalk.InvalidUsernameError
+		cookie      *kitwalk.CookieFormatError
+		credentials *credentialsError
+		auth        *kitwalk.ShibbolethAuthError
+		loginLocked *kitwalk.LoginLockedError
+		locked      *kitwalk.AccountLockedError
+		notFound    *kitwalk.SessionNotFoundError
+		expired     *kitwalk.SessionExpiredError
+		status      *httpStatusError
+		network     net.Error
+	)
+	switch {
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.As(err, &username), errors.As(err, &cookie), errors.As(err, &credentials):
+		return exitInvalidInput
+	case errors.As(err, &auth), errors.As(err, &loginLocked), errors.As(err, &locked):
+		return exitAuthFailed
+	case errors.As(err, &notFound), errors.As(err, &expired):
+		return exitNoSession
+	case errors.As(err, &status):
+		return exitHTTPError
+	case errors.As(err, &network), errors.Is(err, context.DeadlineExceeded), errors.Is(err, context.Canceled):
+		return exitNetwork
+	}
+	return exitError
+}
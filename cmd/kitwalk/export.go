@@ -0,0 +1,110 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+var exportFormats = map[string]func(io.Writer, []*http.Cookie) error{
+	"netscape":      kitwalk.WriteNetscapeCookies,
+	"header":        kitwalk.WriteCookieHeaders,
+	"storage-state": kitwalk.WriteStorageState,
+	"browser":       kitwalk.WriteBrowserCookies,
+}
+
+func runExport(args []string, stdout io.Writer) error {
+	fs, opts := newFlagSet("export")
+	var (
+		format = fs.String("format", "netscape", "netscape, header, storage-state or browser")
+		output = fs.String("o", "", "write to the file instead of stdout")
+	)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	write, ok := exportFormats[*format]
+	if !ok {
+		return &usageError{err: fmt.Errorf("unknown format '%s'", *format)}
+	}
+	session, err := opts.store().Load()
+	if err != nil {
+		return err
+	}
+	out := stdout
+	if *output != "" {
+		// The file contains the session, so it is readable only by the owner.
+		f, err := os.OpenFile(*output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	return write(out, session.Cookies)
+}
+
+func runImport(args []string, stdout io.Writer) error {
+	fs, opts := newFlagSet("import")
+	username := fs.String("username", "", "user of the session (default the user of the saved session)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return &usageError{err: fmt.Errorf("a file of cookies, or '-' for stdin is required")}
+	}
+	in := io.Reader(os.Stdin)
+	if fs.Arg(0) != "-" {
+		f, err := os.Open(fs.Arg(0))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		in = f
+	}
+	cookies, err := readCookies(in)
+	if err != nil {
+		return err
+	}
+	store := opts.store()
+	if *username == "" {
+		if saved, err := store.Load(); err == nil {
+			*username = saved.Username
+		}
+	}
+	if *username == "" {
+		return &credentialsError{errMsg: "username is not given. Use --username."}
+	}
+	if _, err := kitwalk.ParseStudentID(*username); err != nil {
+		return err
+	}
+	session := &kitwalk.Session{Username: *username, LoggedInAt: time.Now(), Cookies: cookies}
+	if err := store.Save(session); err != nil {
+		return err
+	}
+	result := map[string]interface{}{"username": session.Username, "cookies": len(cookies)}
+	return opts.print(stdout, result, fmt.Sprintf("Imported %d cookies for %s.", len(cookies), session.Username))
+}
+
+// readCookies detects JSON or Netscape cookies.txt format from the first character.
+func readCookies(r io.Reader) ([]*http.Cookie, error) {
+	br := bufio.NewReader(r)
+	for {
+		b, err := br.Peek(1)
+		if err != nil {
+			return nil, err
+		}
+		if !bytes.ContainsAny(b, " \t\r\n") {
+			if b[0] == '{' || b[0] == '[' {
+				return kitwalk.ReadJSONCookies(br)
+			}
+			return kitwalk.ReadNetscapeCookies(br)
+		}
+		br.ReadByte()
+	}
+}
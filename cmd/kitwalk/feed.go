@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/StudioAquatan/kitwalk/feed"
+	"github.com/StudioAquatan/kitwalk/proxy"
+)
+
+func runFeed(args []string, stdout io.Writer) error {
+	fs, opts := newFlagSet("feed")
+	creds := newCredentialFlags(fs)
+	var (
+		listen = fs.String("listen", "127.0.0.1:8001", "address to listen")
+		token  = fs.String("token", os.Getenv(tokenEnv), "access token required for every request (default $"+tokenEnv+")")
+		ttl    = fs.Duration("ttl", feed.DefaultTTL, "duration to cache the notices")
+	)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if !proxy.IsLoopback(*listen) && *token == "" {
+		return &usageError{err: fmt.Errorf("--token is required to listen on '%s' which is not loopback", *listen)}
+	}
+	// The server runs until interrupted, so the authenticator is not bound to --timeout.
+	client, err := newAuthClient(context.Background(), opts, creds)
+	if err != nil {
+		return err
+	}
+	client.saveOnLogin(os.Stderr)
+	server := feed.NewServer(client.Auth, client.Client)
+	server.TTL = *ttl
+	var handler http.Handler = server
+	if *token != "" {
+		handler = requireToken(*token, handler)
+	}
+
+	base := "http://" + *listen
+	paths := []string{feed.RSSPath, feed.AtomPath, feed.JSONFeedPath}
+	info := map[string]interface{}{"listen": *listen, "paths": paths}
+	text := fmt.Sprintf("Serving the notices on %s%s, %s%s and %s%s", base, paths[0], base, paths[1], base, paths[2])
+	if *token != "" {
+		text += fmt.Sprintf(" with ?%s=<token>", proxy.TokenQuery)
+	}
+	if err := opts.print(stdout, info, text); err != nil {
+		return err
+	}
+	return serve(&http.Server{Addr: *listen, Handler: handler})
+}
+
+// requireToken rejects the requests without the token. Feed readers which cannot set
+// "Authorization: Bearer" give it with the query proxy.TokenQuery.
+func requireToken(token string, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given := r.URL.Query().Get(proxy.TokenQuery)
+		if a := r.Header.Get("Authorization"); strings.HasPrefix(a, "Bearer ") {
+			given = strings.TrimPrefix(a, "Bearer ")
+		}
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+// logoutPath is the logout handler of Shibboleth SP relative to the login url.
+const logoutPath = "Shibboleth.sso/Logout"
+
+type loginResult struct {
+	Username   string    `json:"username"`
+	LoggedInAt time.Time `json:"logged_in_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func runLogin(args []string, stdout io.Writer) error {
+	fs, opts := newFlagSet("login")
+	creds := newCredentialFlags(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	uname, passwd, err := creds.resolve()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := opts.context()
+	defer cancel()
+	auth, err := newAuthenticator(ctx, opts, uname, passwd)
+	if err != nil {
+		return err
+	}
+	jar := kitwalk.NewJar()
+	client := &http.Client{Jar: jar}
+	if err := auth.LoginWith(client); err != nil {
+		return err
+	}
+	session := captureSession(auth, uname, jar)
+	if err := opts.store().Save(session); err != nil {
+		return err
+	}
+	result := loginResult{
+		Username:   session.Username,
+		LoggedInAt: session.LoggedInAt,
+		ExpiresAt:  session.ExpiresAt(),
+	}
+	return opts.print(stdout, result, fmt.Sprintf("Logged in as %s. The session will expire around %s.",
+		result.Username, result.ExpiresAt.Format(time.RFC3339)))
+}
+
+type logoutResult struct {
+	Username string `json:"username,omitempty"`
+}
+
+func runLogout(args []string, stdout io.Writer) error {
+	fs, opts := newFlagSet("logout")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	store := opts.store()
+	session, err := store.Load()
+	if _, ok := err.(*kitwalk.SessionNotFoundError); ok {
+		return opts.print(stdout, logoutResult{}, "Not logged in.")
+	}
+	if err != nil {
+		return err
+	}
+	// Tell the website to discard the session as well. It is best effort, so the error is ignored.
+	ctx, cancel := opts.context()
+	defer cancel()
+	if req, err := http.NewRequest(http.MethodGet, kitwalk.ShibbolethLoginURL+logoutPath, nil); err == nil {
+		if resp, err := sessionClient(session).Do(req.WithContext(ctx)); err == nil {
+			resp.Body.Close()
+		}
+	}
+	if err := store.Delete(); err != nil {
+		return err
+	}
+	return opts.print(stdout, logoutResult{Username: session.Username}, "Logged out "+session.Username+".")
+}
@@ -0,0 +1,135 @@
+// Command kitwalk logs in to the websites protected with Shibboleth and keeps the session for shell scripts.
+//
+// Usage:
+//
+//	kitwalk <command> [flags]
+//
+// The commands are:
+//
+//	login   login and save the session
+//	status  check whether the saved session is still valid
+//	logout  discard the saved session
+//	whoami  show the user of the saved session
+//	curl    fetch a url with the saved session like curl
+//	proxy   run a local HTTP proxy which injects the session
+//	serve   present a protected website on the local host
+//	feed    serve the notices of the portal as RSS, Atom and JSON Feed
+//	export  export the cookies of the session for other tools
+//	import  import cookies from other tools as the session
+//
+// Every command accepts --json to print machine-readable output.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+const usage = `Usage: kitwalk <command> [flags]
+
+Commands:
+  login   login and save the session
+  status  check whether the saved session is still valid
+  logout  discard the saved session
+  whoami  show the user of the saved session
+  curl    fetch a url with the saved session like curl
+  proxy   run a local HTTP proxy which injects the session
+  serve   present a protected website on the local host
+  feed    serve the notices of the portal as RSS, Atom and JSON Feed
+  export  export the cookies of the session for other tools
+  import  import cookies from other tools as the session
+
+Run 'kitwalk <command> -h' for the flags of each command.
+`
+
+type command func(args []string, stdout io.Writer) error
+
+var commands = map[string]command{
+	"login":  runLogin,
+	"status": runStatus,
+	"logout": runLogout,
+	"whoami": runWhoami,
+	"curl":   runCurl,
+	"proxy":  runProxy,
+	"serve":  runServe,
+	"feed":   runFeed,
+	"export": runExport,
+	"import": runImport,
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(stderr, "kitwalk: unknown command '%s'\n\n%s", args[0], usage)
+		return exitUsage
+	}
+	if err := cmd(args[1:], stdout); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		fmt.Fprintf(stderr, "kitwalk %s: %v\n", args[0], err)
+		return exitCode(err)
+	}
+	return exitOK
+}
+
+// options are the flags shared by all commands.
+type options struct {
+	sessionPath string
+	json        bool
+	timeout     time.Duration
+}
+
+func newFlagSet(name string) (*flag.FlagSet, *options) {
+	fs := flag.NewFlagSet("kitwalk "+name, flag.ContinueOnError)
+	opts := &options{}
+	defaultPath, _ := kitwalk.DefaultSessionPath()
+	fs.StringVar(&opts.sessionPath, "session", defaultPath, "path of the session file")
+	fs.BoolVar(&opts.json, "json", false, "print machine-readable output")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout of network access")
+	return fs, opts
+}
+
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
+		}
+		return &usageError{err: err}
+	}
+	return nil
+}
+
+func (o *options) store() *kitwalk.FileSessionStore {
+	return &kitwalk.FileSessionStore{Path: o.sessionPath}
+}
+
+func (o *options) context() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), o.timeout)
+}
+
+// print writes v as JSON when --json is given, otherwise writes the text.
+func (o *options) print(w io.Writer, v interface{}, text string) error {
+	if o.json {
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	}
+	_, err := fmt.Fprintln(w, text)
+	return err
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kitwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+
+	t.Run("Unknown command", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"unknown"}, &stdout, &stderr); code != exitUsage {
+			t.Errorf("Expect: %d\nActual: %d\n", exitUsage, code)
+		}
+	})
+	t.Run("Whoami without session", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"whoami", "--session", path}, &stdout, &stderr); code != exitNoSession {
+			t.Errorf("Expect: %d\nActual: %d\n", exitNoSession, code)
+		}
+	})
+	t.Run("Login without password", func(t *testing.T) {
+		os.Unsetenv(passwordEnv)
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"login", "--session", path, "--username", "b1234567"}, &stdout, &stderr)
+		if code != exitInvalidInput {
+			t.Errorf("Expect: %d\nActual: %d %s\n", exitInvalidInput, code, stderr.String())
+		}
+	})
+	t.Run("Whoami with session", func(t *testing.T) {
+		store := &kitwalk.FileSessionStore{Path: path}
+		session := &kitwalk.Session{
+			Username:   "m8123456",
+			LoggedInAt: time.Date(2019, time.April, 1, 0, 0, 0, 0, time.UTC),
+		}
+		if err := store.Save(session); err != nil {
+			t.Fatal(err)
+		}
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"whoami", "--session", path, "--json"}, &stdout, &stderr); code != exitOK {
+			t.Fatalf("Expect: %d\nActual: %d %s\n", exitOK, code, stderr.String())
+		}
+		var result whoamiResult
+		if err := json.Unmarshal(stdout.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if result.StudentNumber != "18123456" || result.Course != "master" || result.EnrollmentYear != 2018 {
+			t.Errorf("Unexpected result %+v", result)
+		}
+	})
+	t.Run("Logout", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"logout", "--session", path, "--timeout", "1ns"}, &stdout, &stderr); code != exitOK {
+			t.Fatalf("Expect: %d\nActual: %d %s\n", exitOK, code, stderr.String())
+		}
+		if !strings.Contains(stdout.String(), "m8123456") {
+			t.Errorf("Unexpected output %q", stdout.String())
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Errorf("Session file remains: %v", err)
+		}
+	})
+}
+
+func TestExportImport(t *testing.T) {
+	dir, err := ioutil.TempDir("", "kitwalk")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "session.json")
+	store := &kitwalk.FileSessionStore{Path: path}
+	session := &kitwalk.Session{
+		Username: "b8123456",
+		Cookies:  []*http.Cookie{{Name: "_shibsession_test", Value: "value", Domain: "portal.student.kit.ac.jp", Path: "/", Secure: true}},
+	}
+	if err := store.Save(session); err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []string{"netscape", "storage-state", "browser"} {
+		t.Run(format, func(t *testing.T) {
+			file := filepath.Join(dir, format)
+			var stdout, stderr bytes.Buffer
+			if code := run([]string{"export", "--session", path, "--format", format, "-o", file}, &stdout, &stderr); code != exitOK {
+				t.Fatalf("Expect: %d\nActual: %d %s\n", exitOK, code, stderr.String())
+			}
+			imported := filepath.Join(dir, format+".json")
+			args := []string{"import", "--session", imported, "--username", "b8123456", file}
+			if code := run(args, &stdout, &stderr); code != exitOK {
+				t.Fatalf("Expect: %d\nActual: %d %s\n", exitOK, code, stderr.String())
+			}
+			loaded, err := (&kitwalk.FileSessionStore{Path: imported}).Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(loaded.Cookies) != 1 || loaded.Cookies[0].Value != "value" || loaded.Cookies[0].Domain != "portal.student.kit.ac.jp" {
+				t.Errorf("Unexpected cookies %+v", loaded.Cookies)
+			}
+		})
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		err    error
+		expect int
+	}{
+		{fmt.Errorf("check: %w", &kitwalk.SessionExpiredError{}), exitNoSession},
+		{&url.Error{Op: "Get", URL: "https://example.com/", Err: &kitwalk.SessionExpiredError{}}, exitNoSession},
+		{&url.Error{Op: "Get", URL: "https://example.com/", Err: context.DeadlineExceeded}, exitNetwork},
+		{fmt.Errorf("login: %w", context.Canceled), exitNetwork},
+		{fmt.Errorf("usage: %w", &usageError{err: errors.New("unknown flag")}), exitUsage},
+		{errors.New("other"), exitError},
+	}
+	for _, c := range cases {
+		if code := exitCode(c.err); code != c.expect {
+			t.Errorf("Expect: %d for %v\nActual: %d\n", c.expect, c.err, code)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/StudioAquatan/kitwalk"
+	"github.com/StudioAquatan/kitwalk/proxy"
+)
+
+const (
+	caCertFileName = "ca.pem"
+	caKeyFileName  = "ca-key.pem"
+)
+
+func runProxy(args []string, stdout io.Writer) error {
+	fs, opts := newFlagSet("proxy")
+	creds := newCredentialFlags(fs)
+	var (
+		listen = fs.String("listen", "127.0.0.1:8080", "address to listen")
+		mitm   = fs.Bool("mitm", false, "intercept HTTPS to the hosts with a local CA to inject the session")
+		caCert = fs.String("ca-cert", "", "certificate of the local CA (default next to the session file)")
+		caKey  = fs.String("ca-key", "", "key of the local CA (default next to the session file)")
+		token  = fs.String("token", os.Getenv(tokenEnv), "access token required as the proxy password (default $"+tokenEnv+")")
+		hosts  stringsFlag
+	)
+	fs.Var(&hosts, "host", "host to inject the session into (repeatable, default the portal)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if !proxy.IsLoopback(*listen) && *token == "" {
+		return &usageError{err: fmt.Errorf("--token is required to listen on '%s' which is not loopback", *listen)}
+	}
+	if len(hosts) == 0 {
+		u, _ := url.Parse(kitwalk.ShibbolethLoginURL)
+		hosts = append(hosts, u.Hostname())
+	}
+
+	var ca *proxy.CA
+	if *mitm {
+		dir := filepath.Dir(opts.sessionPath)
+		if *caCert == "" {
+			*caCert = filepath.Join(dir, caCertFileName)
+		}
+		if *caKey == "" {
+			*caKey = filepath.Join(dir, caKeyFileName)
+		}
+		var err error
+		if ca, err = proxy.LoadOrCreateCA(*caCert, *caKey); err != nil {
+			return err
+		}
+	}
+	// The proxy runs until interrupted, so the authenticator is not bound to --timeout.
+	client, err := newAuthClient(context.Background(), opts, creds)
+	if err != nil {
+		return err
+	}
+	client.saveOnLogin(os.Stderr)
+	handler := proxy.NewForward(client.ReloginClient, ca, hosts...)
+	handler.Token = *token
+
+	proxyURL := "http://" + *listen
+	if *token != "" {
+		proxyURL = "http://kitwalk:<token>@" + *listen
+	}
+	text := fmt.Sprintf("Listening on %s. Set HTTP_PROXY=%s", *listen, proxyURL)
+	if ca != nil {
+		text += fmt.Sprintf(" and trust the CA certificate %s", *caCert)
+	}
+	info := map[string]interface{}{"listen": *listen, "hosts": []string(hosts)}
+	if ca != nil {
+		info["ca_cert"] = *caCert
+	}
+	if err := opts.print(stdout, info, text); err != nil {
+		return err
+	}
+	return serve(&http.Server{Addr: *listen, Handler: handler})
+}
+
+// serve runs the server until interrupted.
+func serve(server *http.Server) error {
+	done := make(chan error, 1)
+	go func() {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, os.Interrupt)
+		<-sig
+		done <- server.Shutdown(context.Background())
+	}()
+	if err := server.ListenAndServe(); err != http.ErrServerClosed {
+		return err
+	}
+	return <-done
+}
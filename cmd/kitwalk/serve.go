@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/StudioAquatan/kitwalk"
+	"github.com/StudioAquatan/kitwalk/proxy"
+)
+
+const tokenEnv = "KITWALK_TOKEN"
+
+func runServe(args []string, stdout io.Writer) error {
+	fs, opts := newFlagSet("serve")
+	creds := newCredentialFlags(fs)
+	var (
+		upstream = fs.String("upstream", kitwalk.ShibbolethLoginURL, "website to present on the local host")
+		listen   = fs.String("listen", "127.0.0.1:8000", "address to listen")
+		token    = fs.String("token", os.Getenv(tokenEnv), "access token required for every request (default $"+tokenEnv+")")
+	)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	u, err := url.Parse(*upstream)
+	if err != nil || u.Host == "" {
+		return &usageError{err: fmt.Errorf("invalid upstream '%s'", *upstream)}
+	}
+	if !proxy.IsLoopback(*listen) && *token == "" {
+		return &usageError{err: fmt.Errorf("--token is required to listen on '%s' which is not loopback", *listen)}
+	}
+	// The server runs until interrupted, so the authenticator is not bound to --timeout.
+	client, err := newAuthClient(context.Background(), opts, creds)
+	if err != nil {
+		return err
+	}
+	client.saveOnLogin(os.Stderr)
+	handler := proxy.NewReverse(client.ReloginClient, u)
+	handler.Token = *token
+
+	info := map[string]interface{}{"listen": *listen, "upstream": u.String()}
+	text := fmt.Sprintf("Serving %s on http://%s/", u, *listen)
+	if err := opts.print(stdout, info, text); err != nil {
+		return err
+	}
+	return serve(&http.Server{Addr: *listen, Handler: handler})
+}
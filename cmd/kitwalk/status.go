@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+type statusResult struct {
+	Username string `json:"username"`
+	Valid    bool   `json:"valid"`
+	// State is "valid", "idp_only" or "expired".
+	State      string    `json:"state"`
+	LoggedInAt time.Time `json:"logged_in_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Remaining is the estimated remaining lifetime in seconds.
+	Remaining int64 `json:"remaining"`
+}
+
+func runStatus(args []string, stdout io.Writer) error {
+	fs, opts := newFlagSet("status")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	session, err := opts.store().Load()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := opts.context()
+	defer cancel()
+	status, err := kitwalk.CheckSessionWithExpiry(ctx, sessionClient(session), session.ExpiresAt())
+	if err != nil {
+		return err
+	}
+	result := statusResult{
+		Username:   session.Username,
+		Valid:      status.Valid(),
+		State:      status.State.String(),
+		LoggedInAt: session.LoggedInAt,
+		ExpiresAt:  status.ExpiresAt,
+		Remaining:  int64(status.Remaining() / time.Second),
+	}
+	var text string
+	switch status.State {
+	case kitwalk.SessionValid:
+		text = fmt.Sprintf("Session of %s is valid. It will expire around %s.",
+			result.Username, result.ExpiresAt.Format(time.RFC3339))
+	case kitwalk.SessionIdPOnly:
+		text = fmt.Sprintf("Session of %s has been expired, but the auth server still remembers the user.", result.Username)
+	default:
+		text = fmt.Sprintf("Session of %s has been expired.", result.Username)
+	}
+	if err := opts.print(stdout, result, text); err != nil {
+		return err
+	}
+	if !status.Valid() {
+		return &kitwalk.SessionExpiredError{URL: kitwalk.ShibbolethLoginURL}
+	}
+	return nil
+}
+
+type whoamiResult struct {
+	Username       string `json:"username"`
+	StudentNumber  string `json:"student_number"`
+	Course         string `json:"course"`
+	EnrollmentYear int    `json:"enrollment_year"`
+}
+
+func runWhoami(args []string, stdout io.Writer) error {
+	fs, opts := newFlagSet("whoami")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	session, err := opts.store().Load()
+	if err != nil {
+		return err
+	}
+	sid, err := kitwalk.ParseStudentIDAt(session.Username, session.LoggedInAt)
+	if err != nil {
+		return err
+	}
+	result := whoamiResult{
+		Username:       sid.Username(),
+		StudentNumber:  sid.StudentNumber(),
+		Course:         sid.Course.String(),
+		EnrollmentYear: sid.EnrollmentYear,
+	}
+	return opts.print(stdout, result, fmt.Sprintf("%s (%s, enrolled in %d)",
+		result.Username, result.Course, result.EnrollmentYear))
+}
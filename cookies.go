@@ -0,0 +1,112 @@
+package kitwalk
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// recordingJar passes cookies to the underlying jar and records them with their attributes,
+// because http.CookieJar cannot enumerate its cookies.
+type recordingJar struct {
+	http.CookieJar
+	mu      sync.Mutex
+	cookies []*http.Cookie
+}
+
+func (j *recordingJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	j.CookieJar.SetCookies(u, cookies)
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	for _, c := range cookies {
+		j.cookies = mergeCookie(j.cookies, normalizeCookie(u, c, time.Now()))
+	}
+}
+
+func (j *recordingJar) recorded() []*http.Cookie {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return append([]*http.Cookie(nil), j.cookies...)
+}
+
+// normalizeCookie fills the attributes of the cookie set by given url.
+// Domain starts with '.' for domain cookies, and it is the host for host-only cookies.
+// MaxAge is converted into Expires.
+func normalizeCookie(u *url.URL, c *http.Cookie, now time.Time) *http.Cookie {
+	n := *c
+	n.Raw = ""
+	n.Unparsed = nil
+	if n.Domain == "" {
+		n.Domain = strings.ToLower(u.Hostname())
+	} else if net.ParseIP(n.Domain) == nil {
+		n.Domain = "." + strings.ToLower(strings.TrimPrefix(n.Domain, "."))
+	}
+	if n.Path == "" || !strings.HasPrefix(n.Path, "/") {
+		n.Path = defaultCookiePath(u.Path)
+	}
+	switch {
+	case n.MaxAge < 0:
+		n.Expires = time.Unix(1, 0)
+	case n.MaxAge > 0:
+		n.Expires = now.Add(time.Duration(n.MaxAge) * time.Second)
+	}
+	n.MaxAge = 0
+	return &n
+}
+
+// defaultCookiePath is the default path of a cookie. See RFC 6265 section 5.1.4.
+func defaultCookiePath(p string) string {
+	if p == "" || p[0] != '/' {
+		return "/"
+	}
+	i := strings.LastIndex(p, "/")
+	if i == 0 {
+		return "/"
+	}
+	return p[:i]
+}
+
+// mergeCookie replaces the cookie with the same name, domain and path, or appends it.
+// Expired cookies are removed.
+func mergeCookie(cookies []*http.Cookie, c *http.Cookie) []*http.Cookie {
+	merged := cookies[:0]
+	for _, old := range cookies {
+		if old.Name != c.Name || old.Domain != c.Domain || old.Path != c.Path {
+			merged = append(merged, old)
+		}
+	}
+	if c.Expires.IsZero() || c.Expires.After(time.Now()) {
+		merged = append(merged, c)
+	}
+	return merged
+}
+
+// cookieURL returns the url to set the cookie to a jar.
+func cookieURL(c *http.Cookie) *url.URL {
+	u := &url.URL{Scheme: "https", Host: strings.TrimPrefix(c.Domain, "."), Path: c.Path}
+	if !c.Secure {
+		u.Scheme = "http"
+	}
+	if u.Path == "" {
+		u.Path = "/"
+	}
+	return u
+}
+
+// isHostOnly reports whether the cookie is sent only to the host which set it.
+func isHostOnly(c *http.Cookie) bool {
+	return !strings.HasPrefix(c.Domain, ".")
+}
+
+// containsCookie reports whether the cookie with the same name and host exists.
+func containsCookie(cookies []*http.Cookie, c *http.Cookie) bool {
+	for _, old := range cookies {
+		if old.Name == c.Name && strings.TrimPrefix(old.Domain, ".") == strings.TrimPrefix(c.Domain, ".") {
+			return true
+		}
+	}
+	return false
+}
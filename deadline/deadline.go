@@ -0,0 +1,156 @@
+// Package deadline collects due dates from the assignments on Moodle and the notices on the portal,
+// and reminds them through the sinks of notify before they are due.
+package deadline
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/internal/text"
+)
+
+// Sources of deadlines.
+const (
+	SourceMoodle = "moodle"
+	SourcePortal = "portal"
+)
+
+// Deadline is a due date of something to submit.
+type Deadline struct {
+	// ID is the stable identifier such as "moodle:assign:5" or "portal:<notice ID>".
+	ID     string    `json:"id"`
+	Title  string    `json:"title"`
+	Course string    `json:"course,omitempty"`
+	Due    time.Time `json:"due"`
+	// Links are where the deadline is found. The first one is the primary source.
+	Links []Link `json:"links"`
+	// Done reports whether it has been submitted. Done deadlines are not reminded.
+	Done bool `json:"done,omitempty"`
+}
+
+// Link is a source of a deadline.
+type Link struct {
+	Source string `json:"source"`
+	URL    string `json:"url,omitempty"`
+}
+
+// URL returns the url of the primary source.
+func (d *Deadline) URL() string {
+	for _, l := range d.Links {
+		if l.URL != "" {
+			return l.URL
+		}
+	}
+	return ""
+}
+
+// Fetcher returns current deadlines.
+type Fetcher interface {
+	Deadlines(ctx context.Context) ([]Deadline, error)
+}
+
+// FetcherFunc is an adapter to use an ordinary function as a Fetcher.
+type FetcherFunc func(ctx context.Context) ([]Deadline, error)
+
+// Deadlines calls f(ctx).
+func (f FetcherFunc) Deadlines(ctx context.Context) ([]Deadline, error) {
+	return f(ctx)
+}
+
+// Collect fetches the deadlines from all fetchers, and returns them deduplicated in order of the due date.
+// A fetcher which fails is skipped, and onError is called with the error. onError may be nil.
+func Collect(ctx context.Context, onError func(error), fetchers ...Fetcher) []Deadline {
+	var all []Deadline
+	for _, f := range fetchers {
+		deadlines, err := f.Deadlines(ctx)
+		if err != nil {
+			if onError != nil {
+				onError(err)
+			}
+			continue
+		}
+		all = append(all, deadlines...)
+	}
+	return Dedupe(all)
+}
+
+// Dedupe merges the deadlines with the same ID, and the ones of the same course due at the same minute
+// from different sources, such as an assignment on Moodle also announced on the portal. The earlier one in the slice is kept
+// with the links of the others appended. The result is sorted by the due date.
+func Dedupe(deadlines []Deadline) []Deadline {
+	var kept []Deadline
+	index := make(map[string]int)
+	for _, d := range deadlines {
+		keys := []string{"id:" + d.ID}
+		if d.Course != "" {
+			keys = append(keys, "due:"+text.Normalize(d.Course)+"@"+d.Due.Truncate(time.Minute).UTC().Format(time.RFC3339))
+		}
+		i, found := -1, false
+		for k, key := range keys {
+			if i, found = index[key]; found && k != 0 && hasSource(&kept[i], source(&d)) {
+				// Two assignments from the same source with different IDs are different even when due together.
+				found = false
+			}
+			if found {
+				break
+			}
+		}
+		if !found {
+			i = len(kept)
+			d.Links = append([]Link(nil), d.Links...)
+			kept = append(kept, d)
+		} else {
+			kept[i].Links = appendLinks(kept[i].Links, d.Links)
+			kept[i].Done = kept[i].Done || d.Done
+		}
+		for _, key := range keys {
+			if _, ok := index[key]; !ok {
+				index[key] = i
+			}
+		}
+	}
+	sort.SliceStable(kept, func(i, j int) bool { return kept[i].Due.Before(kept[j].Due) })
+	return kept
+}
+
+// source returns the source of the deadline, taken from its primary link or the prefix of its ID.
+func source(d *Deadline) string {
+	if len(d.Links) != 0 && d.Links[0].Source != "" {
+		return d.Links[0].Source
+	}
+	if i := strings.Index(d.ID, ":"); i >= 0 {
+		return d.ID[:i]
+	}
+	return ""
+}
+
+// hasSource reports whether the deadline is found in the source.
+func hasSource(d *Deadline, src string) bool {
+	if source(d) == src {
+		return true
+	}
+	for _, l := range d.Links {
+		if l.Source == src {
+			return true
+		}
+	}
+	return false
+}
+
+func appendLinks(links, others []Link) []Link {
+	for _, o := range others {
+		found := false
+		for _, l := range links {
+			if l == o {
+				found = true
+				break
+			}
+		}
+		if !found {
+			links = append(links, o)
+		}
+	}
+	return links
+}
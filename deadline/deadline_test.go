@@ -0,0 +1,237 @@
+package deadline
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/moodle"
+	"github.com/StudioAquatan/kitwalk/notify"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func jst(month time.Month, day, hour, minute int) time.Time {
+	return time.Date(2018, month, day, hour, minute, 0, 0, portal.JST)
+}
+
+func TestFromNotice(t *testing.T) {
+	t.Parallel()
+	posted := jst(time.May, 10, 0, 0)
+	cases := []struct {
+		name   string
+		body   string
+		due    time.Time
+		course string
+	}{
+		{"clock", "科目名：線形代数学I\n提出期限：6月1日（金）17:00\n提出先：教務", jst(time.June, 1, 17, 0), "線形代数学I"},
+		{"hour", "レポートの締切は2018年6月2日 12時です。", jst(time.June, 2, 12, 0), ""},
+		{"end of day", "〆切 6/3", jst(time.June, 3, 23, 59), ""},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			d, ok := FromNotice(portal.Notice{Date: posted, Title: "レポート提出について", Body: c.body, Link: "https://example.com/"})
+			if !ok {
+				t.Fatal("Expect: deadline found")
+			}
+			if !d.Due.Equal(c.due) || d.Course != c.course {
+				t.Errorf("Expect: %v %s\nActual: %v %s\n", c.due, c.course, d.Due, d.Course)
+			}
+			if d.URL() != "https://example.com/" || d.Links[0].Source != SourcePortal {
+				t.Errorf("Expect: link to the notice\nActual: %+v\n", d.Links)
+			}
+		})
+	}
+	t.Run("Next year", func(t *testing.T) {
+		t.Parallel()
+		d, ok := FromNotice(portal.Notice{Date: jst(time.December, 20, 0, 0), Title: "課題", Body: "締め切り：1月8日 9:00"})
+		if !ok || !d.Due.Equal(time.Date(2019, time.January, 8, 9, 0, 0, 0, portal.JST)) {
+			t.Errorf("Expect: 2019-01-08 09:00\nActual: %v\n", d.Due)
+		}
+	})
+	t.Run("No deadline", func(t *testing.T) {
+		t.Parallel()
+		if d, ok := FromNotice(portal.Notice{Date: posted, Title: "休講", Body: "休講日：5月14日"}); ok {
+			t.Errorf("Expect: no deadline\nActual: %+v\n", d)
+		}
+	})
+}
+
+func TestCollect(t *testing.T) {
+	t.Parallel()
+	due := jst(time.June, 1, 17, 0)
+	assignment := FromEvent(moodle.Event{
+		Name: "レポート1", Module: "assign", Instance: 5, CourseName: "線形代数学 I", Due: due,
+		URL: "https://moodle.example.ac.jp/mod/assign/view.php?id=7", Actionable: true,
+	})
+	notice := Deadline{ID: "portal:abc", Title: "レポート提出について", Course: "線形代数学I", Due: due.Add(30 * time.Second),
+		Links: []Link{{Source: SourcePortal, URL: "https://example.com/"}}}
+	other := Deadline{ID: "portal:def", Title: "履修登録", Due: jst(time.May, 20, 23, 59)}
+
+	var errs []error
+	deadlines := Collect(context.Background(), func(err error) { errs = append(errs, err) },
+		FetcherFunc(func(ctx context.Context) ([]Deadline, error) { return []Deadline{assignment}, nil }),
+		FetcherFunc(func(ctx context.Context) ([]Deadline, error) { return nil, errors.New("portal is down") }),
+		FetcherFunc(func(ctx context.Context) ([]Deadline, error) { return []Deadline{notice, other, notice}, nil }),
+	)
+	if len(errs) != 1 {
+		t.Errorf("Expect: 1 error\nActual: %v\n", errs)
+	}
+	if len(deadlines) != 2 {
+		t.Fatalf("Expect: 2 deadlines\nActual: %+v\n", deadlines)
+	}
+	if deadlines[0].ID != other.ID {
+		t.Errorf("Expect: %s first\nActual: %s\n", other.ID, deadlines[0].ID)
+	}
+	merged := deadlines[1]
+	if merged.ID != "moodle:assign:5" || len(merged.Links) != 2 || merged.Links[1].Source != SourcePortal {
+		t.Errorf("Expect: the assignment with the link to the notice\nActual: %+v\n", merged)
+	}
+	if merged.URL() != assignment.Links[0].URL {
+		t.Errorf("Expect: %s\nActual: %s\n", assignment.Links[0].URL, merged.URL())
+	}
+	t.Run("Keep assignments of the same source", func(t *testing.T) {
+		quiz := FromEvent(moodle.Event{
+			Name: "小テスト1", Module: "quiz", Instance: 3, CourseName: "線形代数学 I", Due: due,
+			URL: "https://moodle.example.ac.jp/mod/quiz/view.php?id=8", Actionable: true,
+		})
+		deadlines := Dedupe([]Deadline{assignment, quiz, notice})
+		if len(deadlines) != 2 || deadlines[0].ID != assignment.ID || deadlines[1].ID != quiz.ID || deadlines[1].Title != "小テスト1" {
+			t.Errorf("Expect: the assignment and the quiz kept apart\nActual: %+v\n", deadlines)
+		}
+	})
+}
+
+func TestReminder_Remind(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "kitwalk")
+	check(t, err)
+	defer os.RemoveAll(dir)
+	bolt, err := OpenBoltStore(filepath.Join(dir, "reminders.db"))
+	check(t, err)
+	defer bolt.Close()
+
+	for name, store := range map[string]Store{"memory": &MemoryStore{}, "bolt": bolt} {
+		store := store
+		t.Run(name, func(t *testing.T) {
+			due := jst(time.June, 1, 17, 0)
+			deadlines := []Deadline{
+				{ID: "moodle:assign:5", Title: "レポート1", Course: "線形代数学I", Due: due},
+				{ID: "moodle:assign:6", Title: "レポート2", Due: due, Done: true},
+			}
+			sink := &notify.DryRun{}
+			now := due.Add(-80 * time.Hour)
+			r := NewReminder(sink, store)
+			r.Now = func() time.Time { return now }
+			remind := func(expected int) {
+				t.Helper()
+				count, err := r.Remind(context.Background(), deadlines)
+				check(t, err)
+				if count != expected {
+					t.Errorf("Expect: %d sent at %v\nActual: %d\n", expected, now, count)
+				}
+			}
+
+			remind(0)
+			next, err := r.Next(deadlines)
+			check(t, err)
+			if !next.Equal(due.Add(-72 * time.Hour)) {
+				t.Errorf("Expect: %v\nActual: %v\n", due.Add(-72*time.Hour), next)
+			}
+			now = due.Add(-71 * time.Hour)
+			remind(1)
+			remind(0)
+			// Both 24h and 1h have passed, but one message is sent.
+			now = due.Add(-30 * time.Minute)
+			remind(1)
+			remind(0)
+
+			sent := sink.Sent()
+			if len(sent) != 2 {
+				t.Fatalf("Expect: 2 messages\nActual: %+v\n", sent)
+			}
+			if sent[0].Title != "【締切まであと3日】レポート1" || sent[1].Title != "【締切まであと30分】レポート1" {
+				t.Errorf("Expect: reminders of レポート1\nActual: %s, %s\n", sent[0].Title, sent[1].Title)
+			}
+			if sent[0].Category != Category || sent[0].Body != "線形代数学I\n締切: 2018/06/01 17:00" {
+				t.Errorf("Expect: formatted body\nActual: %q\n", sent[0].Body)
+			}
+
+			// An extended deadline is reminded again.
+			deadlines[0].Due = due.Add(48 * time.Hour)
+			remind(1)
+
+			// Reminders of past deadlines are forgotten.
+			now = due.Add(49 * time.Hour)
+			remind(0)
+			left, err := store.Load()
+			check(t, err)
+			if len(left) != 0 {
+				t.Errorf("Expect: no reminders kept\nActual: %v\n", left)
+			}
+		})
+	}
+}
+
+func TestReminder_RemindFailure(t *testing.T) {
+	t.Parallel()
+	due := jst(time.June, 1, 17, 0)
+	fail := true
+	sink := notify.SinkFunc(func(ctx context.Context, m notify.Message) error {
+		if fail {
+			return errors.New("webhook is down")
+		}
+		return nil
+	})
+	r := NewReminder(sink, &MemoryStore{})
+	r.Now = func() time.Time { return due.Add(-time.Hour) }
+	deadlines := []Deadline{{ID: "a", Title: "レポート", Due: due}}
+	if _, err := r.Remind(context.Background(), deadlines); err == nil {
+		t.Error("Expect: error of the sink")
+	}
+	fail = false
+	count, err := r.Remind(context.Background(), deadlines)
+	check(t, err)
+	if count != 1 {
+		t.Errorf("Expect: sent again after failure\nActual: %d\n", count)
+	}
+}
+
+func TestReminder_RunFailure(t *testing.T) {
+	t.Parallel()
+	var calls int32
+	sink := notify.SinkFunc(func(ctx context.Context, m notify.Message) error {
+		atomic.AddInt32(&calls, 1)
+		return errors.New("webhook is down")
+	})
+	due := time.Now().Add(30 * time.Minute)
+	r := NewReminder(sink, &MemoryStore{}, FetcherFunc(func(ctx context.Context) ([]Deadline, error) {
+		return []Deadline{{ID: "a", Title: "レポート", Due: due}}, nil
+	}))
+	var errs int32
+	r.OnError = func(error) { atomic.AddInt32(&errs, 1) }
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := r.Run(ctx); err != context.DeadlineExceeded {
+		t.Errorf("Expect: %v\nActual: %v\n", context.DeadlineExceeded, err)
+	}
+	if n := atomic.LoadInt32(&calls); n != 1 {
+		t.Errorf("Expect: 1 try before the retry delay\nActual: %d\n", n)
+	}
+	if n := atomic.LoadInt32(&errs); n != 1 {
+		t.Errorf("Expect: 1 error\nActual: %d\n", n)
+	}
+}
@@ -0,0 +1,293 @@
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/notify"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+// DefaultOffsets are the durations before the due date to remind.
+var DefaultOffsets = []time.Duration{72 * time.Hour, 24 * time.Hour, time.Hour}
+
+// DefaultInterval is the interval between fetches of Reminder.Run.
+const DefaultInterval = 15 * time.Minute
+
+// MinRetryDelay is the first delay of Reminder.Run before trying the failed reminders again.
+// The delay doubles up to the interval while the sink keeps failing.
+const MinRetryDelay = time.Minute
+
+// Category is the category of the reminder messages, to be filtered with notify.Filter.
+const Category = "締切"
+
+// Reminder sends reminders of the deadlines to the sink, and remembers them in the store.
+type Reminder struct {
+	Sink  notify.Sink
+	Store Store
+	// Offsets are the durations before the due date to remind. DefaultOffsets is used when it is empty.
+	Offsets []time.Duration
+	// Format converts the deadline into a message. DefaultFormat is used when it is nil.
+	Format func(d Deadline, left time.Duration) notify.Message
+	// Fetchers are used by Run.
+	Fetchers []Fetcher
+	// Interval is the interval between fetches of Run. DefaultInterval is used when it is zero.
+	Interval time.Duration
+	// OnError is called when a fetch or a sink fails during Run.
+	OnError func(error)
+	// Now returns current time. time.Now is used when it is nil.
+	Now func() time.Time
+}
+
+// NewReminder create new reminder with given sink, store and fetchers.
+func NewReminder(sink notify.Sink, store Store, fetchers ...Fetcher) *Reminder {
+	return &Reminder{
+		Sink:     sink,
+		Store:    store,
+		Offsets:  DefaultOffsets,
+		Fetchers: fetchers,
+		Interval: DefaultInterval,
+	}
+}
+
+// Key returns the key of the reminder of the deadline in the store.
+// The due date is a part of the key, so an extended deadline is reminded again.
+func Key(d Deadline, offset time.Duration) string {
+	return fmt.Sprintf("%s@%d/%s", d.ID, d.Due.Unix(), offset)
+}
+
+func (r *Reminder) now() time.Time {
+	if r.Now != nil {
+		return r.Now()
+	}
+	return time.Now()
+}
+
+func (r *Reminder) offsets() []time.Duration {
+	if len(r.Offsets) == 0 {
+		return DefaultOffsets
+	}
+	return r.Offsets
+}
+
+// Remind sends the reminders due now, and returns the number of the messages sent.
+// When several offsets have passed, such as for a deadline found 20 hours before, only one message is sent.
+// Done and past deadlines are not reminded. The deadlines whose sink fails are tried again at the next call,
+// and the first error is returned.
+func (r *Reminder) Remind(ctx context.Context, deadlines []Deadline) (int, error) {
+	sent, err := r.Store.Load()
+	if err != nil {
+		return 0, err
+	}
+	now := r.now()
+	format := r.Format
+	if format == nil {
+		format = DefaultFormat
+	}
+	var (
+		count int
+		first error
+		dirty bool
+	)
+	for _, d := range deadlines {
+		if d.Done || !d.Due.After(now) {
+			continue
+		}
+		var pending []string
+		for _, offset := range r.offsets() {
+			key := Key(d, offset)
+			if _, ok := sent[key]; ok || now.Before(d.Due.Add(-offset)) {
+				continue
+			}
+			pending = append(pending, key)
+		}
+		if len(pending) == 0 {
+			continue
+		}
+		if err := r.Sink.Send(ctx, format(d, d.Due.Sub(now))); err != nil {
+			if first == nil {
+				first = err
+			}
+			continue
+		}
+		count++
+		for _, key := range pending {
+			sent[key] = d.Due
+		}
+		dirty = true
+	}
+	// Forget the reminders of the past deadlines.
+	for key, due := range sent {
+		if due.Before(now) {
+			delete(sent, key)
+			dirty = true
+		}
+	}
+	if dirty {
+		if err := r.Store.Save(sent); err != nil {
+			return count, err
+		}
+	}
+	return count, first
+}
+
+// Next returns the time of the earliest reminder of the deadlines not sent yet.
+// It returns zero time when there is no reminder to send.
+func (r *Reminder) Next(deadlines []Deadline) (time.Time, error) {
+	sent, err := r.Store.Load()
+	if err != nil {
+		return time.Time{}, err
+	}
+	now := r.now()
+	var next time.Time
+	for _, d := range deadlines {
+		if d.Done || !d.Due.After(now) {
+			continue
+		}
+		for _, offset := range r.offsets() {
+			if _, ok := sent[Key(d, offset)]; ok {
+				continue
+			}
+			at := d.Due.Add(-offset)
+			if next.IsZero() || at.Before(next) {
+				next = at
+			}
+		}
+	}
+	return next, nil
+}
+
+// Run fetches the deadlines with Fetchers periodically and sends the reminders until ctx is done.
+// It wakes up earlier than Interval when a reminder is due before the next fetch.
+// While the sink fails, the reminders are tried again after MinRetryDelay, doubling up to Interval.
+func (r *Reminder) Run(ctx context.Context) error {
+	interval := r.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+	var (
+		deadlines []Deadline
+		fetched   time.Time
+		retry     time.Duration
+	)
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timer.C:
+		}
+		if now := r.now(); fetched.IsZero() || !now.Before(fetched.Add(interval)) {
+			deadlines = Collect(ctx, r.OnError, r.Fetchers...)
+			fetched = now
+		}
+		_, err := r.Remind(ctx, deadlines)
+		if err != nil && ctx.Err() == nil && r.OnError != nil {
+			r.OnError(err)
+		}
+		wait := fetched.Add(interval).Sub(r.now())
+		if next, err := r.Next(deadlines); err == nil && !next.IsZero() {
+			if d := next.Sub(r.now()); d < wait {
+				wait = d
+			}
+		}
+		if err != nil {
+			// The failed reminders are still due, so Next is in the past.
+			if retry = retry * 2; retry < MinRetryDelay {
+				retry = MinRetryDelay
+			}
+			if retry > interval {
+				retry = interval
+			}
+			if wait < retry {
+				wait = retry
+			}
+		} else {
+			retry = 0
+		}
+		if wait < 0 {
+			wait = 0
+		}
+		timer.Reset(wait)
+	}
+}
+
+// DefaultFormat formats the reminder such as "【締切まであと1日】レポート1".
+func DefaultFormat(d Deadline, left time.Duration) notify.Message {
+	var lines []string
+	if d.Course != "" {
+		lines = append(lines, d.Course)
+	}
+	lines = append(lines, "締切: "+d.Due.In(portal.JST).Format("2006/01/02 15:04"))
+	var sources []string
+	for _, l := range d.Links {
+		sources = append(sources, l.Source)
+	}
+	if len(sources) != 0 {
+		lines = append(lines, "("+strings.Join(sources, ", ")+")")
+	}
+	return notify.Message{
+		Title:    "【締切まで" + formatLeft(left) + "】" + d.Title,
+		Body:     strings.Join(lines, "\n"),
+		URL:      d.URL(),
+		Category: Category,
+		Time:     d.Due,
+	}
+}
+
+// formatLeft rounds the duration into days, hours or minutes.
+func formatLeft(left time.Duration) string {
+	if left < time.Hour {
+		return fmt.Sprintf("あと%d分", int((left+30*time.Second)/time.Minute))
+	}
+	hours := int((left + 30*time.Minute) / time.Hour)
+	if hours >= 24 {
+		return fmt.Sprintf("あと%d日", (hours+12)/24)
+	}
+	return fmt.Sprintf("あと%d時間", hours)
+}
+
+// Store keeps the reminders sent. The key is made by Key, and the value is the due date of the deadline
+// to forget the reminders of past deadlines.
+type Store interface {
+	Load() (map[string]time.Time, error)
+	Save(sent map[string]time.Time) error
+	Close() error
+}
+
+// MemoryStore is a Store in memory. The zero value is usable.
+type MemoryStore struct {
+	mu   sync.Mutex
+	sent map[string]time.Time
+}
+
+// Load returns a copy of the reminders sent.
+func (s *MemoryStore) Load() (map[string]time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sent := make(map[string]time.Time, len(s.sent))
+	for k, v := range s.sent {
+		sent[k] = v
+	}
+	return sent, nil
+}
+
+// Save replaces the reminders sent with given ones.
+func (s *MemoryStore) Save(sent map[string]time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sent = make(map[string]time.Time, len(sent))
+	for k, v := range sent {
+		s.sent[k] = v
+	}
+	return nil
+}
+
+// Close does nothing.
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,167 @@
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/internal/text"
+	"github.com/StudioAquatan/kitwalk/moodle"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+// Keywords are the words in a line of a notice telling the deadline, such as "提出期限：6月1日 17:00".
+var Keywords = []string{"締切", "締め切り", "〆切", "提出期限", "期限"}
+
+var (
+	datePattern   = regexp.MustCompile(`(?:(\d{4})\s*[年/]\s*)?(\d{1,2})\s*[月/]\s*(\d{1,2})`)
+	clockPattern  = regexp.MustCompile(`(\d{1,2})\s*[:：]\s*(\d{2})`)
+	hourPattern   = regexp.MustCompile(`(\d{1,2})\s*時\s*(?:(\d{1,2})\s*分)?`)
+	coursePattern = regexp.MustCompile(`^(?:授業)?科目名?\s*[：:]\s*(.+)$`)
+)
+
+// MoodleFetcher fetches the deadlines of the activities on the timeline of Moodle, such as assignments and quizzes.
+type MoodleFetcher struct {
+	Client *moodle.Client
+	// Modules limits the kinds of the activities such as "assign". All kinds are fetched when it is empty.
+	Modules []string
+	// Now returns current time. time.Now is used when it is nil.
+	Now func() time.Time
+}
+
+// Deadlines fetches the activities due from now.
+func (f *MoodleFetcher) Deadlines(ctx context.Context) ([]Deadline, error) {
+	now := time.Now
+	if f.Now != nil {
+		now = f.Now
+	}
+	events, err := f.Client.Events(ctx, now())
+	if err != nil {
+		return nil, err
+	}
+	var deadlines []Deadline
+	for _, e := range events {
+		if len(f.Modules) != 0 && !text.Contains(f.Modules, e.Module) {
+			continue
+		}
+		deadlines = append(deadlines, FromEvent(e))
+	}
+	return deadlines, nil
+}
+
+// FromEvent converts the event on the timeline of Moodle into the deadline.
+func FromEvent(e moodle.Event) Deadline {
+	return Deadline{
+		ID:     fmt.Sprintf("%s:%s:%d", SourceMoodle, e.Module, e.Instance),
+		Title:  e.Name,
+		Course: e.CourseName,
+		Due:    e.Due,
+		Links:  []Link{{Source: SourceMoodle, URL: e.URL}},
+		Done:   !e.Actionable,
+	}
+}
+
+// NoticeFetcher finds the deadlines in the notices on the portal.
+type NoticeFetcher struct {
+	Portal *portal.Client
+	// Query filters the notices to look into. All notices are looked into when it is empty.
+	Query portal.NoticeQuery
+}
+
+// Deadlines fetches the notices and returns the deadlines in them.
+func (f *NoticeFetcher) Deadlines(ctx context.Context) ([]Deadline, error) {
+	notices, err := f.Portal.AllNotices(ctx, f.Query)
+	if err != nil {
+		return nil, err
+	}
+	var deadlines []Deadline
+	for _, n := range notices {
+		if d, ok := FromNotice(n); ok {
+			deadlines = append(deadlines, d)
+		}
+	}
+	return deadlines, nil
+}
+
+// FromNotice finds the deadline in the title or the body of the notice. The first line with one of Keywords
+// and a date is taken. The year is inferred from the posting date when omitted, and the deadline is
+// the end of the day when the time is not written. It returns false when the notice has no deadline.
+func FromNotice(n portal.Notice) (Deadline, bool) {
+	var course string
+	var due time.Time
+	for _, line := range strings.Split(n.Title+"\n"+n.Body, "\n") {
+		line = strings.TrimSpace(line)
+		if m := coursePattern.FindStringSubmatch(line); m != nil && course == "" {
+			course = strings.TrimSpace(m[1])
+		}
+		if !due.IsZero() || !containsKeyword(line) {
+			continue
+		}
+		due = parseDue(line, n.Date)
+	}
+	if due.IsZero() {
+		return Deadline{}, false
+	}
+	link := n.Link
+	if link == "" && len(n.Attachments) != 0 {
+		link = n.Attachments[0]
+	}
+	return Deadline{
+		ID:     SourcePortal + ":" + n.ID(),
+		Title:  n.Title,
+		Course: course,
+		Due:    due,
+		Links:  []Link{{Source: SourcePortal, URL: link}},
+	}, true
+}
+
+func containsKeyword(line string) bool {
+	for _, k := range Keywords {
+		if strings.Contains(line, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseDue parses the text such as "2018年6月1日（金）17:00" or "6/1 12時". It returns zero time without a date.
+func parseDue(s string, posted time.Time) time.Time {
+	loc := datePattern.FindStringSubmatchIndex(s)
+	if loc == nil {
+		return time.Time{}
+	}
+	group := func(i int) string {
+		if loc[2*i] < 0 {
+			return ""
+		}
+		return s[loc[2*i]:loc[2*i+1]]
+	}
+	month, _ := strconv.Atoi(group(2))
+	day, _ := strconv.Atoi(group(3))
+	posted = posted.In(portal.JST)
+	year := posted.Year()
+	if group(1) != "" {
+		year, _ = strconv.Atoi(group(1))
+	}
+	date := time.Date(year, time.Month(month), day, 0, 0, 0, 0, portal.JST)
+	// A notice in December tells about January of the next year.
+	if group(1) == "" && !posted.IsZero() && date.Before(posted.AddDate(0, -6, 0)) {
+		date = date.AddDate(1, 0, 0)
+	}
+	// The time follows the date.
+	rest := s[loc[1]:]
+	if c := clockPattern.FindStringSubmatch(rest); c != nil {
+		hour, _ := strconv.Atoi(c[1])
+		minute, _ := strconv.Atoi(c[2])
+		return date.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	if c := hourPattern.FindStringSubmatch(rest); c != nil {
+		hour, _ := strconv.Atoi(c[1])
+		minute, _ := strconv.Atoi(c[2])
+		return date.Add(time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute)
+	}
+	return date.Add(24*time.Hour - time.Minute)
+}
@@ -0,0 +1,83 @@
+package deadline
+
+import (
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var reminderBucket = []byte("reminders")
+
+// BoltStore is a Store backed by a local bbolt database file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens the database file at given path. The file is created if it does not exist.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(reminderBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Load returns all reminders sent.
+func (s *BoltStore) Load() (map[string]time.Time, error) {
+	sent := make(map[string]time.Time)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(reminderBucket).ForEach(func(k, v []byte) error {
+			var due time.Time
+			if err := due.UnmarshalText(v); err != nil {
+				return err
+			}
+			sent[string(k)] = due
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sent, nil
+}
+
+// Save replaces the reminders sent with given ones in a transaction.
+func (s *BoltStore) Save(sent map[string]time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(reminderBucket); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket(reminderBucket)
+		if err != nil {
+			return err
+		}
+		for key, due := range sent {
+			v, err := due.MarshalText()
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(key), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the database file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// Path returns the path of the database file.
+func (s *BoltStore) Path() string {
+	return s.db.Path()
+}
@@ -1,6 +1,9 @@
 package kitwalk
 
-import "fmt"
+import (
+	"fmt"
+	"time"
+)
 
 // InvalidUsernameError will be return when given user name is invalid.
 type InvalidUsernameError struct {
@@ -24,9 +27,77 @@ func (e *ConfigDoesNotExists) Error() string {
 type ShibbolethAuthError struct {
 	username string
 	errMsg   string
+	// rejected is true when the auth server showed the error of the credentials,
+	// and false when the page could not be understood.
+	rejected bool
 }
 
 func (e *ShibbolethAuthError) Error() string {
 	return fmt.Sprintf(`Authentication failed. 
 			Given error message from auth page is as follows.\n%+v`, e.errMsg)
 }
+
+// InvalidStudentNumberError will be return when given student number is invalid.
+type InvalidStudentNumberError struct {
+	number string
+}
+
+func (e *InvalidStudentNumberError) Error() string {
+	return fmt.Sprintf("Given student number '%s' is invalid. It must be 8 digits.", e.number)
+}
+
+// SessionExpiredError will raise when a request to the protected website is redirected to the auth server.
+type SessionExpiredError struct {
+	URL string
+}
+
+func (e *SessionExpiredError) Error() string {
+	return fmt.Sprintf("Session has been expired. The request to '%s' is redirected to the auth page.", e.URL)
+}
+
+// SessionNotFoundError will raise when no session has been saved.
+type SessionNotFoundError struct {
+	path string
+}
+
+func (e *SessionNotFoundError) Error() string {
+	return fmt.Sprintf("No session has been saved in '%s'. Please login first.", e.path)
+}
+
+// CookieFormatError will raise when the cookie file cannot be parsed.
+type CookieFormatError struct {
+	line   int
+	errMsg string
+}
+
+func (e *CookieFormatError) Error() string {
+	return fmt.Sprintf("Invalid cookie file at line %d: %s", e.line, e.errMsg)
+}
+
+// LoginLockedError will raise when kitwalk refuses to post credentials after repeated failures,
+// to avoid locking the account at the auth server.
+type LoginLockedError struct {
+	username string
+	failures int
+	until    time.Time
+}
+
+func (e *LoginLockedError) Error() string {
+	return fmt.Sprintf("Login of '%s' is refused until %s after %d failures, to avoid locking the account.",
+		e.username, e.until.Format(time.RFC3339), e.failures)
+}
+
+// Until returns when login is allowed again.
+func (e *LoginLockedError) Until() time.Time {
+	return e.until
+}
+
+// AccountLockedError will raise when the auth server reports that the account is locked.
+type AccountLockedError struct {
+	username string
+	errMsg   string
+}
+
+func (e *AccountLockedError) Error() string {
+	return fmt.Sprintf("The account '%s' is locked by the auth server: %s", e.username, e.errMsg)
+}
@@ -0,0 +1,296 @@
+package kitwalk
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	netscapeHeader  = "# Netscape HTTP Cookie File"
+	httpOnlyPrefix  = "#HttpOnly_"
+	netscapeTrue    = "TRUE"
+	netscapeFalse   = "FALSE"
+	netscapeColumns = 7
+)
+
+// WriteNetscapeCookies writes the cookies in Netscape cookies.txt format used by curl, wget and yt-dlp.
+func WriteNetscapeCookies(w io.Writer, cookies []*http.Cookie) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintln(bw, netscapeHeader)
+	fmt.Fprintln(bw, "# This file was generated by kitwalk. Do not share it; it contains your session.")
+	for _, c := range cookies {
+		domain := c.Domain
+		if c.HttpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+		var expires int64
+		if !c.Expires.IsZero() {
+			expires = c.Expires.Unix()
+		}
+		fmt.Fprintf(bw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, netscapeBool(!isHostOnly(c)), cookiePath(c), netscapeBool(c.Secure), expires, c.Name, c.Value)
+	}
+	return bw.Flush()
+}
+
+// ReadNetscapeCookies reads the cookies in Netscape cookies.txt format.
+func ReadNetscapeCookies(r io.Reader) ([]*http.Cookie, error) {
+	var cookies []*http.Cookie
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httpOnly {
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		}
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.Split(line, "\t")
+		if len(fields) != netscapeColumns {
+			return nil, &CookieFormatError{line: n, errMsg: "7 fields separated by tab are required"}
+		}
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, &CookieFormatError{line: n, errMsg: "invalid expiry"}
+		}
+		c := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   fields[3] == netscapeTrue,
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		c.Domain = strings.TrimPrefix(c.Domain, ".")
+		if fields[1] == netscapeTrue {
+			c.Domain = "." + c.Domain
+		}
+		if expires != 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return cookies, nil
+}
+
+func netscapeBool(b bool) string {
+	if b {
+		return netscapeTrue
+	}
+	return netscapeFalse
+}
+
+func cookiePath(c *http.Cookie) string {
+	if c.Path == "" {
+		return "/"
+	}
+	return c.Path
+}
+
+// CookieHeaders returns the value of Cookie header for each host.
+// Domain cookies are listed under the domain without the leading '.'.
+func CookieHeaders(cookies []*http.Cookie) map[string]string {
+	pairs := make(map[string][]string)
+	for _, c := range cookies {
+		host := strings.TrimPrefix(c.Domain, ".")
+		pairs[host] = append(pairs[host], (&http.Cookie{Name: c.Name, Value: c.Value}).String())
+	}
+	headers := make(map[string]string, len(pairs))
+	for host, p := range pairs {
+		headers[host] = strings.Join(p, "; ")
+	}
+	return headers
+}
+
+// WriteCookieHeaders writes "Cookie:" header lines with a comment of the host, sorted by host.
+func WriteCookieHeaders(w io.Writer, cookies []*http.Cookie) error {
+	headers := CookieHeaders(cookies)
+	hosts := make([]string, 0, len(headers))
+	for h := range headers {
+		hosts = append(hosts, h)
+	}
+	sort.Strings(hosts)
+	bw := bufio.NewWriter(w)
+	for _, h := range hosts {
+		fmt.Fprintf(bw, "# %s\nCookie: %s\n", h, headers[h])
+	}
+	return bw.Flush()
+}
+
+// StorageState is the format of storageState of Playwright.
+type StorageState struct {
+	Cookies []StorageStateCookie `json:"cookies"`
+	Origins []json.RawMessage    `json:"origins"`
+}
+
+// StorageStateCookie is a cookie in StorageState.
+type StorageStateCookie struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Domain string `json:"domain"`
+	Path   string `json:"path"`
+	// Expires is Unix time in seconds, and -1 for session cookies.
+	Expires  float64 `json:"expires"`
+	HTTPOnly bool    `json:"httpOnly"`
+	Secure   bool    `json:"secure"`
+	SameSite string  `json:"sameSite"`
+}
+
+// BrowserCookie is a cookie in the JSON format of browser extensions such as Cookie-Editor and EditThisCookie.
+type BrowserCookie struct {
+	Domain         string  `json:"domain"`
+	ExpirationDate float64 `json:"expirationDate,omitempty"`
+	HostOnly       bool    `json:"hostOnly"`
+	HTTPOnly       bool    `json:"httpOnly"`
+	Name           string  `json:"name"`
+	Path           string  `json:"path"`
+	SameSite       string  `json:"sameSite"`
+	Secure         bool    `json:"secure"`
+	Session        bool    `json:"session"`
+	Value          string  `json:"value"`
+}
+
+var (
+	sameSiteNames = map[http.SameSite]string{
+		http.SameSiteLaxMode:    "Lax",
+		http.SameSiteStrictMode: "Strict",
+		http.SameSiteNoneMode:   "None",
+	}
+	browserSameSiteNames = map[http.SameSite]string{
+		http.SameSiteLaxMode:    "lax",
+		http.SameSiteStrictMode: "strict",
+		http.SameSiteNoneMode:   "no_restriction",
+	}
+)
+
+func sameSiteOf(names map[http.SameSite]string, name string) http.SameSite {
+	for s, n := range names {
+		if strings.EqualFold(n, name) {
+			return s
+		}
+	}
+	return http.SameSiteDefaultMode
+}
+
+// WriteStorageState writes the cookies as storageState of Playwright.
+func WriteStorageState(w io.Writer, cookies []*http.Cookie) error {
+	state := StorageState{Cookies: []StorageStateCookie{}, Origins: []json.RawMessage{}}
+	for _, c := range cookies {
+		sc := StorageStateCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     cookiePath(c),
+			Expires:  -1,
+			HTTPOnly: c.HttpOnly,
+			Secure:   c.Secure,
+			SameSite: sameSiteNames[c.SameSite],
+		}
+		if !c.Expires.IsZero() {
+			sc.Expires = float64(c.Expires.Unix())
+		}
+		if sc.SameSite == "" {
+			sc.SameSite = sameSiteNames[http.SameSiteLaxMode]
+		}
+		state.Cookies = append(state.Cookies, sc)
+	}
+	return writeIndentedJSON(w, state)
+}
+
+// WriteBrowserCookies writes the cookies in the JSON format of browser extensions.
+func WriteBrowserCookies(w io.Writer, cookies []*http.Cookie) error {
+	list := []BrowserCookie{}
+	for _, c := range cookies {
+		bc := BrowserCookie{
+			Domain:   c.Domain,
+			HostOnly: isHostOnly(c),
+			HTTPOnly: c.HttpOnly,
+			Name:     c.Name,
+			Path:     cookiePath(c),
+			SameSite: browserSameSiteNames[c.SameSite],
+			Secure:   c.Secure,
+			Session:  c.Expires.IsZero(),
+			Value:    c.Value,
+		}
+		if !c.Expires.IsZero() {
+			bc.ExpirationDate = float64(c.Expires.Unix())
+		}
+		if bc.SameSite == "" {
+			bc.SameSite = "unspecified"
+		}
+		list = append(list, bc)
+	}
+	return writeIndentedJSON(w, list)
+}
+
+// ReadJSONCookies reads the cookies written by WriteStorageState or WriteBrowserCookies.
+// The format is detected from the content.
+func ReadJSONCookies(r io.Reader) ([]*http.Cookie, error) {
+	var raw json.RawMessage
+	if err := json.NewDecoder(r).Decode(&raw); err != nil {
+		return nil, err
+	}
+	var cookies []*http.Cookie
+	if trimmed := strings.TrimSpace(string(raw)); strings.HasPrefix(trimmed, "[") {
+		var list []BrowserCookie
+		if err := json.Unmarshal(raw, &list); err != nil {
+			return nil, err
+		}
+		for _, bc := range list {
+			c := &http.Cookie{
+				Name:     bc.Name,
+				Value:    bc.Value,
+				Domain:   strings.TrimPrefix(bc.Domain, "."),
+				Path:     bc.Path,
+				Secure:   bc.Secure,
+				HttpOnly: bc.HTTPOnly,
+				SameSite: sameSiteOf(browserSameSiteNames, bc.SameSite),
+			}
+			if !bc.HostOnly {
+				c.Domain = "." + c.Domain
+			}
+			if !bc.Session && bc.ExpirationDate > 0 {
+				c.Expires = time.Unix(int64(bc.ExpirationDate), 0)
+			}
+			cookies = append(cookies, c)
+		}
+		return cookies, nil
+	}
+	var state StorageState
+	if err := json.Unmarshal(raw, &state); err != nil {
+		return nil, err
+	}
+	for _, sc := range state.Cookies {
+		c := &http.Cookie{
+			Name:     sc.Name,
+			Value:    sc.Value,
+			Domain:   sc.Domain,
+			Path:     sc.Path,
+			Secure:   sc.Secure,
+			HttpOnly: sc.HTTPOnly,
+			SameSite: sameSiteOf(sameSiteNames, sc.SameSite),
+		}
+		if sc.Expires > 0 {
+			c.Expires = time.Unix(int64(sc.Expires), 0)
+		}
+		cookies = append(cookies, c)
+	}
+	return cookies, nil
+}
+
+func writeIndentedJSON(w io.Writer, v interface{}) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(v)
+}
@@ -0,0 +1,103 @@
+package kitwalk
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testCookies() []*http.Cookie {
+	return []*http.Cookie{
+		{Name: "_shibsession_test", Value: "sp", Domain: "portal.student.kit.ac.jp", Path: "/", Secure: true, HttpOnly: true},
+		{Name: "shib_idp_session", Value: "idp", Domain: ".kit.ac.jp", Path: "/idp", Secure: true,
+			Expires: time.Unix(2000000000, 0), SameSite: http.SameSiteNoneMode},
+	}
+}
+
+func assertCookies(t *testing.T, expected, actual []*http.Cookie) {
+	if len(expected) != len(actual) {
+		t.Fatalf("Expect: %d cookies\nActual: %d cookies\n", len(expected), len(actual))
+	}
+	for i := range expected {
+		e, a := expected[i], actual[i]
+		if e.Name != a.Name || e.Value != a.Value || e.Domain != a.Domain || e.Path != a.Path ||
+			e.Secure != a.Secure || e.HttpOnly != a.HttpOnly || !e.Expires.Equal(a.Expires) {
+			t.Errorf("Expect: %+v\nActual: %+v\n", e, a)
+		}
+	}
+}
+
+func TestNetscapeCookies(t *testing.T) {
+	t.Parallel()
+	var buf bytes.Buffer
+	check(t, WriteNetscapeCookies(&buf, testCookies()))
+	if !strings.Contains(buf.String(), "#HttpOnly_portal.student.kit.ac.jp\tFALSE\t/\tTRUE\t0\t_shibsession_test\tsp") {
+		t.Errorf("Unexpected cookies.txt\n%s", buf.String())
+	}
+	cookies, err := ReadNetscapeCookies(&buf)
+	check(t, err)
+	assertCookies(t, testCookies(), cookies)
+
+	_, err = ReadNetscapeCookies(strings.NewReader("invalid line"))
+	if _, ok := err.(*CookieFormatError); !ok {
+		t.Errorf("Expected: CookieFormatError\nActual: %+v\n", err)
+	}
+}
+
+func TestJSONCookies(t *testing.T) {
+	t.Parallel()
+	t.Run("Storage state", func(t *testing.T) {
+		var buf bytes.Buffer
+		check(t, WriteStorageState(&buf, testCookies()))
+		cookies, err := ReadJSONCookies(&buf)
+		check(t, err)
+		assertCookies(t, testCookies(), cookies)
+		if cookies[1].SameSite != http.SameSiteNoneMode {
+			t.Errorf("Expect: SameSite=None\nActual: %+v\n", cookies[1].SameSite)
+		}
+	})
+	t.Run("Browser extension", func(t *testing.T) {
+		var buf bytes.Buffer
+		check(t, WriteBrowserCookies(&buf, testCookies()))
+		cookies, err := ReadJSONCookies(&buf)
+		check(t, err)
+		assertCookies(t, testCookies(), cookies)
+	})
+}
+
+func TestCookieHeaders(t *testing.T) {
+	t.Parallel()
+	headers := CookieHeaders(testCookies())
+	if headers["portal.student.kit.ac.jp"] != "_shibsession_test=sp" || headers["kit.ac.jp"] != "shib_idp_session=idp" {
+		t.Errorf("Unexpected headers %+v", headers)
+	}
+}
+
+func TestRecordingJar(t *testing.T) {
+	t.Parallel()
+	jar, err := cookiejar.New(nil)
+	check(t, err)
+	recorder := &recordingJar{CookieJar: jar}
+	u, _ := url.Parse("https://portal.student.kit.ac.jp/path/page")
+	recorder.SetCookies(u, []*http.Cookie{
+		{Name: "a", Value: "1"},
+		{Name: "b", Value: "2", Domain: "kit.ac.jp", Path: "/", MaxAge: 60},
+	})
+	cookies := recorder.recorded()
+	if len(cookies) != 2 {
+		t.Fatalf("Expect: 2 cookies\nActual: %+v\n", cookies)
+	}
+	if cookies[0].Domain != "portal.student.kit.ac.jp" || cookies[0].Path != "/path" {
+		t.Errorf("Unexpected host-only cookie %+v", cookies[0])
+	}
+	if cookies[1].Domain != ".kit.ac.jp" || cookies[1].Expires.IsZero() {
+		t.Errorf("Unexpected domain cookie %+v", cookies[1])
+	}
+	if len(jar.Cookies(u)) != 2 {
+		t.Errorf("Cookies are not passed to the jar")
+	}
+}
@@ -0,0 +1,292 @@
+// Package feed converts the notices on the portal into RSS 2.0, Atom and JSON Feed documents.
+package feed
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"mime"
+	"path"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+const (
+	rssVersion      = "2.0"
+	atomNamespace   = "http://www.w3.org/2005/Atom"
+	jsonFeedVersion = "https://jsonfeed.org/version/1.1"
+	idPrefix        = "urn:kitwalk:notice:"
+)
+
+// Meta is the information about the feed itself.
+type Meta struct {
+	Title       string
+	Description string
+	// Link is the url of the website.
+	Link string
+	// FeedURL is the url of the feed document. It is optional.
+	FeedURL string
+	// Lengths are the sizes in bytes of the attachments by url. They are written as the lengths of the enclosures,
+	// and unknown sizes are written as zero in RSS and omitted in the others. Server fills them with HEAD requests.
+	Lengths map[string]int64
+}
+
+// DefaultMeta returns the meta information for the portal.
+func DefaultMeta() Meta {
+	return Meta{
+		Title:       "KIT Portal Notices",
+		Description: "お知らせ on the student portal",
+		Link:        kitwalk.ShibbolethLoginURL,
+	}
+}
+
+// link returns the url the item points. The portal is used when the notice has no link.
+func link(meta Meta, n *portal.Notice) string {
+	if n.Link != "" {
+		return n.Link
+	}
+	if len(n.Attachments) != 0 {
+		return n.Attachments[0]
+	}
+	return meta.Link
+}
+
+func title(n *portal.Notice) string {
+	if n.Category == "" {
+		return n.Title
+	}
+	return "《" + n.Category + "》" + n.Title
+}
+
+func updated(notices []portal.Notice) time.Time {
+	var t time.Time
+	for _, n := range notices {
+		if n.Date.After(t) {
+			t = n.Date
+		}
+	}
+	return t
+}
+
+func mimeType(u string) string {
+	if t := mime.TypeByExtension(path.Ext(u)); t != "" {
+		return t
+	}
+	return "application/octet-stream"
+}
+
+type rss struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string         `xml:"title"`
+	Link        string         `xml:"link"`
+	Description string         `xml:"description"`
+	Category    string         `xml:"category,omitempty"`
+	GUID        rssGUID        `xml:"guid"`
+	PubDate     string         `xml:"pubDate"`
+	Enclosures  []rssEnclosure `xml:"enclosure"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+type rssEnclosure struct {
+	URL    string `xml:"url,attr"`
+	Type   string `xml:"type,attr"`
+	Length int64  `xml:"length,attr"`
+}
+
+// WriteRSS writes the notices as RSS 2.0 document.
+func WriteRSS(w io.Writer, meta Meta, notices []portal.Notice) error {
+	doc := rss{
+		Version: rssVersion,
+		Channel: rssChannel{
+			Title:       meta.Title,
+			Link:        meta.Link,
+			Description: meta.Description,
+		},
+	}
+	if t := updated(notices); !t.IsZero() {
+		doc.Channel.LastBuildDate = t.Format(time.RFC1123Z)
+	}
+	for i := range notices {
+		n := &notices[i]
+		item := rssItem{
+			Title:       title(n),
+			Link:        link(meta, n),
+			Description: n.Body,
+			Category:    n.Category,
+			GUID:        rssGUID{Value: idPrefix + n.ID()},
+			PubDate:     n.Date.Format(time.RFC1123Z),
+		}
+		for _, a := range n.Attachments {
+			item.Enclosures = append(item.Enclosures, rssEnclosure{URL: a, Type: mimeType(a), Length: meta.Lengths[a]})
+		}
+		doc.Channel.Items = append(doc.Channel.Items, item)
+	}
+	return writeXML(w, doc)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"feed"`
+	Xmlns   string      `xml:"xmlns,attr"`
+	ID      string      `xml:"id"`
+	Title   string      `xml:"title"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href   string `xml:"href,attr"`
+	Rel    string `xml:"rel,attr,omitempty"`
+	Type   string `xml:"type,attr,omitempty"`
+	Length int64  `xml:"length,attr,omitempty"`
+}
+
+type atomEntry struct {
+	ID       string        `xml:"id"`
+	Title    string        `xml:"title"`
+	Updated  string        `xml:"updated"`
+	Author   atomAuthor    `xml:"author"`
+	Category *atomCategory `xml:"category"`
+	Links    []atomLink    `xml:"link"`
+	Content  atomContent   `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// WriteAtom writes the notices as Atom document.
+func WriteAtom(w io.Writer, meta Meta, notices []portal.Notice) error {
+	doc := atomFeed{
+		Xmlns:   atomNamespace,
+		ID:      meta.Link,
+		Title:   meta.Title,
+		Updated: updated(notices).Format(time.RFC3339),
+		Links:   []atomLink{{Href: meta.Link}},
+	}
+	if meta.FeedURL != "" {
+		doc.ID = meta.FeedURL
+		doc.Links = append(doc.Links, atomLink{Href: meta.FeedURL, Rel: "self"})
+	}
+	for i := range notices {
+		n := &notices[i]
+		entry := atomEntry{
+			ID:      idPrefix + n.ID(),
+			Title:   title(n),
+			Updated: n.Date.Format(time.RFC3339),
+			Author:  atomAuthor{Name: n.Office},
+			Links:   []atomLink{{Href: link(meta, n)}},
+			Content: atomContent{Type: "text", Value: n.Body},
+		}
+		if n.Category != "" {
+			entry.Category = &atomCategory{Term: n.Category}
+		}
+		for _, a := range n.Attachments {
+			entry.Links = append(entry.Links, atomLink{Href: a, Rel: "enclosure", Type: mimeType(a), Length: meta.Lengths[a]})
+		}
+		doc.Entries = append(doc.Entries, entry)
+	}
+	return writeXML(w, doc)
+}
+
+func writeXML(w io.Writer, doc interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	return enc.Encode(doc)
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string               `json:"id"`
+	URL           string               `json:"url,omitempty"`
+	Title         string               `json:"title"`
+	ContentText   string               `json:"content_text"`
+	DatePublished string               `json:"date_published"`
+	Tags          []string             `json:"tags,omitempty"`
+	Authors       []jsonFeedAuthor     `json:"authors,omitempty"`
+	Attachments   []jsonFeedAttachment `json:"attachments,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name"`
+}
+
+type jsonFeedAttachment struct {
+	URL         string `json:"url"`
+	MimeType    string `json:"mime_type"`
+	SizeInBytes int64  `json:"size_in_bytes,omitempty"`
+}
+
+// WriteJSONFeed writes the notices as JSON Feed version 1.1 document.
+func WriteJSONFeed(w io.Writer, meta Meta, notices []portal.Notice) error {
+	doc := jsonFeed{
+		Version:     jsonFeedVersion,
+		Title:       meta.Title,
+		HomePageURL: meta.Link,
+		FeedURL:     meta.FeedURL,
+		Description: meta.Description,
+		Items:       []jsonFeedItem{},
+	}
+	for i := range notices {
+		n := &notices[i]
+		item := jsonFeedItem{
+			ID:            idPrefix + n.ID(),
+			URL:           link(meta, n),
+			Title:         title(n),
+			ContentText:   n.Body,
+			DatePublished: n.Date.Format(time.RFC3339),
+		}
+		if n.Category != "" {
+			item.Tags = []string{n.Category}
+		}
+		if n.Office != "" {
+			item.Authors = []jsonFeedAuthor{{Name: n.Office}}
+		}
+		for _, a := range n.Attachments {
+			item.Attachments = append(item.Attachments, jsonFeedAttachment{URL: a, MimeType: mimeType(a), SizeInBytes: meta.Lengths[a]})
+		}
+		doc.Items = append(doc.Items, item)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
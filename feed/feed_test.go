@@ -0,0 +1,207 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+const samplePortal = "../samples/internal_auth.html"
+
+func check(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sampleNotices(t *testing.T) []portal.Notice {
+	f, err := os.Open(samplePortal)
+	check(t, err)
+	defer f.Close()
+	notices, err := portal.ParseNotices(f, nil)
+	check(t, err)
+	return notices
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+	notices := sampleNotices(t)
+	meta := DefaultMeta()
+	t.Run("RSS", func(t *testing.T) {
+		var buf bytes.Buffer
+		check(t, WriteRSS(&buf, meta, notices))
+		var doc rss
+		check(t, xml.Unmarshal(buf.Bytes(), &doc))
+		if len(doc.Channel.Items) != 3 || len(doc.Channel.Items[0].Enclosures) != 1 {
+			t.Errorf("Unexpected RSS document %+v", doc)
+		}
+	})
+	t.Run("Atom", func(t *testing.T) {
+		var buf bytes.Buffer
+		check(t, WriteAtom(&buf, meta, notices))
+		var doc atomFeed
+		check(t, xml.Unmarshal(buf.Bytes(), &doc))
+		if len(doc.Entries) != 3 || doc.Entries[0].Author.Name != "学務課" {
+			t.Errorf("Unexpected Atom document %+v", doc)
+		}
+	})
+	t.Run("JSON Feed", func(t *testing.T) {
+		var buf bytes.Buffer
+		check(t, WriteJSONFeed(&buf, meta, notices))
+		var doc jsonFeed
+		check(t, json.Unmarshal(buf.Bytes(), &doc))
+		if doc.Version != jsonFeedVersion || len(doc.Items) != 3 || doc.Items[1].URL != "https://example.com/" {
+			t.Errorf("Unexpected JSON Feed document %+v", doc)
+		}
+	})
+}
+
+func TestServer(t *testing.T) {
+	t.Parallel()
+	var (
+		mu    sync.Mutex
+		pages int
+	)
+	var upstream *httptest.Server
+	upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/example.pdf" {
+			w.Header().Set("Content-Length", "1234")
+			return
+		}
+		mu.Lock()
+		pages++
+		mu.Unlock()
+		// Keep the fetch in progress while the other requests arrive.
+		time.Sleep(50 * time.Millisecond)
+		b, err := ioutil.ReadFile(samplePortal)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Write([]byte(strings.Replace(string(b), "https://example.com/example.pdf", upstream.URL+"/example.pdf", -1)))
+	}))
+	defer upstream.Close()
+	s := NewServer(nil, upstream.Client())
+	s.Portal.BaseURL, _ = url.Parse(upstream.URL)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := httptest.NewRecorder()
+			s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, JSONFeedPath, nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expect: 200\nActual: %d %s\n", rec.Code, rec.Body)
+			}
+		}()
+	}
+	wg.Wait()
+	if pages != 1 {
+		t.Errorf("Expect: concurrent requests share one fetch\nActual: %d fetches\n", pages)
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, RSSPath, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expect: 200\nActual: %d %s\n", rec.Code, rec.Body)
+	}
+	var doc rss
+	check(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+	if e := doc.Channel.Items[0].Enclosures; len(e) != 1 || e[0].Length != 1234 {
+		t.Errorf("Expect: enclosure of 1234 bytes\nActual: %+v\n", e)
+	}
+	etag := rec.Header().Get("ETag")
+	lastModified := rec.Header().Get("Last-Modified")
+	if etag == "" || lastModified == "" {
+		t.Fatalf("Expect: ETag and Last-Modified\nActual: %+v\n", rec.Header())
+	}
+
+	t.Run("If-None-Match", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, RSSPath, nil)
+		req.Header.Set("If-None-Match", etag)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("Expect: 304\nActual: %d\n", rec.Code)
+		}
+	})
+	t.Run("If-Modified-Since", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, AtomPath, nil)
+		req.Header.Set("If-Modified-Since", lastModified)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		if rec.Code != http.StatusNotModified {
+			t.Errorf("Expect: 304\nActual: %d\n", rec.Code)
+		}
+	})
+	t.Run("Unknown path", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/unknown", nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("Expect: 404\nActual: %d\n", rec.Code)
+		}
+	})
+}
+
+type headMock struct {
+	mu      sync.Mutex
+	cookies map[string]string
+}
+
+func (m *headMock) RoundTrip(req *http.Request) (*http.Response, error) {
+	m.mu.Lock()
+	m.cookies[req.URL.String()] = req.Header.Get("Cookie")
+	m.mu.Unlock()
+	resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Body: ioutil.NopCloser(strings.NewReader("")), Request: req}
+	switch req.URL.Path {
+	case "/missing.pdf":
+		resp.StatusCode = http.StatusNotFound
+	default:
+		resp.ContentLength = 1234
+	}
+	return resp, nil
+}
+
+func TestMeasure(t *testing.T) {
+	t.Parallel()
+	mock := &headMock{cookies: make(map[string]string)}
+	jar, err := cookiejar.New(nil)
+	check(t, err)
+	base, _ := url.Parse("https://portal.test/")
+	jar.SetCookies(base, []*http.Cookie{{Name: "_shibsession_test", Value: "secret"}})
+	jar.SetCookies(&url.URL{Scheme: "https", Host: "other.test", Path: "/"}, []*http.Cookie{{Name: "other", Value: "1"}})
+	s := NewServer(nil, &http.Client{Jar: jar, Transport: mock})
+	s.Portal.BaseURL = base
+	notices := []portal.Notice{
+		{Attachments: []string{"https://portal.test/a.pdf", "https://other.test/b.pdf"}},
+		{Attachments: []string{"https://portal.test/a.pdf", "https://portal.test/missing.pdf"}},
+	}
+
+	lengths := s.measure(context.Background(), notices, nil)
+	if len(lengths) != 2 || lengths["https://portal.test/a.pdf"] != 1234 || lengths["https://other.test/b.pdf"] != 1234 {
+		t.Errorf("Expect: the sizes of a.pdf and b.pdf\nActual: %v\n", lengths)
+	}
+	if _, ok := lengths["https://portal.test/missing.pdf"]; ok {
+		t.Errorf("Expect: the failed size is not cached\nActual: %v\n", lengths)
+	}
+	if c := mock.cookies["https://portal.test/a.pdf"]; c != "_shibsession_test=secret" {
+		t.Errorf("Expect: the cookies are sent to the portal\nActual: %q\n", c)
+	}
+	if c := mock.cookies["https://other.test/b.pdf"]; c != "" {
+		t.Errorf("Expect: no cookies are sent to the other hosts\nActual: %q\n", c)
+	}
+}
@@ -0,0 +1,279 @@
+package feed
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+const (
+	// DefaultTTL is the duration to cache the notices fetched from the portal.
+	DefaultTTL = 10 * time.Minute
+	// RSSPath is the path to serve RSS 2.0 document.
+	RSSPath = "/rss.xml"
+	// AtomPath is the path to serve Atom document.
+	AtomPath = "/atom.xml"
+	// JSONFeedPath is the path to serve JSON Feed document.
+	JSONFeedPath = "/feed.json"
+)
+
+type format struct {
+	contentType string
+	write       func(io.Writer, Meta, []portal.Notice) error
+}
+
+var formats = map[string]format{
+	RSSPath:      {contentType: "application/rss+xml; charset=utf-8", write: WriteRSS},
+	AtomPath:     {contentType: "application/atom+xml; charset=utf-8", write: WriteAtom},
+	JSONFeedPath: {contentType: "application/feed+json; charset=utf-8", write: WriteJSONFeed},
+}
+
+// Server serves the feeds of the notices over HTTP.
+// The notices are cached for TTL, and the server logs in again with Auth when the session has been expired.
+// Concurrent requests with a stale cache share one fetch.
+type Server struct {
+	Portal *portal.Client
+	// Auth is used to login before the first fetch and after the session expires. It is optional.
+	Auth kitwalk.Auth
+	Meta Meta
+	TTL  time.Duration
+
+	mu        sync.Mutex
+	loggedIn  bool
+	notices   []portal.Notice
+	lengths   map[string]int64
+	digest    string
+	fetchedAt time.Time
+	changedAt time.Time
+	// pending is the fetch in progress.
+	pending *fetchCall
+}
+
+// snapshot is the cached notices and their state.
+type snapshot struct {
+	notices   []portal.Notice
+	lengths   map[string]int64
+	digest    string
+	changedAt time.Time
+}
+
+type fetchCall struct {
+	done chan struct{}
+	snap snapshot
+	err  error
+}
+
+// NewServer create new feed server which logs in with given authenticator and client.
+func NewServer(auth kitwalk.Auth, client *http.Client) *Server {
+	return &Server{
+		Portal: portal.NewClient(client),
+		Auth:   auth,
+		Meta:   DefaultMeta(),
+		TTL:    DefaultTTL,
+	}
+}
+
+// ServeHTTP serves RSSPath, AtomPath and JSONFeedPath.
+// Conditional requests with If-None-Match and If-Modified-Since are answered with 304.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f, ok := formats[r.URL.Path]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	snap, err := s.load(r.Context())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	meta := s.Meta
+	meta.Lengths = snap.lengths
+	var buf bytes.Buffer
+	if err := f.write(&buf, meta, snap.notices); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", f.contentType)
+	w.Header().Set("ETag", `"`+snap.digest+`-`+r.URL.Path[1:]+`"`)
+	http.ServeContent(w, r, "", snap.changedAt, bytes.NewReader(buf.Bytes()))
+}
+
+// load returns cached notices, or fetches them when the cache is stale.
+// The lock is held only around the cache, and the requests arriving during a fetch wait for it.
+func (s *Server) load(ctx context.Context) (snapshot, error) {
+	ttl := s.TTL
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	for {
+		s.mu.Lock()
+		if !s.fetchedAt.IsZero() && time.Since(s.fetchedAt) < ttl {
+			snap := snapshot{notices: s.notices, lengths: s.lengths, digest: s.digest, changedAt: s.changedAt}
+			s.mu.Unlock()
+			return snap, nil
+		}
+		call := s.pending
+		if call == nil {
+			call = &fetchCall{done: make(chan struct{})}
+			s.pending = call
+			s.mu.Unlock()
+			s.update(ctx, call)
+			return call.snap, call.err
+		}
+		s.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return snapshot{}, ctx.Err()
+		case <-call.done:
+		}
+		// The fetch of the request which has gone is done again with this request.
+		if call.err != nil && ctx.Err() == nil &&
+			(errors.Is(call.err, context.Canceled) || errors.Is(call.err, context.DeadlineExceeded)) {
+			continue
+		}
+		return call.snap, call.err
+	}
+}
+
+// update fetches the notices for the call, and caches them.
+func (s *Server) update(ctx context.Context, call *fetchCall) {
+	defer close(call.done)
+	notices, err := s.fetch(ctx)
+	var lengths map[string]int64
+	if err == nil {
+		s.mu.Lock()
+		known := s.lengths
+		s.mu.Unlock()
+		lengths = s.measure(ctx, notices, known)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.pending = nil
+	if err != nil {
+		call.err = err
+		return
+	}
+	if d := digestOf(notices); d != s.digest {
+		s.digest = d
+		s.changedAt = time.Now()
+	}
+	s.notices = notices
+	s.lengths = lengths
+	s.fetchedAt = time.Now()
+	call.snap = snapshot{notices: s.notices, lengths: s.lengths, digest: s.digest, changedAt: s.changedAt}
+}
+
+// fetch fetches the notices. Only one fetch runs at a time.
+func (s *Server) fetch(ctx context.Context) ([]portal.Notice, error) {
+	if s.Auth != nil && !s.loggedIn {
+		if err := s.Auth.LoginWith(kitwalk.WithContext(ctx, s.Portal.HTTPClient)); err != nil {
+			return nil, err
+		}
+		s.loggedIn = true
+	}
+	notices, err := s.Portal.Notices(ctx)
+	if _, ok := err.(*kitwalk.SessionExpiredError); ok && s.Auth != nil {
+		if err := s.Auth.LoginWith(kitwalk.WithContext(ctx, s.Portal.HTTPClient)); err != nil {
+			return nil, err
+		}
+		return s.Portal.Notices(ctx)
+	}
+	return notices, err
+}
+
+// maxHeads is the number of HEAD requests to measure the attachments at a time.
+const maxHeads = 4
+
+// measure returns the sizes of the attachments for the enclosures. The known sizes are reused,
+// and the others are asked with HEAD requests, maxHeads at a time. The sizes which cannot be known
+// are left out, so that they are asked again in the next fetch.
+func (s *Server) measure(ctx context.Context, notices []portal.Notice, known map[string]int64) map[string]int64 {
+	lengths := make(map[string]int64)
+	var unknown []string
+	asked := make(map[string]bool)
+	for i := range notices {
+		for _, a := range notices[i].Attachments {
+			if n, ok := known[a]; ok {
+				lengths[a] = n
+				continue
+			}
+			if !asked[a] {
+				asked[a] = true
+				unknown = append(unknown, a)
+			}
+		}
+	}
+	var (
+		mu  sync.Mutex
+		wg  sync.WaitGroup
+		sem = make(chan struct{}, maxHeads)
+	)
+	for _, a := range unknown {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(a string) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			if n := s.contentLength(ctx, a); n > 0 {
+				mu.Lock()
+				lengths[a] = n
+				mu.Unlock()
+			}
+		}(a)
+	}
+	wg.Wait()
+	return lengths
+}
+
+// contentLength asks the size of the attachment. The cookies of the portal are sent only to the portal,
+// and the attachments on the other hosts are asked without them.
+func (s *Server) contentLength(ctx context.Context, rawurl string) int64 {
+	u, err := url.Parse(rawurl)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return 0
+	}
+	client := s.Portal.HTTPClient
+	if !strings.EqualFold(u.Host, s.Portal.BaseURL.Host) {
+		client = &http.Client{Transport: client.Transport, Timeout: client.Timeout}
+	}
+	req, err := http.NewRequest(http.MethodHead, rawurl, nil)
+	if err != nil {
+		return 0
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return 0
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || resp.ContentLength < 0 {
+		return 0
+	}
+	return resp.ContentLength
+}
+
+func digestOf(notices []portal.Notice) string {
+	h := sha1.New()
+	for i := range notices {
+		io.WriteString(h, notices[i].Fingerprint())
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
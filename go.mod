@@ -4,6 +4,9 @@ go 1.13
 
 require (
 	github.com/PuerkitoBio/goquery v1.4.1
-	github.com/andybalholm/cascadia v1.0.0
+	github.com/andybalholm/cascadia v1.0.0 // indirect
+	github.com/ledongthuc/pdf v0.0.0-20240201131950-da5b75280b06
+	go.etcd.io/bbolt v1.3.5
 	golang.org/x/net v0.0.0-20180811021610-c39426892332
+	golang.org/x/text v0.3.7 // indirect
 )
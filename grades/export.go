@@ -0,0 +1,130 @@
+package grades
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+// csvHeader is the header of the CSV written by WriteCSV.
+var csvHeader = []string{"year", "term", "category", "code", "course", "instructor", "credits", "letter", "score", "point"}
+
+// WriteCSV writes the grades as CSV with a header. Score and point are empty when not available.
+func WriteCSV(w io.Writer, grades []portal.Grade) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, g := range grades {
+		score, point := "", ""
+		if g.Score >= 0 {
+			score = strconv.Itoa(g.Score)
+		}
+		if p, ok := Point(g); ok {
+			point = strconv.FormatFloat(p, 'f', -1, 64)
+		}
+		record := []string{
+			strconv.Itoa(g.Year), g.Term, g.Category, g.Code, g.Course, g.Instructor,
+			strconv.FormatFloat(g.Credits, 'f', -1, 64), g.Letter, score, point,
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// WriteJSON writes the grades with their summary as JSON.
+func WriteJSON(w io.Writer, grades []portal.Grade) error {
+	if grades == nil {
+		grades = []portal.Grade{}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&report{Summary: Summarize(grades), Terms: ByTerm(grades), Grades: grades})
+}
+
+// ReadJSON reads the grades written by WriteJSON, such as the result of the last fetch.
+func ReadJSON(r io.Reader) ([]portal.Grade, error) {
+	var doc report
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc.Grades, nil
+}
+
+type report struct {
+	Summary Summary        `json:"summary"`
+	Terms   []TermSummary  `json:"terms"`
+	Grades  []portal.Grade `json:"grades"`
+}
+
+// DiffType is the kind of a Diff.
+type DiffType int
+
+const (
+	// Added means a new grade appeared.
+	Added DiffType = iota + 1
+	// Changed means a grade seen before has been modified.
+	Changed
+	// Removed means a grade seen before disappeared.
+	Removed
+)
+
+func (t DiffType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Changed:
+		return "changed"
+	case Removed:
+		return "removed"
+	}
+	return fmt.Sprintf("DiffType(%d)", int(t))
+}
+
+// Diff is a difference between the grades fetched before and now.
+type Diff struct {
+	Type  DiffType
+	Grade portal.Grade
+	// Previous is the grade fetched before. It is nil when Type is Added.
+	Previous *portal.Grade
+}
+
+// Compare returns the grades added, changed and removed since the previous fetch.
+// A grade fixed after the previous fetch is reported as Changed.
+func Compare(previous, current []portal.Grade) []Diff {
+	seen := make(map[string]portal.Grade, len(previous))
+	for _, g := range previous {
+		seen[key(g)] = g
+	}
+	found := make(map[string]bool, len(current))
+	var diffs []Diff
+	for _, g := range current {
+		found[key(g)] = true
+		prev, ok := seen[key(g)]
+		switch {
+		case !ok:
+			diffs = append(diffs, Diff{Type: Added, Grade: g})
+		case prev != g:
+			p := prev
+			diffs = append(diffs, Diff{Type: Changed, Grade: g, Previous: &p})
+		}
+	}
+	for _, g := range previous {
+		if !found[key(g)] {
+			p := g
+			diffs = append(diffs, Diff{Type: Removed, Grade: g, Previous: &p})
+		}
+	}
+	return diffs
+}
+
+func key(g portal.Grade) string {
+	return strconv.Itoa(g.Year) + "\x00" + g.Term + "\x00" + g.Code + "\x00" + g.Course
+}
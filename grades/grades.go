@@ -0,0 +1,177 @@
+// Package grades computes GPA and credits from the grades on the portal, and exports them as CSV and JSON.
+package grades
+
+import (
+	"sort"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+// Points are the grade points of the letters. Both the Japanese letters and the alphabets are used in the portal.
+var Points = map[string]float64{
+	"秀": 4, "S": 4,
+	"優": 3, "A": 3,
+	"良": 2, "B": 2,
+	"可": 1, "C": 1,
+	"不可": 0, "F": 0,
+	"欠席": 0,
+}
+
+// PassLetters are the letters which earn credits without grade points, such as transferred credits.
+var PassLetters = map[string]bool{
+	"合格": true,
+	"認定": true,
+	"P":  true,
+}
+
+// Point returns the grade point of the grade. It returns false when the grade is not counted in GPA,
+// such as a pending grade or transferred credits.
+func Point(g portal.Grade) (float64, bool) {
+	p, ok := Points[g.Letter]
+	return p, ok
+}
+
+// Passed reports whether the grade earns the credits.
+func Passed(g portal.Grade) bool {
+	if PassLetters[g.Letter] {
+		return true
+	}
+	p, ok := Point(g)
+	return ok && p > 0
+}
+
+// Summary is the GPA and the credits of grades.
+type Summary struct {
+	GPA float64 `json:"gpa"`
+	// Earned is the credits of passed courses.
+	Earned float64 `json:"earned"`
+	// Attempted is the credits counted in GPA including failed courses.
+	Attempted float64 `json:"attempted"`
+}
+
+// Summarize computes the GPA and the credits. GPA is the average of the grade points weighted by credits,
+// and zero when no grade is counted.
+func Summarize(grades []portal.Grade) Summary {
+	var s Summary
+	var points float64
+	for _, g := range grades {
+		if p, ok := Point(g); ok {
+			points += p * g.Credits
+			s.Attempted += g.Credits
+		}
+		if Passed(g) {
+			s.Earned += g.Credits
+		}
+	}
+	if s.Attempted > 0 {
+		s.GPA = points / s.Attempted
+	}
+	return s
+}
+
+// GPA computes the GPA of the grades.
+func GPA(grades []portal.Grade) float64 {
+	return Summarize(grades).GPA
+}
+
+// TermSummary is the summary of a term.
+type TermSummary struct {
+	Year int    `json:"year"`
+	Term string `json:"term"`
+	Summary
+}
+
+// ByTerm summarizes the grades of each term in order of appearance.
+func ByTerm(grades []portal.Grade) []TermSummary {
+	var (
+		keys  []TermSummary
+		terms = make(map[TermSummary][]portal.Grade)
+	)
+	for _, g := range grades {
+		key := TermSummary{Year: g.Year, Term: g.Term}
+		if _, ok := terms[key]; !ok {
+			keys = append(keys, key)
+		}
+		terms[key] = append(terms[key], g)
+	}
+	summaries := make([]TermSummary, len(keys))
+	for i, key := range keys {
+		key.Summary = Summarize(terms[key])
+		summaries[i] = key
+	}
+	return summaries
+}
+
+// Latest keeps the latest attempt of each course, for the GPA which counts retaken courses once.
+// The portal lists the grades in chronological order, so the later record is taken as the latest.
+func Latest(grades []portal.Grade) []portal.Grade {
+	latest := make(map[string]int)
+	var kept []portal.Grade
+	for _, g := range grades {
+		key := g.Code
+		if key == "" {
+			key = g.Course
+		}
+		if i, ok := latest[key]; ok {
+			kept[i] = g
+			continue
+		}
+		latest[key] = len(kept)
+		kept = append(kept, g)
+	}
+	return kept
+}
+
+// Requirement is the credits required for graduation in a category.
+type Requirement struct {
+	Category string  `json:"category"`
+	Credits  float64 `json:"credits"`
+}
+
+// Progress is the credits earned toward a requirement.
+type Progress struct {
+	Requirement
+	Earned float64 `json:"earned"`
+}
+
+// Remaining returns the credits still required. It is zero when the requirement is satisfied.
+func (p *Progress) Remaining() float64 {
+	if p.Earned >= p.Credits {
+		return 0
+	}
+	return p.Credits - p.Earned
+}
+
+// Credits returns the credits earned in each category.
+func Credits(grades []portal.Grade) map[string]float64 {
+	credits := make(map[string]float64)
+	for _, g := range grades {
+		if Passed(g) {
+			credits[g.Category] += g.Credits
+		}
+	}
+	return credits
+}
+
+// Track returns the progress toward the requirements. Categories earned but not required are appended
+// with zero requirement in order of the name.
+func Track(grades []portal.Grade, requirements []Requirement) []Progress {
+	credits := Credits(grades)
+	var progress []Progress
+	required := make(map[string]bool)
+	for _, r := range requirements {
+		required[r.Category] = true
+		progress = append(progress, Progress{Requirement: r, Earned: credits[r.Category]})
+	}
+	var others []string
+	for category := range credits {
+		if !required[category] {
+			others = append(others, category)
+		}
+	}
+	sort.Strings(others)
+	for _, category := range others {
+		progress = append(progress, Progress{Requirement: Requirement{Category: category}, Earned: credits[category]})
+	}
+	return progress
+}
@@ -0,0 +1,121 @@
+package grades
+
+import (
+	"bytes"
+	"encoding/csv"
+	"math"
+	"os"
+	"testing"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func sampleGrades(t *testing.T) []portal.Grade {
+	f, err := os.Open("../samples/grades.html")
+	check(t, err)
+	defer f.Close()
+	grades, err := portal.ParseGrades(f, "table.grades")
+	check(t, err)
+	return grades
+}
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestSummarize(t *testing.T) {
+	t.Parallel()
+	grades := sampleGrades(t)
+	t.Run("Count all attempts", func(t *testing.T) {
+		s := Summarize(grades)
+		if !near(s.GPA, 16.0/9) || s.Attempted != 9 || s.Earned != 8 {
+			t.Errorf("Unexpected summary %+v", s)
+		}
+	})
+	t.Run("Count the latest attempts", func(t *testing.T) {
+		if gpa := GPA(Latest(grades)); !near(gpa, 16.0/7) {
+			t.Errorf("Expect: %f\nActual: %f\n", 16.0/7, gpa)
+		}
+	})
+	t.Run("Summarize each term", func(t *testing.T) {
+		terms := ByTerm(grades)
+		expect := []float64{2, 1.5, 2}
+		if len(terms) != len(expect) {
+			t.Fatalf("Unexpected terms %+v", terms)
+		}
+		for i, gpa := range expect {
+			if !near(terms[i].GPA, gpa) {
+				t.Errorf("Expect: %f\nActual: %+v\n", gpa, terms[i])
+			}
+		}
+	})
+	t.Run("Track requirements", func(t *testing.T) {
+		progress := Track(grades, []Requirement{{Category: "専門科目", Credits: 60}, {Category: "専門基礎科目", Credits: 4}})
+		if len(progress) != 3 {
+			t.Fatalf("Unexpected progress %+v", progress)
+		}
+		if progress[0].Earned != 2 || progress[0].Remaining() != 58 || progress[1].Remaining() != 0 {
+			t.Errorf("Unexpected progress %+v", progress[:2])
+		}
+		if progress[2].Category != "基盤教育科目" || progress[2].Earned != 2 {
+			t.Errorf("Expect: unrequired category\nActual: %+v\n", progress[2])
+		}
+	})
+}
+
+func TestExport(t *testing.T) {
+	t.Parallel()
+	grades := sampleGrades(t)
+	t.Run("Write CSV", func(t *testing.T) {
+		var b bytes.Buffer
+		check(t, WriteCSV(&b, grades))
+		records, err := csv.NewReader(&b).ReadAll()
+		check(t, err)
+		if len(records) != 8 || records[0][0] != "year" {
+			t.Fatalf("Unexpected CSV %v", records)
+		}
+		if toeic := records[5]; toeic[7] != "認定" || toeic[8] != "" || toeic[9] != "" {
+			t.Errorf("Unexpected record %v", toeic)
+		}
+		if first := records[1]; first[6] != "1" || first[8] != "95" || first[9] != "4" {
+			t.Errorf("Unexpected record %v", first)
+		}
+	})
+	t.Run("Read JSON written", func(t *testing.T) {
+		var b bytes.Buffer
+		check(t, WriteJSON(&b, grades))
+		read, err := ReadJSON(&b)
+		check(t, err)
+		if len(read) != len(grades) || read[4] != grades[4] {
+			t.Errorf("Expect: %+v\nActual: %+v\n", grades, read)
+		}
+	})
+}
+
+func TestCompare(t *testing.T) {
+	t.Parallel()
+	previous := sampleGrades(t)
+	current := append([]portal.Grade(nil), previous[1:]...)
+	current[5].Letter, current[5].Score = "優", 88
+	current = append(current, portal.Grade{Year: 2018, Term: "後期", Code: "10150101", Course: "卒業研究"})
+	diffs := Compare(previous, current)
+	expect := []DiffType{Changed, Added, Removed}
+	if len(diffs) != len(expect) {
+		t.Fatalf("Expect: %v\nActual: %+v\n", expect, diffs)
+	}
+	for i, typ := range expect {
+		if diffs[i].Type != typ {
+			t.Errorf("Expect: %s\nActual: %s\n", typ, diffs[i].Type)
+		}
+	}
+	if diffs[0].Previous.Letter != "" || diffs[0].Grade.Letter != "優" {
+		t.Errorf("Expect: fixed grade\nActual: %+v\n", diffs[0])
+	}
+}
@@ -0,0 +1,249 @@
+// Package ical writes iCalendar (RFC 5545) documents, and converts the timetable of the portal into weekly events.
+package ical
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// DefaultProdID is the product identifier written when Calendar.ProdID is empty.
+	DefaultProdID  = "-//StudioAquatan//kitwalk//JA"
+	dateTimeLayout = "20060102T150405"
+	dateLayout     = "20060102"
+	maxLineOctets  = 75
+)
+
+// Calendar is a VCALENDAR object.
+type Calendar struct {
+	ProdID string
+	// Name is shown as the name of the calendar by most clients.
+	Name   string
+	Events []Event
+}
+
+// Event is a VEVENT object. Times in UTC are written as UTC, and others with TZID of their location.
+// Locations are assumed to have a fixed offset such as Asia/Tokyo.
+type Event struct {
+	UID         string
+	Summary     string
+	Location    string
+	Description string
+	Start       time.Time
+	End         time.Time
+	// AllDay writes the times as dates with VALUE=DATE. End is the day after the last day then.
+	AllDay bool
+	// Stamp is the time the event is created. The time of writing is used when it is zero.
+	Stamp time.Time
+	// Rule repeats the event. It is optional.
+	Rule *Rule
+	// ExDates are the occurrences excluded from Rule. They have the same time of day as Start.
+	ExDates []time.Time
+	// RDates are the occurrences added to Rule.
+	RDates []time.Time
+	// RecurrenceID is the original start of the occurrence this event overrides.
+	// Such an event has the same UID as the recurring event.
+	RecurrenceID time.Time
+	// Status is one of StatusConfirmed, StatusTentative and StatusCancelled. It is optional.
+	Status string
+}
+
+// Statuses of events.
+const (
+	StatusConfirmed = "CONFIRMED"
+	StatusTentative = "TENTATIVE"
+	StatusCancelled = "CANCELLED"
+)
+
+// Frequency is the FREQ of a recurrence rule.
+type Frequency string
+
+// Frequencies of recurrence rules.
+const (
+	Daily   Frequency = "DAILY"
+	Weekly  Frequency = "WEEKLY"
+	Monthly Frequency = "MONTHLY"
+)
+
+// Rule is a recurrence rule.
+type Rule struct {
+	Freq Frequency
+	// Interval is the number of Freq between occurrences. Zero means 1.
+	Interval int
+	// Until is the inclusive end of the occurrences. It is written in UTC.
+	Until time.Time
+}
+
+func (r *Rule) String() string {
+	parts := []string{"FREQ=" + string(r.Freq)}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(dateTimeLayout)+"Z")
+	}
+	return strings.Join(parts, ";")
+}
+
+// Write writes the calendar as an iCalendar document.
+func Write(w io.Writer, cal *Calendar) error {
+	bw := bufio.NewWriter(w)
+	cw := &contentWriter{w: bw}
+	prodID := cal.ProdID
+	if prodID == "" {
+		prodID = DefaultProdID
+	}
+	cw.line("BEGIN", "VCALENDAR")
+	cw.line("VERSION", "2.0")
+	cw.line("PRODID", prodID)
+	cw.line("CALSCALE", "GREGORIAN")
+	if cal.Name != "" {
+		cw.line("X-WR-CALNAME", escape(cal.Name))
+	}
+	for _, tz := range timezones(cal.Events) {
+		name, offset := tz.Zone()
+		cw.line("BEGIN", "VTIMEZONE")
+		cw.line("TZID", tz.Location().String())
+		cw.line("BEGIN", "STANDARD")
+		cw.line("DTSTART", "19700101T000000")
+		cw.line("TZOFFSETFROM", formatOffset(offset))
+		cw.line("TZOFFSETTO", formatOffset(offset))
+		cw.line("TZNAME", name)
+		cw.line("END", "STANDARD")
+		cw.line("END", "VTIMEZONE")
+	}
+	now := time.Now()
+	for _, e := range cal.Events {
+		stamp := e.Stamp
+		if stamp.IsZero() {
+			stamp = now
+		}
+		cw.line("BEGIN", "VEVENT")
+		cw.line("UID", e.UID)
+		cw.line("DTSTAMP", stamp.UTC().Format(dateTimeLayout)+"Z")
+		when := cw.time
+		if e.AllDay {
+			when = cw.date
+		}
+		if !e.RecurrenceID.IsZero() {
+			when("RECURRENCE-ID", e.RecurrenceID)
+		}
+		when("DTSTART", e.Start)
+		when("DTEND", e.End)
+		if e.Rule != nil {
+			cw.line("RRULE", e.Rule.String())
+		}
+		for _, t := range e.ExDates {
+			when("EXDATE", t)
+		}
+		for _, t := range e.RDates {
+			when("RDATE", t)
+		}
+		cw.line("SUMMARY", escape(e.Summary))
+		if e.Location != "" {
+			cw.line("LOCATION", escape(e.Location))
+		}
+		if e.Description != "" {
+			cw.line("DESCRIPTION", escape(e.Description))
+		}
+		if e.Status != "" {
+			cw.line("STATUS", e.Status)
+		}
+		cw.line("END", "VEVENT")
+	}
+	cw.line("END", "VCALENDAR")
+	if cw.err != nil {
+		return cw.err
+	}
+	return bw.Flush()
+}
+
+// timezones returns a time in each location other than UTC used by the events.
+func timezones(events []Event) []time.Time {
+	seen := make(map[string]time.Time)
+	for _, e := range events {
+		if e.AllDay {
+			continue
+		}
+		for _, t := range []time.Time{e.Start, e.End} {
+			if t.IsZero() || t.Location() == time.UTC {
+				continue
+			}
+			if _, ok := seen[t.Location().String()]; !ok {
+				seen[t.Location().String()] = t
+			}
+		}
+	}
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	zones := make([]time.Time, len(names))
+	for i, name := range names {
+		zones[i] = seen[name]
+	}
+	return zones
+}
+
+func formatOffset(seconds int) string {
+	sign := '+'
+	if seconds < 0 {
+		sign = '-'
+		seconds = -seconds
+	}
+	return fmt.Sprintf("%c%02d%02d", sign, seconds/3600, seconds%3600/60)
+}
+
+// escape escapes a TEXT value.
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// contentWriter writes content lines folded at 75 octets, and keeps the first error.
+type contentWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (cw *contentWriter) time(name string, t time.Time) {
+	if t.Location() == time.UTC {
+		cw.line(name, t.Format(dateTimeLayout)+"Z")
+		return
+	}
+	cw.line(name+";TZID="+t.Location().String(), t.Format(dateTimeLayout))
+}
+
+// date writes the date of t in its location, ignoring the time of day.
+func (cw *contentWriter) date(name string, t time.Time) {
+	cw.line(name+";VALUE=DATE", t.Format(dateLayout))
+}
+
+func (cw *contentWriter) line(name, value string) {
+	if cw.err != nil {
+		return
+	}
+	_, cw.err = io.WriteString(cw.w, fold(name+":"+value)+"\r\n")
+}
+
+// fold splits a line longer than 75 octets without breaking UTF-8 sequences.
+func fold(line string) string {
+	var b strings.Builder
+	width := 0
+	for _, r := range line {
+		n := utf8.RuneLen(r)
+		if width+n > maxLineOctets {
+			b.WriteString("\r\n ")
+			// The leading space counts.
+			width = 1
+		}
+		b.WriteRune(r)
+		width += n
+	}
+	return b.String()
+}
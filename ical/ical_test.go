@@ -0,0 +1,165 @@
+package ical
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func jst(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, portal.JST)
+}
+
+// unfold joins the folded lines of the document.
+func unfold(doc string) []string {
+	return strings.Split(strings.Replace(doc, "\r\n ", "", -1), "\r\n")
+}
+
+func TestWrite(t *testing.T) {
+	t.Parallel()
+	stamp := time.Date(2018, time.April, 1, 0, 0, 0, 0, time.UTC)
+	cal := &Calendar{
+		Name: "test",
+		Events: []Event{{
+			UID:         "1@example.com",
+			Summary:     "会議; 準備, 確認",
+			Description: "1行目\n2行目 " + strings.Repeat("長い説明", 20),
+			Start:       jst(2018, time.April, 9).Add(9 * time.Hour),
+			End:         jst(2018, time.April, 9).Add(10 * time.Hour),
+			Stamp:       stamp,
+			Rule:        &Rule{Freq: Weekly, Interval: 2, Until: jst(2018, time.May, 1)},
+			ExDates:     []time.Time{jst(2018, time.April, 23).Add(9 * time.Hour)},
+		}},
+	}
+	var b bytes.Buffer
+	check(t, Write(&b, cal))
+	doc := b.String()
+	for _, line := range strings.Split(doc, "\r\n") {
+		if len(line) > maxLineOctets {
+			t.Errorf("Expect: lines folded at %d octets\nActual: %d octets\n", maxLineOctets, len(line))
+		}
+	}
+	lines := unfold(doc)
+	expect := []string{
+		"BEGIN:VCALENDAR",
+		"TZID:Asia/Tokyo",
+		"TZOFFSETTO:+0900",
+		"DTSTAMP:20180401T000000Z",
+		"DTSTART;TZID=Asia/Tokyo:20180409T090000",
+		"DTEND;TZID=Asia/Tokyo:20180409T100000",
+		"RRULE:FREQ=WEEKLY;INTERVAL=2;UNTIL=20180430T150000Z",
+		"EXDATE;TZID=Asia/Tokyo:20180423T090000",
+		`SUMMARY:会議\; 準備\, 確認`,
+		`DESCRIPTION:1行目\n2行目 ` + strings.Repeat("長い説明", 20),
+		"END:VCALENDAR",
+	}
+	for _, e := range expect {
+		found := false
+		for _, line := range lines {
+			if line == e {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("Expect: %s\nActual: %s\n", e, doc)
+		}
+	}
+}
+
+func TestTimetableEvents(t *testing.T) {
+	t.Parallel()
+	f, err := os.Open("../samples/timetable.html")
+	check(t, err)
+	defer f.Close()
+	tt, err := portal.ParseTimetable(f, "table.timetable", "table.timetable_other")
+	check(t, err)
+	cal := AcademicCalendar{
+		"前期": {
+			Start: jst(2018, time.April, 9),
+			End:   jst(2018, time.August, 3),
+			// Showa Day is on Monday, and the first Monday is a holiday for the entrance ceremony.
+			Holidays: []time.Time{jst(2018, time.April, 30), jst(2018, time.April, 9), jst(2018, time.July, 16)},
+		},
+		"第1クォーター": {Start: jst(2018, time.April, 9), End: jst(2018, time.June, 5)},
+		"第2クォーター": {Start: jst(2018, time.June, 6), End: jst(2018, time.August, 3)},
+	}
+
+	t.Run("Repeat weekly within terms", func(t *testing.T) {
+		events, err := TimetableEvents(tt, cal)
+		check(t, err)
+		if len(events) != 5 {
+			t.Fatalf("Expect: 5 events\nActual: %d events\n", len(events))
+		}
+		monday := events[0]
+		if !monday.Start.Equal(jst(2018, time.April, 16).Add(8*time.Hour + 50*time.Minute)) {
+			t.Errorf("Expect: first class after the holiday\nActual: %s\n", monday.Start)
+		}
+		if len(monday.ExDates) != 2 || !monday.ExDates[0].Equal(jst(2018, time.April, 30).Add(8*time.Hour+50*time.Minute)) {
+			t.Errorf("Unexpected exdates %v", monday.ExDates)
+		}
+		if !monday.Rule.Until.Equal(jst(2018, time.August, 4).Add(-time.Second)) {
+			t.Errorf("Unexpected until %s", monday.Rule.Until)
+		}
+		second := events[2]
+		if !second.Start.Equal(jst(2018, time.June, 6).Add(8*time.Hour+50*time.Minute)) || len(second.ExDates) != 0 {
+			t.Errorf("Expect: the quarter starts on Wednesday\nActual: %s %v\n", second.Start, second.ExDates)
+		}
+		lab := events[3]
+		if !lab.Start.Equal(jst(2018, time.April, 10).Add(13*time.Hour)) || !lab.End.Equal(jst(2018, time.April, 10).Add(16*time.Hour+10*time.Minute)) {
+			t.Errorf("Expect: 3rd and 4th periods\nActual: %s - %s\n", lab.Start, lab.End)
+		}
+		if lab.Location != "8-301" || lab.Description != "10130301\n佐藤 花子、鈴木 一郎" {
+			t.Errorf("Unexpected event %+v", lab)
+		}
+	})
+	t.Run("Fail on unknown term", func(t *testing.T) {
+		_, err := TimetableEvents(tt, AcademicCalendar{"前期": cal["前期"]})
+		switch err.(type) {
+		case *TermNotFoundError:
+		default:
+			t.Errorf("Expect: TermNotFoundError\nActual: %v\n", err)
+		}
+	})
+	t.Run("Write calendar", func(t *testing.T) {
+		var b bytes.Buffer
+		check(t, WriteTimetable(&b, tt, cal))
+		if !strings.Contains(b.String(), "X-WR-CALNAME:2018年度 前期 時間割\r\n") || strings.Count(b.String(), "BEGIN:VEVENT") != 5 {
+			t.Errorf("Unexpected calendar %s", b.String())
+		}
+	})
+}
+
+func TestWrite_AllDay(t *testing.T) {
+	t.Parallel()
+	cal := &Calendar{Events: []Event{{
+		UID:     "2@example.com",
+		Summary: "締切",
+		Start:   jst(2018, time.May, 15),
+		End:     jst(2018, time.May, 16),
+		AllDay:  true,
+		ExDates: []time.Time{jst(2018, time.May, 22)},
+		Rule:    &Rule{Freq: Weekly},
+	}}}
+	var b bytes.Buffer
+	check(t, Write(&b, cal))
+	doc := b.String()
+	for _, e := range []string{"DTSTART;VALUE=DATE:20180515\r\n", "DTEND;VALUE=DATE:20180516\r\n", "EXDATE;VALUE=DATE:20180522\r\n"} {
+		if !strings.Contains(doc, e) {
+			t.Errorf("Expect: %s\nActual: %s\n", e, doc)
+		}
+	}
+	if strings.Contains(doc, "VTIMEZONE") {
+		t.Errorf("Expect: no timezone for all-day events\nActual: %s\n", doc)
+	}
+}
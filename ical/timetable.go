@@ -0,0 +1,154 @@
+package ical
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+// UIDDomain is the domain part of the UIDs of the events generated by this package.
+const UIDDomain = "kitwalk.studioaquatan"
+
+// Term is the range of classes of a term in the academic calendar.
+type Term struct {
+	// Start and End are the inclusive dates of the first and the last classes.
+	Start time.Time
+	End   time.Time
+	// Holidays are the dates without classes such as national holidays and the winter vacation.
+	Holidays []time.Time
+}
+
+// AcademicCalendar maps the name of a term such as "前期" or "第1クォーター" to its range.
+type AcademicCalendar map[string]Term
+
+// TermNotFoundError will be return when the academic calendar lacks the term of a course.
+type TermNotFoundError struct {
+	course string
+	term   string
+}
+
+func (e *TermNotFoundError) Error() string {
+	return fmt.Sprintf("The academic calendar has no term '%s' of the course '%s'.", e.term, e.course)
+}
+
+// PeriodNotFoundError will be return when the timetable lacks the time of a period of a course.
+type PeriodNotFoundError struct {
+	course string
+	period int
+}
+
+func (e *PeriodNotFoundError) Error() string {
+	return fmt.Sprintf("The time of period %d of the course '%s' is unknown.", e.period, e.course)
+}
+
+// TimetableEvents converts the scheduled courses into weekly events repeated within their terms.
+// Holidays of the term are excluded from the occurrences. Intensive courses without fixed time are skipped.
+func TimetableEvents(tt *portal.Timetable, cal AcademicCalendar) ([]Event, error) {
+	var events []Event
+	for _, c := range tt.Courses {
+		if !c.Scheduled() {
+			continue
+		}
+		term, ok := cal[c.Term]
+		if !ok {
+			return nil, &TermNotFoundError{course: c.Title, term: c.Term}
+		}
+		length := c.Length
+		if length < 1 {
+			length = 1
+		}
+		first, ok := tt.Period(c.Period)
+		if !ok {
+			return nil, &PeriodNotFoundError{course: c.Title, period: c.Period}
+		}
+		last, ok := tt.Period(c.Period + length - 1)
+		if !ok {
+			return nil, &PeriodNotFoundError{course: c.Title, period: c.Period + length - 1}
+		}
+
+		day := date(term.Start)
+		for day.Weekday() != c.Day {
+			day = day.AddDate(0, 0, 1)
+		}
+		end := date(term.End)
+		if day.After(end) {
+			continue
+		}
+		// Skip the holidays at the beginning, so that the first occurrence is an actual class.
+		holidays := holidaySet(term.Holidays)
+		for holidays[day.Format(dateTimeLayout)] && !day.After(end) {
+			day = day.AddDate(0, 0, 7)
+		}
+		if day.After(end) {
+			continue
+		}
+		e := Event{
+			UID:         CourseUID(tt.Year, c),
+			Summary:     c.Title,
+			Location:    c.Room,
+			Description: description(c),
+			Start:       day.Add(first.Start),
+			End:         day.Add(last.End),
+			Rule:        &Rule{Freq: Weekly, Until: end.AddDate(0, 0, 1).Add(-time.Second)},
+		}
+		for _, h := range sortedDates(term.Holidays) {
+			if h.Weekday() == c.Day && h.After(day) && !h.After(end) {
+				e.ExDates = append(e.ExDates, h.Add(first.Start))
+			}
+		}
+		events = append(events, e)
+	}
+	return events, nil
+}
+
+// CourseUID returns the UID of the weekly event of the course.
+func CourseUID(year int, c portal.Course) string {
+	return fmt.Sprintf("%d-%s-%d-%d@%s", year, c.Code, c.Day, c.Period, UIDDomain)
+}
+
+// WriteTimetable writes the timetable as an iCalendar document.
+func WriteTimetable(w io.Writer, tt *portal.Timetable, cal AcademicCalendar) error {
+	events, err := TimetableEvents(tt, cal)
+	if err != nil {
+		return err
+	}
+	name := "時間割"
+	if tt.Year != 0 {
+		name = fmt.Sprintf("%d年度 %s 時間割", tt.Year, tt.Term)
+	}
+	return Write(w, &Calendar{Name: name, Events: events})
+}
+
+func description(c portal.Course) string {
+	s := c.Code
+	if c.Instructor != "" {
+		s += "\n" + c.Instructor
+	}
+	return s
+}
+
+// date returns the midnight of the day in JST.
+func date(t time.Time) time.Time {
+	t = t.In(portal.JST)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, portal.JST)
+}
+
+func holidaySet(holidays []time.Time) map[string]bool {
+	set := make(map[string]bool, len(holidays))
+	for _, h := range holidays {
+		set[date(h).Format(dateTimeLayout)] = true
+	}
+	return set
+}
+
+func sortedDates(dates []time.Time) []time.Time {
+	sorted := make([]time.Time, len(dates))
+	for i, d := range dates {
+		sorted[i] = date(d)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+	return sorted
+}
@@ -0,0 +1,26 @@
+// Package text has the helpers to compare and clean up the texts scraped from the pages of the university.
+package text
+
+import "strings"
+
+// CollapseSpaces replaces each run of spaces, including the full-width ones and line breaks, with a space,
+// and trims the spaces at both ends.
+func CollapseSpaces(s string) string {
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// Normalize removes spaces including the full-width ones, such as in "山田　太郎",
+// to compare the names written with and without them.
+func Normalize(s string) string {
+	return strings.Join(strings.Fields(s), "")
+}
+
+// Contains reports whether the list has s.
+func Contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,16 @@
+package text
+
+import "testing"
+
+func TestHelpers(t *testing.T) {
+	t.Parallel()
+	if s := CollapseSpaces(" 線形代数学　I\n 山田 太郎 "); s != "線形代数学 I 山田 太郎" {
+		t.Errorf("Expect: 線形代数学 I 山田 太郎\nActual: %q\n", s)
+	}
+	if s := Normalize(" 山田　太郎\n"); s != "山田太郎" {
+		t.Errorf("Expect: 山田太郎\nActual: %q\n", s)
+	}
+	if !Contains([]string{"前期", "後期"}, "後期") || Contains(nil, "前期") {
+		t.Error("Expect: Contains finds only the elements of the list")
+	}
+}
@@ -0,0 +1,313 @@
+package kitwalk
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CookieEvent is the kind of change in Jar.
+type CookieEvent int
+
+const (
+	// CookieSet means that a cookie is added or replaced.
+	CookieSet CookieEvent = iota
+	// CookieDeleted means that a cookie is deleted by the server or Jar.Delete.
+	CookieDeleted
+	// CookieExpired means that an expired cookie is dropped.
+	CookieExpired
+)
+
+func (e CookieEvent) String() string {
+	switch e {
+	case CookieSet:
+		return "set"
+	case CookieDeleted:
+		return "deleted"
+	case CookieExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// CookieChange is a change of a cookie in Jar.
+type CookieChange struct {
+	Event  CookieEvent
+	Cookie *http.Cookie
+}
+
+type jarEntry struct {
+	cookie *http.Cookie
+	// seq is the order of creation.
+	seq uint64
+}
+
+// Jar is an http.CookieJar which can list, inspect and delete its cookies.
+// Cookies returned by its methods other than Cookies have their attributes:
+// Domain starts with '.' for domain cookies, and it is the host for host-only cookies.
+//
+// Public suffixes are not checked, so the jar should be used only for trusted websites.
+type Jar struct {
+	// OnChange is called after cookies are changed. It must be set before the jar is used.
+	OnChange func(CookieChange)
+
+	mu      sync.Mutex
+	entries []*jarEntry
+	seq     uint64
+	now     func() time.Time
+}
+
+// NewJar create new empty cookie jar. The zero value of Jar is also usable.
+func NewJar() *Jar {
+	return &Jar{now: time.Now}
+}
+
+// SetCookies stores the cookies received from given url.
+func (j *Jar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return
+	}
+	now := j.clock()
+	var changes []CookieChange
+	j.mu.Lock()
+	for _, c := range cookies {
+		n := normalizeCookie(u, c, now)
+		if !domainMatch(u.Hostname(), n) {
+			continue
+		}
+		old := j.remove(n.Name, n.Domain, n.Path)
+		if isExpired(n, now) {
+			if old != nil {
+				changes = append(changes, CookieChange{Event: CookieDeleted, Cookie: copyCookie(old.cookie)})
+			}
+			continue
+		}
+		e := &jarEntry{cookie: n, seq: j.seq}
+		j.seq++
+		if old != nil {
+			// Keep the creation time to keep the order of cookies. See RFC 6265 section 5.3.
+			e.seq = old.seq
+		}
+		j.entries = append(j.entries, e)
+		changes = append(changes, CookieChange{Event: CookieSet, Cookie: copyCookie(n)})
+	}
+	changes = append(changes, j.expire(now)...)
+	j.mu.Unlock()
+	j.notify(changes)
+}
+
+// Cookies returns the cookies to send to given url, as http.CookieJar.
+// Only Name and Value are set.
+func (j *Jar) Cookies(u *url.URL) []*http.Cookie {
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil
+	}
+	now := j.clock()
+	j.mu.Lock()
+	changes := j.expire(now)
+	var matched []*jarEntry
+	for _, e := range j.entries {
+		if domainMatch(u.Hostname(), e.cookie) && pathMatch(u.EscapedPath(), e.cookie.Path) && (!e.cookie.Secure || u.Scheme == "https") {
+			matched = append(matched, e)
+		}
+	}
+	j.mu.Unlock()
+	j.notify(changes)
+
+	sort.Slice(matched, func(a, b int) bool {
+		if len(matched[a].cookie.Path) != len(matched[b].cookie.Path) {
+			return len(matched[a].cookie.Path) > len(matched[b].cookie.Path)
+		}
+		return matched[a].seq < matched[b].seq
+	})
+	cookies := make([]*http.Cookie, 0, len(matched))
+	for _, e := range matched {
+		cookies = append(cookies, &http.Cookie{Name: e.cookie.Name, Value: e.cookie.Value})
+	}
+	return cookies
+}
+
+// All returns all cookies which are not expired, in the order they were set.
+func (j *Jar) All() []*http.Cookie {
+	return j.filter(func(*http.Cookie) bool { return true })
+}
+
+// Domain returns the cookies which are sent to given host regardless of the path.
+func (j *Jar) Domain(host string) []*http.Cookie {
+	return j.filter(func(c *http.Cookie) bool { return domainMatch(host, c) })
+}
+
+// Lookup returns the cookie of given name which is sent to given host.
+// When several cookies match, the one with the longest path is returned.
+func (j *Jar) Lookup(name, host string) (*http.Cookie, bool) {
+	var found *http.Cookie
+	for _, c := range j.Domain(host) {
+		if c.Name == name && (found == nil || len(c.Path) > len(found.Path)) {
+			found = c
+		}
+	}
+	return found, found != nil
+}
+
+// Expires returns when the cookie of given name for given host expires.
+// The zero time is returned for session cookies, and false when the cookie does not exist.
+func (j *Jar) Expires(name, host string) (time.Time, bool) {
+	c, ok := j.Lookup(name, host)
+	if !ok {
+		return time.Time{}, false
+	}
+	return c.Expires, true
+}
+
+// Delete removes the cookies of given name whose domain is given domain with or without the leading '.'.
+// All cookies of the domain are removed when name is empty.
+// It returns the number of removed cookies.
+func (j *Jar) Delete(name, domain string) int {
+	domain = strings.TrimPrefix(strings.ToLower(domain), ".")
+	var changes []CookieChange
+	j.mu.Lock()
+	kept := j.entries[:0]
+	for _, e := range j.entries {
+		if strings.TrimPrefix(e.cookie.Domain, ".") == domain && (name == "" || e.cookie.Name == name) {
+			changes = append(changes, CookieChange{Event: CookieDeleted, Cookie: copyCookie(e.cookie)})
+			continue
+		}
+		kept = append(kept, e)
+	}
+	j.entries = kept
+	j.mu.Unlock()
+	j.notify(changes)
+	return len(changes)
+}
+
+// Clear removes all cookies.
+func (j *Jar) Clear() {
+	j.mu.Lock()
+	changes := make([]CookieChange, 0, len(j.entries))
+	for _, e := range j.entries {
+		changes = append(changes, CookieChange{Event: CookieDeleted, Cookie: copyCookie(e.cookie)})
+	}
+	j.entries = nil
+	j.mu.Unlock()
+	j.notify(changes)
+}
+
+// Snapshot returns copies of all cookies, which can be given to Restore later.
+func (j *Jar) Snapshot() []*http.Cookie {
+	return j.All()
+}
+
+// Restore replaces all cookies with given ones taken by Snapshot.
+// Cookies already expired are ignored.
+func (j *Jar) Restore(cookies []*http.Cookie) {
+	j.Clear()
+	for _, c := range cookies {
+		cookie := *c
+		if isHostOnly(c) {
+			cookie.Domain = ""
+		}
+		j.SetCookies(cookieURL(c), []*http.Cookie{&cookie})
+	}
+}
+
+func (j *Jar) filter(f func(*http.Cookie) bool) []*http.Cookie {
+	now := j.clock()
+	j.mu.Lock()
+	changes := j.expire(now)
+	entries := append([]*jarEntry(nil), j.entries...)
+	j.mu.Unlock()
+	j.notify(changes)
+
+	sort.Slice(entries, func(a, b int) bool { return entries[a].seq < entries[b].seq })
+	var cookies []*http.Cookie
+	for _, e := range entries {
+		if f(e.cookie) {
+			cookies = append(cookies, copyCookie(e.cookie))
+		}
+	}
+	return cookies
+}
+
+// remove drops the entry of the same name, domain and path. j.mu must be held.
+func (j *Jar) remove(name, domain, path string) *jarEntry {
+	for i, e := range j.entries {
+		if e.cookie.Name == name && e.cookie.Domain == domain && e.cookie.Path == path {
+			j.entries = append(j.entries[:i], j.entries[i+1:]...)
+			return e
+		}
+	}
+	return nil
+}
+
+// expire drops expired entries. j.mu must be held.
+func (j *Jar) expire(now time.Time) []CookieChange {
+	var changes []CookieChange
+	kept := j.entries[:0]
+	for _, e := range j.entries {
+		if isExpired(e.cookie, now) {
+			changes = append(changes, CookieChange{Event: CookieExpired, Cookie: copyCookie(e.cookie)})
+			continue
+		}
+		kept = append(kept, e)
+	}
+	j.entries = kept
+	return changes
+}
+
+func (j *Jar) notify(changes []CookieChange) {
+	if j.OnChange == nil {
+		return
+	}
+	for _, c := range changes {
+		j.OnChange(c)
+	}
+}
+
+func isExpired(c *http.Cookie, now time.Time) bool {
+	return !c.Expires.IsZero() && !c.Expires.After(now)
+}
+
+func copyCookie(c *http.Cookie) *http.Cookie {
+	copied := *c
+	return &copied
+}
+
+// domainMatch reports whether the cookie normalized by normalizeCookie is sent to given host.
+// See RFC 6265 section 5.1.3.
+func domainMatch(host string, c *http.Cookie) bool {
+	host = strings.ToLower(host)
+	domain := strings.ToLower(c.Domain)
+	if isHostOnly(c) {
+		return host == domain
+	}
+	domain = strings.TrimPrefix(domain, ".")
+	if host == domain {
+		return true
+	}
+	return net.ParseIP(host) == nil && strings.HasSuffix(host, "."+domain)
+}
+
+// pathMatch reports whether the request path matches the cookie path. See RFC 6265 section 5.1.4.
+func pathMatch(requestPath, cookiePath string) bool {
+	if requestPath == "" {
+		requestPath = "/"
+	}
+	if requestPath == cookiePath {
+		return true
+	}
+	if !strings.HasPrefix(requestPath, cookiePath) {
+		return false
+	}
+	return strings.HasSuffix(cookiePath, "/") || requestPath[len(cookiePath)] == '/'
+}
+
+func (j *Jar) clock() time.Time {
+	if j.now == nil {
+		return time.Now()
+	}
+	return j.now()
+}
@@ -0,0 +1,122 @@
+package kitwalk
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func mustParse(t *testing.T, rawurl string) *url.URL {
+	u, err := url.Parse(rawurl)
+	check(t, err)
+	return u
+}
+
+func TestJar(t *testing.T) {
+	t.Parallel()
+	idp := mustParse(t, "https://auth.cis.kit.ac.jp/idp/profile/SAML2/Redirect/SSO")
+	portal := mustParse(t, "https://portal.student.kit.ac.jp/ead/")
+
+	t.Run("Send cookies matching domain, path and scheme", func(t *testing.T) {
+		jar := NewJar()
+		jar.SetCookies(idp, []*http.Cookie{{Name: "shib_idp_session", Value: "idp", Path: "/idp", Secure: true}})
+		jar.SetCookies(portal, []*http.Cookie{
+			{Name: "_shibsession_test", Value: "sp", Path: "/"},
+			{Name: "shared", Value: "kit", Domain: "kit.ac.jp", Path: "/"},
+			{Name: "other", Value: "rejected", Domain: "example.com"},
+		})
+		cases := []struct {
+			url    string
+			expect []string
+		}{
+			{"https://auth.cis.kit.ac.jp/idp/Authn", []string{"shib_idp_session", "shared"}},
+			{"http://auth.cis.kit.ac.jp/idp/Authn", []string{"shared"}},
+			{"https://auth.cis.kit.ac.jp/idpx", []string{"shared"}},
+			{"https://portal.student.kit.ac.jp/", []string{"_shibsession_test", "shared"}},
+			{"https://sub.portal.student.kit.ac.jp/", []string{"shared"}},
+		}
+		for _, c := range cases {
+			var names []string
+			for _, cookie := range jar.Cookies(mustParse(t, c.url)) {
+				names = append(names, cookie.Name)
+			}
+			if len(names) != len(c.expect) {
+				t.Errorf("%s\nExpect: %v\nActual: %v\n", c.url, c.expect, names)
+				continue
+			}
+			for i := range names {
+				if names[i] != c.expect[i] {
+					t.Errorf("%s\nExpect: %v\nActual: %v\n", c.url, c.expect, names)
+				}
+			}
+		}
+	})
+	t.Run("List, look up and delete cookies", func(t *testing.T) {
+		jar := NewJar()
+		jar.SetCookies(idp, []*http.Cookie{{Name: "shib_idp_session", Value: "idp", Path: "/idp", MaxAge: 3600}})
+		jar.SetCookies(portal, []*http.Cookie{{Name: "_shibsession_test", Value: "sp", Path: "/"}})
+		if cookies := jar.All(); len(cookies) != 2 {
+			t.Fatalf("Expect: 2 cookies\nActual: %+v\n", cookies)
+		}
+		cookies := jar.Domain("auth.cis.kit.ac.jp")
+		if len(cookies) != 1 || cookies[0].Domain != "auth.cis.kit.ac.jp" || cookies[0].Path != "/idp" {
+			t.Fatalf("Unexpected cookies %+v", cookies)
+		}
+		expires, ok := jar.Expires("shib_idp_session", "auth.cis.kit.ac.jp")
+		if !ok || expires.Before(time.Now().Add(59*time.Minute)) {
+			t.Errorf("Unexpected expiry %v", expires)
+		}
+		if expires, ok := jar.Expires("_shibsession_test", "portal.student.kit.ac.jp"); !ok || !expires.IsZero() {
+			t.Errorf("Expect: session cookie\nActual: %v\n", expires)
+		}
+		if n := jar.Delete("", "portal.student.kit.ac.jp"); n != 1 {
+			t.Errorf("Expect: 1\nActual: %d\n", n)
+		}
+		if _, ok := jar.Lookup("_shibsession_test", "portal.student.kit.ac.jp"); ok {
+			t.Error("Expect: deleted\nActual: found")
+		}
+		if _, ok := jar.Lookup("shib_idp_session", "auth.cis.kit.ac.jp"); !ok {
+			t.Error("Expect: found\nActual: deleted")
+		}
+	})
+	t.Run("Snapshot and restore cookies", func(t *testing.T) {
+		jar := NewJar()
+		jar.SetCookies(idp, []*http.Cookie{{Name: "shib_idp_session", Value: "idp", Path: "/idp", Secure: true}})
+		jar.SetCookies(portal, []*http.Cookie{{Name: "shared", Value: "kit", Domain: ".kit.ac.jp", Path: "/"}})
+		snapshot := jar.Snapshot()
+		jar.Clear()
+		if cookies := jar.All(); len(cookies) != 0 {
+			t.Fatalf("Expect: no cookies\nActual: %+v\n", cookies)
+		}
+		restored := NewJar()
+		restored.Restore(snapshot)
+		cookies := restored.Cookies(mustParse(t, "https://auth.cis.kit.ac.jp/idp/"))
+		if len(cookies) != 2 {
+			t.Errorf("Unexpected cookies %+v", cookies)
+		}
+		if cookies := restored.Cookies(mustParse(t, "https://www.kit.ac.jp/")); len(cookies) != 1 || cookies[0].Value != "kit" {
+			t.Errorf("Unexpected cookies %+v", cookies)
+		}
+	})
+	t.Run("Notify changes", func(t *testing.T) {
+		var changes []CookieChange
+		now := time.Now()
+		jar := NewJar()
+		jar.now = func() time.Time { return now }
+		jar.OnChange = func(c CookieChange) { changes = append(changes, c) }
+		jar.SetCookies(portal, []*http.Cookie{{Name: "a", Value: "1"}, {Name: "b", Value: "2", MaxAge: 60}})
+		jar.SetCookies(portal, []*http.Cookie{{Name: "a", Value: "", MaxAge: -1}})
+		now = now.Add(time.Hour)
+		jar.All()
+		expect := []CookieEvent{CookieSet, CookieSet, CookieDeleted, CookieExpired}
+		if len(changes) != len(expect) {
+			t.Fatalf("Expect: %v\nActual: %+v\n", expect, changes)
+		}
+		for i, c := range changes {
+			if c.Event != expect[i] {
+				t.Errorf("Expect: %v\nActual: %v\n", expect[i], c.Event)
+			}
+		}
+	})
+}
@@ -0,0 +1,342 @@
+package kitwalk
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultKeepAliveInterval is the interval of probes used by Keeper when zero is given.
+	DefaultKeepAliveInterval = 10 * time.Minute
+	// DefaultRefreshMargin is how long before the expiry Keeper logs in again when zero is given.
+	DefaultRefreshMargin = 10 * time.Minute
+	// DefaultMinBackoff is the first wait after a failure used by Keeper when zero is given.
+	DefaultMinBackoff = 30 * time.Second
+	// DefaultMaxBackoff is the longest wait after failures used by Keeper when zero is given.
+	DefaultMaxBackoff = 30 * time.Minute
+)
+
+// KeeperState is the state of Keeper.
+type KeeperState int
+
+const (
+	// KeeperStarting means that the session has not been checked yet.
+	KeeperStarting KeeperState = iota
+	// KeeperHealthy means that the session was valid at the last check.
+	KeeperHealthy
+	// KeeperRefreshing means that Keeper is logging in.
+	KeeperRefreshing
+	// KeeperBackoff means that the last check or login failed, and Keeper is waiting to retry.
+	KeeperBackoff
+	// KeeperStopped means that Run has returned.
+	KeeperStopped
+)
+
+func (s KeeperState) String() string {
+	switch s {
+	case KeeperStarting:
+		return "starting"
+	case KeeperHealthy:
+		return "healthy"
+	case KeeperRefreshing:
+		return "refreshing"
+	case KeeperBackoff:
+		return "backoff"
+	case KeeperStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// Keeper keeps the session of the client alive for long-running processes.
+// It probes the session periodically, which also prevents the inactivity timeout of the website,
+// refreshes it before the estimated expiry or after the session is lost, by single sign-on while the auth server
+// has the session and by logging in with Auth otherwise,
+// and retries with jittered exponential backoff when the auth server fails.
+type Keeper struct {
+	Auth   Auth
+	Client *http.Client
+	// Interval is the interval of probes.
+	Interval time.Duration
+	// RefreshMargin is how long before the estimated expiry Keeper logs in again.
+	RefreshMargin time.Duration
+	// MinBackoff and MaxBackoff bound the wait after failures.
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// OnError is called when a check or login fails.
+	OnError func(error)
+
+	mu        sync.Mutex
+	state     KeeperState
+	lastErr   error
+	lastCheck time.Time
+	expiresAt time.Time
+	backoff   time.Duration
+	now       func() time.Time
+}
+
+// NewKeeper create new keeper of the session of given client.
+func NewKeeper(auth Auth, client *http.Client) *Keeper {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return &Keeper{
+		Auth:          auth,
+		Client:        client,
+		Interval:      DefaultKeepAliveInterval,
+		RefreshMargin: DefaultRefreshMargin,
+		MinBackoff:    DefaultMinBackoff,
+		MaxBackoff:    DefaultMaxBackoff,
+		now:           time.Now,
+	}
+}
+
+// State returns the current state.
+func (k *Keeper) State() KeeperState {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.state
+}
+
+// LastError returns the error of the last check or login, or nil when it succeeded.
+func (k *Keeper) LastError() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.lastErr
+}
+
+// LastCheck returns when the session was checked or refreshed last time.
+func (k *Keeper) LastCheck() time.Time {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.lastCheck
+}
+
+// ExpiresAt returns the estimated expiry of the session. It is zero when unknown.
+func (k *Keeper) ExpiresAt() time.Time {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.expiresAt
+}
+
+// Run keeps the session until the context is canceled, and returns the error of the context.
+// It must not be called concurrently.
+func (k *Keeper) Run(ctx context.Context) error {
+	defer k.setState(KeeperStopped)
+	for {
+		wait := k.step(ctx)
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// step checks or refreshes the session once, and returns the wait until the next step.
+func (k *Keeper) step(ctx context.Context) time.Duration {
+	k.mu.Lock()
+	refresh := !k.expiresAt.IsZero() && !k.clock().Before(k.expiresAt.Add(-k.refreshMargin()))
+	k.mu.Unlock()
+
+	var err error
+	if !refresh {
+		var status *SessionStatus
+		var known time.Time
+		if sa, ok := k.Auth.(*SamlAuthenticator); ok {
+			known = sa.SessionNotOnOrAfter()
+		}
+		if status, err = CheckSessionWithExpiry(ctx, k.Client, known); err == nil {
+			refresh = !status.Valid()
+			if !refresh && !status.ExpiresAt.IsZero() {
+				k.mu.Lock()
+				if k.expiresAt.IsZero() || status.ExpiresAt.Before(k.expiresAt) {
+					k.expiresAt = status.ExpiresAt
+				}
+				k.mu.Unlock()
+			}
+		}
+	}
+	if err == nil && refresh {
+		k.setState(KeeperRefreshing)
+		err = k.refresh(ctx)
+	}
+	if ctx.Err() != nil {
+		return 0
+	}
+	return k.done(err)
+}
+
+// refresh signs on with the session of the auth server in a new jar, and moves the new cookies into the jar
+// of the client, so that the client keeps sending the old session until the new one is ready.
+// It logs in with Auth into another new jar only when the auth server no longer has the session.
+func (k *Keeper) refresh(ctx context.Context) error {
+	jar, err := k.signOn(ctx)
+	sso := err == nil
+	if !sso {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		jar = NewJar()
+		loginClient := *k.Client
+		loginClient.Jar = jar
+		loginClient.CheckRedirect = nil
+		if err := k.Auth.LoginWith(WithContext(ctx, &loginClient)); err != nil {
+			return err
+		}
+	}
+	if k.Client.Jar != nil {
+		copyCookies(k.Client.Jar, jar.All())
+	}
+	now := k.clock()
+	expiresAt := now.Add(DefaultSessionLifetime)
+	if sa, ok := k.Auth.(*SamlAuthenticator); ok && !sso {
+		if t := sa.SessionNotOnOrAfter(); !t.IsZero() && t.Before(expiresAt) {
+			expiresAt = t
+		}
+	}
+	if t, _ := cookieExpiry(jar); !t.IsZero() && t.Before(expiresAt) {
+		expiresAt = t
+	}
+	k.mu.Lock()
+	k.expiresAt = expiresAt
+	k.mu.Unlock()
+	return nil
+}
+
+// signOn signs on to the website in a new jar with the cookies of the auth server in the jar of the client.
+// SessionExpiredError is returned without the cookies, when the client has never logged in or the jar is not *Jar.
+func (k *Keeper) signOn(ctx context.Context) (*Jar, error) {
+	current, ok := k.Client.Jar.(*Jar)
+	if !ok {
+		return nil, &SessionExpiredError{URL: ShibbolethLoginURL}
+	}
+	idp := current.Domain(DefaultAuthDomain)
+	if len(idp) == 0 {
+		return nil, &SessionExpiredError{URL: ShibbolethLoginURL}
+	}
+	jar := NewJar()
+	copyCookies(jar, idp)
+	ssoClient := *k.Client
+	ssoClient.Jar = jar
+	ssoClient.CheckRedirect = nil
+	if err := SingleSignOn(ctx, WithContext(ctx, &ssoClient), ShibbolethLoginURL); err != nil {
+		return nil, err
+	}
+	return jar, nil
+}
+
+// copyCookies sets the cookies taken from Jar.All to the jar.
+func copyCookies(jar http.CookieJar, cookies []*http.Cookie) {
+	for _, c := range cookies {
+		cookie := *c
+		if isHostOnly(c) {
+			cookie.Domain = ""
+		}
+		jar.SetCookies(cookieURL(c), []*http.Cookie{&cookie})
+	}
+}
+
+// done records the result of a step, and returns the wait until the next step.
+func (k *Keeper) done(err error) time.Duration {
+	k.mu.Lock()
+	k.lastErr = err
+	k.lastCheck = k.clock()
+	if err != nil {
+		k.state = KeeperBackoff
+		switch {
+		case isAuthError(err):
+			// Wrong credentials and locked accounts do not get better by retrying soon,
+			// and posting the password again may lock the account for longer.
+			k.backoff = k.maxBackoff()
+		case k.backoff == 0:
+			k.backoff = k.minBackoff()
+		default:
+			k.backoff *= 2
+		}
+		if k.backoff > k.maxBackoff() {
+			k.backoff = k.maxBackoff()
+		}
+		wait := k.backoff + time.Duration(rand.Int63n(int64(k.backoff)/2+1))
+		if e, ok := err.(*LoginLockedError); ok {
+			if until := e.Until().Sub(k.lastCheck); until > wait {
+				wait = until
+			}
+		}
+		k.mu.Unlock()
+		if k.OnError != nil {
+			k.OnError(err)
+		}
+		return wait
+	}
+	defer k.mu.Unlock()
+	k.state = KeeperHealthy
+	k.backoff = 0
+	wait := k.interval()
+	if !k.expiresAt.IsZero() {
+		if until := k.expiresAt.Add(-k.refreshMargin()).Sub(k.lastCheck); until < wait {
+			wait = until
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+func (k *Keeper) setState(s KeeperState) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.state = s
+}
+
+func (k *Keeper) clock() time.Time {
+	if k.now == nil {
+		return time.Now()
+	}
+	return k.now()
+}
+
+func (k *Keeper) interval() time.Duration {
+	if k.Interval <= 0 {
+		return DefaultKeepAliveInterval
+	}
+	return k.Interval
+}
+
+func (k *Keeper) refreshMargin() time.Duration {
+	if k.RefreshMargin <= 0 {
+		return DefaultRefreshMargin
+	}
+	return k.RefreshMargin
+}
+
+func (k *Keeper) minBackoff() time.Duration {
+	if k.MinBackoff <= 0 {
+		return DefaultMinBackoff
+	}
+	return k.MinBackoff
+}
+
+func (k *Keeper) maxBackoff() time.Duration {
+	if k.MaxBackoff <= 0 {
+		return DefaultMaxBackoff
+	}
+	return k.MaxBackoff
+}
+
+func isAuthError(err error) bool {
+	switch err.(type) {
+	case *ShibbolethAuthError, *AccountLockedError, *LoginLockedError:
+		return true
+	}
+	return false
+}
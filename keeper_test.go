@@ -0,0 +1,182 @@
+package kitwalk
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// keeperAuth logs in to sessionMock.
+type keeperAuth struct {
+	mock   *sessionMock
+	err    error
+	logins int
+}
+
+func (a *keeperAuth) LoginWith(client *http.Client) error {
+	a.logins++
+	if a.err != nil {
+		return a.err
+	}
+	a.mock.SPAlive = true
+	u, _ := url.Parse(ShibbolethLoginURL)
+	client.Jar.SetCookies(u, []*http.Cookie{{Name: SPSessionCookiePrefix + "test", Value: "new", Path: "/"}})
+	return nil
+}
+
+func (a *keeperAuth) SetupWith(config Config) error                  { return nil }
+func (a *keeperAuth) LoginAs(username string, password string) error { return nil }
+
+func newTestKeeper(mock *sessionMock, auth *keeperAuth) (*Keeper, *Jar) {
+	jar := NewJar()
+	k := NewKeeper(auth, &http.Client{Jar: jar, Transport: mock})
+	k.Interval = time.Minute
+	k.MinBackoff = time.Second
+	k.MaxBackoff = 8 * time.Second
+	return k, jar
+}
+
+func setIdPSession(t *testing.T, jar *Jar) {
+	t.Helper()
+	jar.SetCookies(mustParse(t, idpSSOURL), []*http.Cookie{{Name: IdPSessionCookie, Value: "idp", Path: "/idp"}})
+}
+
+func TestKeeper(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("Keep valid session", func(t *testing.T) {
+		mock := &sessionMock{SPAlive: true}
+		auth := &keeperAuth{mock: mock}
+		k, _ := newTestKeeper(mock, auth)
+		if wait := k.step(ctx); wait != time.Minute {
+			t.Errorf("Expect: %v\nActual: %v\n", time.Minute, wait)
+		}
+		if k.State() != KeeperHealthy || auth.logins != 0 {
+			t.Errorf("Unexpected state %v with %d logins", k.State(), auth.logins)
+		}
+	})
+	t.Run("Login after session is lost", func(t *testing.T) {
+		mock := &sessionMock{}
+		auth := &keeperAuth{mock: mock}
+		k, jar := newTestKeeper(mock, auth)
+		k.step(ctx)
+		if k.State() != KeeperHealthy || auth.logins != 1 || k.LastError() != nil {
+			t.Fatalf("Unexpected state %v with %d logins: %v", k.State(), auth.logins, k.LastError())
+		}
+		if _, ok := jar.Lookup(SPSessionCookiePrefix+"test", "portal.student.kit.ac.jp"); !ok {
+			t.Error("Expect: new session in the jar of the client\nActual: not found")
+		}
+		if k.ExpiresAt().IsZero() {
+			t.Error("Expect: estimated expiry\nActual: zero")
+		}
+	})
+	t.Run("Refresh before expiry", func(t *testing.T) {
+		mock := &sessionMock{SPAlive: true}
+		auth := &keeperAuth{mock: mock}
+		k, _ := newTestKeeper(mock, auth)
+		now := time.Now()
+		k.now = func() time.Time { return now }
+		k.expiresAt = now.Add(5 * time.Minute)
+		k.step(ctx)
+		if auth.logins != 1 || len(mock.Methods) != 0 {
+			t.Errorf("Expect: login without probe\nActual: %d logins, requests %v\n", auth.logins, mock.Methods)
+		}
+		if wait := k.step(ctx); wait != time.Minute {
+			t.Errorf("Expect: %v\nActual: %v\n", time.Minute, wait)
+		}
+	})
+	t.Run("Sign on with the session of the auth server", func(t *testing.T) {
+		mock := &sessionMock{IdPAlive: true}
+		auth := &keeperAuth{mock: mock}
+		k, jar := newTestKeeper(mock, auth)
+		setIdPSession(t, jar)
+		k.expiresAt = time.Now()
+		k.step(ctx)
+		if k.State() != KeeperHealthy || auth.logins != 0 {
+			t.Fatalf("Unexpected state %v with %d logins: %v", k.State(), auth.logins, k.LastError())
+		}
+		if c, ok := jar.Lookup(SPSessionCookiePrefix+"test", "portal.student.kit.ac.jp"); !ok || c.Value != "sso" {
+			t.Errorf("Expect: session by single sign-on in the jar of the client\nActual: %v\n", c)
+		}
+	})
+	t.Run("Login when the auth server has no session", func(t *testing.T) {
+		mock := &sessionMock{}
+		auth := &keeperAuth{mock: mock}
+		k, jar := newTestKeeper(mock, auth)
+		setIdPSession(t, jar)
+		k.expiresAt = time.Now()
+		k.step(ctx)
+		if k.State() != KeeperHealthy || auth.logins != 1 {
+			t.Errorf("Unexpected state %v with %d logins: %v", k.State(), auth.logins, k.LastError())
+		}
+	})
+	t.Run("Cancel refresh", func(t *testing.T) {
+		mock := &sessionMock{IdPAlive: true}
+		auth := &keeperAuth{mock: mock}
+		k, jar := newTestKeeper(mock, auth)
+		setIdPSession(t, jar)
+		k.expiresAt = time.Now()
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+		if wait := k.step(ctx); wait != 0 || auth.logins != 0 || len(mock.Methods) != 0 {
+			t.Errorf("Expect: canceled without requests\nActual: wait %v, %d logins, requests %v\n", wait, auth.logins, mock.Methods)
+		}
+	})
+	t.Run("Back off on failures", func(t *testing.T) {
+		mock := &sessionMock{}
+		auth := &keeperAuth{mock: mock, err: errors.New("auth server is down")}
+		k, _ := newTestKeeper(mock, auth)
+		var errs int
+		k.OnError = func(error) { errs++ }
+		for _, base := range []time.Duration{1, 2, 4, 8, 8} {
+			wait := k.step(ctx)
+			if wait < base*time.Second || wait > base*time.Second*3/2 {
+				t.Errorf("Expect: %v with jitter\nActual: %v\n", base*time.Second, wait)
+			}
+		}
+		if k.State() != KeeperBackoff || k.LastError() != auth.err || errs != 5 {
+			t.Errorf("Unexpected state %v: %v", k.State(), k.LastError())
+		}
+		auth.err = &ShibbolethAuthError{errMsg: "wrong password"}
+		k.backoff = 0
+		if wait := k.step(ctx); wait < 8*time.Second {
+			t.Errorf("Expect: max backoff for wrong credentials\nActual: %v\n", wait)
+		}
+		auth.err = &AccountLockedError{username: "b1234567", errMsg: "Your account is locked."}
+		k.backoff = 0
+		logins := auth.logins
+		if wait := k.step(ctx); wait < 8*time.Second || auth.logins != logins+1 {
+			t.Errorf("Expect: max backoff for locked account\nActual: %v\n", wait)
+		}
+		now := time.Now()
+		k.now = func() time.Time { return now }
+		auth.err = &LoginLockedError{username: "b1234567", failures: 3, until: now.Add(time.Minute)}
+		if wait := k.step(ctx); wait < time.Minute {
+			t.Errorf("Expect: wait until the lock is over\nActual: %v\n", wait)
+		}
+		k.now = nil
+		auth.err = nil
+		k.step(ctx)
+		if k.State() != KeeperHealthy || k.LastError() != nil {
+			t.Errorf("Unexpected state %v: %v", k.State(), k.LastError())
+		}
+	})
+	t.Run("Stop on cancellation", func(t *testing.T) {
+		mock := &sessionMock{SPAlive: true}
+		k, _ := newTestKeeper(mock, &keeperAuth{mock: mock})
+		ctx, cancel := context.WithCancel(ctx)
+		done := make(chan error)
+		go func() { done <- k.Run(ctx) }()
+		cancel()
+		if err := <-done; err != context.Canceled {
+			t.Errorf("Expect: %v\nActual: %v\n", context.Canceled, err)
+		}
+		if k.State() != KeeperStopped {
+			t.Errorf("Expect: %v\nActual: %v\n", KeeperStopped, k.State())
+		}
+	})
+}
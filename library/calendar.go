@@ -0,0 +1,71 @@
+package library
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/StudioAquatan/kitwalk/ical"
+)
+
+// Summary prefixes of the events of the library.
+const (
+	DuePrefix    = "【返却期限】"
+	PickupPrefix = "【取置期限】"
+)
+
+// Events returns the due dates of the loans and the last days to receive the reserved items as all-day events.
+// Append them to the events of the timetable, such as from classchange.Events, to write one calendar.
+func Events(loans []Loan, reservations []Reservation) []ical.Event {
+	var events []ical.Event
+	for _, l := range loans {
+		if l.Due.IsZero() {
+			continue
+		}
+		var lines []string
+		if l.Author != "" {
+			lines = append(lines, l.Author)
+		}
+		lines = append(lines, "資料ID: "+l.ID)
+		if l.Status != "" {
+			lines = append(lines, l.Status)
+		}
+		events = append(events, ical.Event{
+			UID:         fmt.Sprintf("loan-%s-%s@%s", l.ID, l.Loaned.Format("20060102"), ical.UIDDomain),
+			Summary:     DuePrefix + l.Title,
+			Description: strings.Join(lines, "\n"),
+			Start:       l.Due,
+			End:         l.Due.AddDate(0, 0, 1),
+			AllDay:      true,
+			Status:      ical.StatusConfirmed,
+		})
+	}
+	for _, r := range reservations {
+		if r.PickupBy.IsZero() {
+			continue
+		}
+		events = append(events, ical.Event{
+			UID:      fmt.Sprintf("reservation-%s-%s@%s", r.Reserved.Format("20060102"), digest(r.Title), ical.UIDDomain),
+			Summary:  PickupPrefix + r.Title,
+			Location: r.Pickup,
+			Start:    r.PickupBy,
+			End:      r.PickupBy.AddDate(0, 0, 1),
+			AllDay:   true,
+			Status:   ical.StatusConfirmed,
+		})
+	}
+	return events
+}
+
+// WriteCalendar writes the due dates of the loans and the reservations as an iCalendar document.
+func WriteCalendar(w io.Writer, loans []Loan, reservations []Reservation) error {
+	return ical.Write(w, &ical.Calendar{Name: "図書館", Events: Events(loans, reservations)})
+}
+
+// digest returns a short digest of the text to identify the event.
+func digest(s string) string {
+	sum := sha1.Sum([]byte(s))
+	return hex.EncodeToString(sum[:8])
+}
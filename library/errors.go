@@ -0,0 +1,38 @@
+package library
+
+import "fmt"
+
+// StatusError will be return when the library responds with unexpected status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Unexpected status code %d from '%s'.", e.StatusCode, e.URL)
+}
+
+// ParseError will be return when the page of the library does not have expected content.
+type ParseError struct {
+	errMsg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Could not parse the page of the library. %s", e.errMsg)
+}
+
+// PageError will be return when a page is needed but not set in Client.Pages.
+type PageError struct {
+	Page string
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("The page '%s' is not set in Client.Pages.", e.Page)
+}
+
+// RenewDisabledError will be return when renewal is requested without Client.AllowRenew.
+type RenewDisabledError struct{}
+
+func (e *RenewDisabledError) Error() string {
+	return "Renewal is disabled. Set AllowRenew to renew, or DryRun to see the loans to be renewed."
+}
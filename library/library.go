@@ -0,0 +1,279 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/StudioAquatan/kitwalk/internal/text"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+var datePattern = regexp.MustCompile(`(\d{4})\s*[/.\-年]\s*(\d{1,2})\s*[/.\-月]\s*(\d{1,2})`)
+
+// Loan is a borrowed item.
+type Loan struct {
+	// ID is the item ID ("資料ID") such as the barcode.
+	ID     string `json:"id"`
+	Title  string `json:"title"`
+	Author string `json:"author,omitempty"`
+	// Loaned and Due are the midnight of the dates in JST.
+	Loaned time.Time `json:"loaned"`
+	Due    time.Time `json:"due"`
+	// Renewals is the number of times the loan has been renewed.
+	Renewals int `json:"renewals"`
+	// Renewable reports whether the library accepts the renewal of the loan.
+	Renewable bool `json:"renewable"`
+	// Status is the note as shown such as "延滞" or "延長不可（予約あり）".
+	Status string `json:"status,omitempty"`
+}
+
+// Overdue reports whether the loan is past the due date at given time.
+func (l *Loan) Overdue(now time.Time) bool {
+	return !l.Due.IsZero() && !now.Before(l.Due.AddDate(0, 0, 1))
+}
+
+// Reservation is a reserved item.
+type Reservation struct {
+	Title    string    `json:"title"`
+	Reserved time.Time `json:"reserved"`
+	// Status is the state as shown such as "予約中" or "取置中".
+	Status string `json:"status"`
+	// Pickup is the library to receive the item at.
+	Pickup string `json:"pickup,omitempty"`
+	// PickupBy is the last day to receive the item. It is zero until the item is ready.
+	PickupBy time.Time `json:"pickup_by,omitempty"`
+}
+
+// loanColumns maps the headers of the loans to the setters of the fields.
+var loanColumns = map[string]func(l *Loan, s string){
+	"書名":   func(l *Loan, s string) { l.Title = s },
+	"著者":   func(l *Loan, s string) { l.Author = s },
+	"資料ID": func(l *Loan, s string) { l.ID = s },
+	"貸出日":  func(l *Loan, s string) { l.Loaned = parseDate(s) },
+	"返却期限": func(l *Loan, s string) { l.Due = parseDate(s) },
+	"延長回数": func(l *Loan, s string) { l.Renewals, _ = strconv.Atoi(s) },
+	"状態":   func(l *Loan, s string) { l.Status = s },
+}
+
+// reservationColumns maps the headers of the reservations to the setters of the fields.
+var reservationColumns = map[string]func(r *Reservation, s string){
+	"書名":   func(r *Reservation, s string) { r.Title = s },
+	"予約日":  func(r *Reservation, s string) { r.Reserved = parseDate(s) },
+	"状態":   func(r *Reservation, s string) { r.Status = s },
+	"受取館":  func(r *Reservation, s string) { r.Pickup = s },
+	"取置期限": func(r *Reservation, s string) { r.PickupBy = parseDate(s) },
+}
+
+// Loans fetches the current loans from Pages.Loans.
+func (c *Client) Loans(ctx context.Context) ([]Loan, error) {
+	if c.Pages.Loans.Path == "" || c.Pages.Loans.Table == "" {
+		return nil, &PageError{Page: "Loans"}
+	}
+	body, _, err := c.fetch(ctx, c.Pages.Loans.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLoans(bytes.NewReader(body), &c.Pages)
+}
+
+// Reservations fetches the current reservations from Pages.Reservations.
+func (c *Client) Reservations(ctx context.Context) ([]Reservation, error) {
+	if c.Pages.Reservations.Path == "" || c.Pages.Reservations.Table == "" {
+		return nil, &PageError{Page: "Reservations"}
+	}
+	body, _, err := c.fetch(ctx, c.Pages.Reservations.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	return ParseReservations(bytes.NewReader(body), &c.Pages)
+}
+
+// ParseLoans parses the table of the loans page selected by pages.Loans.Table. Columns are identified by
+// their headers, and a loan is renewable when its checkbox named pages.RenewField is enabled.
+func ParseLoans(body io.Reader, pages *Pages) ([]Loan, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+	var loans []Loan
+	err = parseTable(doc, pages.Loans.Table, pages.Empty, func(headers []string, row *goquery.Selection) {
+		var l Loan
+		row.Find("td").Each(func(i int, cell *goquery.Selection) {
+			if i < len(headers) {
+				if set := loanColumns[headers[i]]; set != nil {
+					set(&l, text.CollapseSpaces(cell.Text()))
+				}
+			}
+		})
+		if box := row.Find(`input[name="` + pages.RenewField + `"]`).First(); pages.RenewField != "" && box.Length() != 0 {
+			_, disabled := box.Attr("disabled")
+			l.Renewable = !disabled
+			if id, ok := box.Attr("value"); ok && l.ID == "" {
+				l.ID = id
+			}
+		}
+		loans = append(loans, l)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return loans, nil
+}
+
+// ParseReservations parses the table of the reservations page selected by pages.Reservations.Table.
+// Columns are identified by their headers.
+func ParseReservations(body io.Reader, pages *Pages) ([]Reservation, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+	var reservations []Reservation
+	err = parseTable(doc, pages.Reservations.Table, pages.Empty, func(headers []string, row *goquery.Selection) {
+		var r Reservation
+		row.Find("td").Each(func(i int, cell *goquery.Selection) {
+			if i < len(headers) {
+				if set := reservationColumns[headers[i]]; set != nil {
+					set(&r, text.CollapseSpaces(cell.Text()))
+				}
+			}
+		})
+		reservations = append(reservations, r)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// parseTable calls fn with the headers for each row of the table. A page without the table has no item
+// when it has the message selected by empty.
+func parseTable(doc *goquery.Document, selector, empty string, fn func(headers []string, row *goquery.Selection)) error {
+	table := doc.Find(selector).First()
+	if table.Length() == 0 {
+		if empty != "" && doc.Find(empty).Length() != 0 {
+			return nil
+		}
+		return &ParseError{errMsg: "The table '" + selector + "' is not found."}
+	}
+	rows := table.Find("tr")
+	var headers []string
+	rows.First().Find("th").Each(func(_ int, s *goquery.Selection) {
+		headers = append(headers, text.CollapseSpaces(s.Text()))
+	})
+	rows.Slice(1, rows.Length()).Each(func(_ int, row *goquery.Selection) {
+		if row.Find("td").Length() != 0 {
+			fn(headers, row)
+		}
+	})
+	return nil
+}
+
+// RenewResult is the result of the renewal of a loan.
+type RenewResult struct {
+	Loan Loan `json:"loan"`
+	// Renewed reports whether the due date has been extended. It is false in the dry-run mode.
+	Renewed bool `json:"renewed"`
+	// DryRun reports that the loan would be renewed but has not been.
+	DryRun bool `json:"dry_run,omitempty"`
+	// NewDue is the due date after the renewal.
+	NewDue time.Time `json:"new_due,omitempty"`
+	// Reason tells why the loan is not renewed.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Renew renews the renewable loans among given ones, and returns the result of each loan.
+// It needs AllowRenew, or DryRun to report the loans to be renewed without renewing them.
+// Pages.RenewForm and Pages.RenewField are needed to renew.
+// The renewal is confirmed by the due dates in the loans page returned by the library.
+func (c *Client) Renew(ctx context.Context, loans []Loan) ([]RenewResult, error) {
+	if !c.AllowRenew && !c.DryRun {
+		return nil, &RenewDisabledError{}
+	}
+	results := make([]RenewResult, len(loans))
+	var ids []string
+	for i, l := range loans {
+		results[i].Loan = l
+		if !l.Renewable {
+			results[i].Reason = "The library does not accept the renewal."
+			if l.Status != "" {
+				results[i].Reason += " " + l.Status
+			}
+			continue
+		}
+		if c.DryRun {
+			results[i].DryRun = true
+			continue
+		}
+		ids = append(ids, l.ID)
+	}
+	if len(ids) == 0 {
+		return results, nil
+	}
+	if c.Pages.RenewForm == "" || c.Pages.RenewField == "" {
+		return nil, &PageError{Page: "RenewForm"}
+	}
+	if c.Pages.Loans.Path == "" || c.Pages.Loans.Table == "" {
+		return nil, &PageError{Page: "Loans"}
+	}
+
+	body, base, err := c.fetch(ctx, c.Pages.Loans.Path, nil)
+	if err != nil {
+		return nil, err
+	}
+	form, err := portal.ParseForm(bytes.NewReader(body), base, c.Pages.RenewForm)
+	if err != nil {
+		return nil, err
+	}
+	form.Values.Del(c.Pages.RenewField)
+	for _, id := range ids {
+		form.Values.Add(c.Pages.RenewField, id)
+	}
+	if body, _, err = c.fetch(ctx, form.Action.String(), form.Values); err != nil {
+		return nil, err
+	}
+	renewed, err := ParseLoans(bytes.NewReader(body), &c.Pages)
+	if err != nil {
+		return nil, err
+	}
+	after := make(map[string]Loan, len(renewed))
+	for _, l := range renewed {
+		after[l.ID] = l
+	}
+	for i := range results {
+		r := &results[i]
+		if !r.Loan.Renewable {
+			continue
+		}
+		l, ok := after[r.Loan.ID]
+		switch {
+		case !ok:
+			r.Reason = "The loan is not found after the renewal."
+		case l.Due.After(r.Loan.Due):
+			r.Renewed, r.NewDue = true, l.Due
+		default:
+			r.NewDue = l.Due
+			r.Reason = "The due date is not extended."
+			if l.Status != "" {
+				r.Reason += " " + l.Status
+			}
+		}
+	}
+	return results, nil
+}
+
+// parseDate parses the date such as "2018/05/15" or "2018年5月15日" in JST. It returns zero time without a date.
+func parseDate(s string) time.Time {
+	m := datePattern.FindStringSubmatch(s)
+	if m == nil {
+		return time.Time{}
+	}
+	year, _ := strconv.Atoi(m[1])
+	month, _ := strconv.Atoi(m[2])
+	day, _ := strconv.Atoi(m[3])
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, portal.JST)
+}
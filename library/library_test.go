@@ -0,0 +1,228 @@
+package library
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+const testSite = "https://opac.example.ac.jp"
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// mock is the library behind the auth server which has the session.
+type mock struct {
+	t      *testing.T
+	Logins int
+	// Renewed are the IDs posted to renew.
+	Renewed []string
+}
+
+func (m *mock) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	m.serve(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+func (m *mock) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Host == kitwalk.DefaultAuthDomain {
+		fmt.Fprint(w, `<form action="`+testSite+`/Shibboleth.sso/SAML2/POST" method="post">`+
+			`<input type="hidden" name="RelayState" value="state"/><input type="hidden" name="SAMLResponse" value="response"/></form>`)
+		return
+	}
+	if req.URL.Path == "/Shibboleth.sso/SAML2/POST" {
+		m.Logins++
+		http.SetCookie(w, &http.Cookie{Name: "_shibsession_library", Value: "library", Path: "/"})
+		http.Redirect(w, req, testSite+"/opac/myopac/", http.StatusFound)
+		return
+	}
+	if _, err := req.Cookie("_shibsession_library"); err != nil {
+		http.Redirect(w, req, "https://"+kitwalk.DefaultAuthDomain+"/idp/profile/SAML2/Redirect/SSO", http.StatusFound)
+		return
+	}
+	page := func(name string) string {
+		body, err := ioutil.ReadFile("../samples/" + name)
+		if err != nil {
+			m.t.Fatal(err)
+		}
+		return string(body)
+	}
+	switch req.URL.Path {
+	case "/opac/myopac/", "/opac/myopac/shibboleth":
+		fmt.Fprint(w, "MyLibrary")
+	case "/opac/myopac/loans":
+		fmt.Fprint(w, page("library_loans.html"))
+	case "/opac/myopac/reservations":
+		fmt.Fprint(w, page("library_reservations.html"))
+	case "/opac/myopac/renew":
+		req.ParseForm()
+		if req.Method != http.MethodPost || req.PostForm.Get("csrf_token") != "library-token" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		m.Renewed = req.PostForm["renew"]
+		loans := page("library_loans.html")
+		for _, id := range m.Renewed {
+			if id == "B1001" {
+				loans = strings.Replace(loans, "<td>2018/05/01</td><td>2018/05/15</td><td>0</td>", "<td>2018/05/01</td><td>2018/05/29</td><td>1</td>", 1)
+			}
+		}
+		fmt.Fprint(w, loans)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+// testPages locate the synthetic samples.
+var testPages = Pages{
+	Login:        "opac/myopac/shibboleth",
+	Loans:        Page{Path: "opac/myopac/loans", Table: "table.loans"},
+	Reservations: Page{Path: "opac/myopac/reservations", Table: "table.reservations"},
+	Empty:        ".no_items",
+	RenewForm:    "form#renew_form",
+	RenewField:   "renew",
+}
+
+func newTestClient(t *testing.T, m *mock) *Client {
+	m.t = t
+	c := NewClient(nil, &http.Client{Transport: m})
+	c.BaseURL, _ = url.Parse(testSite + "/")
+	c.Pages = testPages
+	return c
+}
+
+func date(month time.Month, day int) time.Time {
+	return time.Date(2018, month, day, 0, 0, 0, 0, portal.JST)
+}
+
+func TestClient_Loans(t *testing.T) {
+	t.Parallel()
+	m := &mock{}
+	c := newTestClient(t, m)
+	// The client signs on when the library bounces it to the auth server.
+	loans, err := c.Loans(context.Background())
+	check(t, err)
+	if m.Logins != 1 {
+		t.Errorf("Expect: signed on once\nActual: %d\n", m.Logins)
+	}
+	if len(loans) != 3 {
+		t.Fatalf("Expect: 3 loans\nActual: %+v\n", loans)
+	}
+	expect := Loan{ID: "B1001", Title: "アルゴリズムイントロダクション 第3版", Author: "T. コルメン",
+		Loaned: date(time.May, 1), Due: date(time.May, 15), Renewable: true}
+	if loans[0] != expect {
+		t.Errorf("Expect: %+v\nActual: %+v\n", expect, loans[0])
+	}
+	if overdue := loans[2]; overdue.Renewable || !overdue.Overdue(date(time.May, 9)) || overdue.Overdue(date(time.May, 8).Add(23*time.Hour)) {
+		t.Errorf("Expect: overdue loan\nActual: %+v\n", overdue)
+	}
+
+	reservations, err := c.Reservations(context.Background())
+	check(t, err)
+	if len(reservations) != 2 || reservations[0].Status != "取置中" || !reservations[0].PickupBy.Equal(date(time.May, 16)) || !reservations[1].PickupBy.IsZero() {
+		t.Errorf("Unexpected reservations %+v", reservations)
+	}
+	if m.Logins != 1 {
+		t.Errorf("Expect: session kept\nActual: signed on %d times\n", m.Logins)
+	}
+
+	c.Pages = Pages{}
+	if _, err := c.Loans(context.Background()); err == nil {
+		t.Error("Expect: PageError\nActual: (nil)")
+	} else if _, ok := err.(*PageError); !ok {
+		t.Errorf("Expect: PageError\nActual: %v\n", err)
+	}
+}
+
+func TestClient_Renew(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	t.Run("Refuse without opt-in", func(t *testing.T) {
+		m := &mock{}
+		c := newTestClient(t, m)
+		loans, err := c.Loans(ctx)
+		check(t, err)
+		_, err = c.Renew(ctx, loans)
+		switch err.(type) {
+		case *RenewDisabledError:
+		default:
+			t.Errorf("Expect: RenewDisabledError\nActual: %v\n", err)
+		}
+		if m.Renewed != nil {
+			t.Errorf("Expect: nothing posted\nActual: %v\n", m.Renewed)
+		}
+	})
+	t.Run("Dry run", func(t *testing.T) {
+		m := &mock{}
+		c := newTestClient(t, m)
+		c.DryRun = true
+		loans, err := c.Loans(ctx)
+		check(t, err)
+		results, err := c.Renew(ctx, loans)
+		check(t, err)
+		if !results[0].DryRun || results[0].Renewed || results[1].DryRun || results[1].Reason == "" {
+			t.Errorf("Unexpected results %+v", results)
+		}
+		if m.Renewed != nil {
+			t.Errorf("Expect: nothing posted\nActual: %v\n", m.Renewed)
+		}
+	})
+	t.Run("Renew eligible loans", func(t *testing.T) {
+		m := &mock{}
+		c := newTestClient(t, m)
+		c.AllowRenew = true
+		loans, err := c.Loans(ctx)
+		check(t, err)
+		results, err := c.Renew(ctx, loans)
+		check(t, err)
+		if len(m.Renewed) != 1 || m.Renewed[0] != "B1001" {
+			t.Errorf("Expect: only B1001 posted\nActual: %v\n", m.Renewed)
+		}
+		if !results[0].Renewed || !results[0].NewDue.Equal(date(time.May, 29)) {
+			t.Errorf("Expect: renewed until 5/29\nActual: %+v\n", results[0])
+		}
+		if results[1].Renewed || results[2].Renewed {
+			t.Errorf("Expect: others not renewed\nActual: %+v\n", results)
+		}
+	})
+}
+
+func TestWriteCalendar(t *testing.T) {
+	t.Parallel()
+	loans := []Loan{{ID: "B1001", Title: "情報理論", Loaned: date(time.May, 1), Due: date(time.May, 15)}}
+	reservations := []Reservation{
+		{Title: "計算機プログラムの構造と解釈", Reserved: date(time.May, 2), Status: "取置中", Pickup: "附属図書館", PickupBy: date(time.May, 16)},
+		{Title: "コンパイラ", Reserved: date(time.May, 9), Status: "予約中"},
+	}
+	events := Events(loans, reservations)
+	if len(events) != 2 {
+		t.Fatalf("Expect: 2 events\nActual: %+v\n", events)
+	}
+	if e := events[0]; e.UID != "loan-B1001-20180501@kitwalk.studioaquatan" || e.Summary != "【返却期限】情報理論" || !e.End.Equal(date(time.May, 16)) {
+		t.Errorf("Unexpected event of the loan %+v", e)
+	}
+	var b bytes.Buffer
+	check(t, WriteCalendar(&b, loans, reservations))
+	if !strings.Contains(b.String(), "SUMMARY:【取置期限】計算機プログラムの構造と解釈\r\n") ||
+		!strings.Contains(b.String(), "DTSTART;VALUE=DATE:20180515\r\n") ||
+		!strings.Contains(b.String(), "DTEND;VALUE=DATE:20180516\r\n") || strings.Contains(b.String(), "VTIMEZONE") {
+		t.Errorf("Unexpected calendar\n%s", b.String())
+	}
+}
@@ -0,0 +1,256 @@
+package kitwalk
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultMaxLoginFailures is the number of failures after which LoginLimiter refuses login.
+	DefaultMaxLoginFailures = 3
+	// DefaultLoginFailureWindow is how long LoginLimiter remembers a failure.
+	DefaultLoginFailureWindow = 30 * time.Minute
+	// DefaultLoginInterval is the interval between logins allowed by LoginLimiter after the burst.
+	DefaultLoginInterval = 2 * time.Second
+	// DefaultLoginBurst is the number of logins LoginLimiter allows at once.
+	DefaultLoginBurst = 3
+)
+
+// lockoutMessages are the parts of the error messages of the auth server for locked accounts.
+// The exact messages depend on the configuration of the auth server, so common phrases are matched.
+var lockoutMessages = []string{
+	"locked",
+	"too many",
+	"exceeded",
+	"ロック",
+	"試行回数",
+}
+
+func isLockoutMessage(msg string) bool {
+	msg = strings.ToLower(msg)
+	for _, m := range lockoutMessages {
+		if strings.Contains(msg, m) {
+			return true
+		}
+	}
+	return false
+}
+
+// FailureStore persists the recent login failures, so that they are remembered across processes.
+type FailureStore interface {
+	LoadFailures(username string) ([]time.Time, error)
+	SaveFailures(username string, failures []time.Time) error
+}
+
+// LoginLimiter protects accounts from being locked by the auth server.
+// It refuses to post credentials of a user with LoginLockedError after MaxFailures failures within Window,
+// and limits the rate of logins of all users with a token bucket.
+type LoginLimiter struct {
+	MaxFailures int
+	Window      time.Duration
+	// Interval is the interval between logins after Burst logins.
+	Interval time.Duration
+	Burst    int
+	// Store persists failures when it is not nil.
+	Store FailureStore
+	// OnError is called when Store fails to record the result of a login by SamlAuthenticator.
+	// The result is still kept in memory. It may be nil.
+	OnError func(error)
+
+	mu sync.Mutex
+	// failures are all failures without Store, and the ones Store failed to save with it.
+	failures map[string][]time.Time
+	tokens   float64
+	filledAt time.Time
+	now      func() time.Time
+}
+
+// DefaultLoginLimiter is set to the authenticators created by NewAuthenticator, so that the logins of the process
+// are limited together. Set SamlAuthenticator.Limiter to nil to opt out.
+var DefaultLoginLimiter = NewLoginLimiter()
+
+// NewLoginLimiter create new limiter with default settings.
+func NewLoginLimiter() *LoginLimiter {
+	return &LoginLimiter{
+		MaxFailures: DefaultMaxLoginFailures,
+		Window:      DefaultLoginFailureWindow,
+		Interval:    DefaultLoginInterval,
+		Burst:       DefaultLoginBurst,
+		now:         time.Now,
+	}
+}
+
+// Wait returns LoginLockedError when the user has failed too many times,
+// or waits until the rate limit allows login.
+func (l *LoginLimiter) Wait(ctx context.Context, username string) error {
+	l.mu.Lock()
+	now := l.clock()
+	failures, err := l.recent(username, now)
+	if err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	if max := l.maxFailures(); len(failures) >= max {
+		l.mu.Unlock()
+		return &LoginLockedError{
+			username: username,
+			failures: len(failures),
+			until:    failures[len(failures)-max].Add(l.window()),
+		}
+	}
+	wait := l.reserve(now)
+	l.mu.Unlock()
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// Failure records that the auth server rejected the credentials.
+func (l *LoginLimiter) Failure(username string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock()
+	failures, err := l.recent(username, now)
+	if err != nil {
+		return err
+	}
+	return l.save(username, append(failures, now))
+}
+
+// Locked records that the auth server has locked the account, and refuses login until Window passes.
+func (l *LoginLimiter) Locked(username string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.clock()
+	failures, err := l.recent(username, now)
+	if err != nil {
+		return err
+	}
+	for len(failures) < l.maxFailures() {
+		failures = append(failures, now)
+	}
+	return l.save(username, failures)
+}
+
+// Success forgets the failures of the user.
+func (l *LoginLimiter) Success(username string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.failures[username]) == 0 && l.Store == nil {
+		return nil
+	}
+	return l.save(username, nil)
+}
+
+// report passes the error of recording a login to OnError.
+func (l *LoginLimiter) report(err error) {
+	if err != nil && l.OnError != nil {
+		l.OnError(err)
+	}
+}
+
+// Failures returns the number of recent failures of the user.
+func (l *LoginLimiter) Failures(username string) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	failures, err := l.recent(username, l.clock())
+	return len(failures), err
+}
+
+// recent returns the failures within the window. They are loaded from Store every time when it is set,
+// since other processes sharing the store may have recorded failures. l.mu must be held.
+func (l *LoginLimiter) recent(username string, now time.Time) ([]time.Time, error) {
+	failures := l.failures[username]
+	if l.Store != nil {
+		loaded, err := l.Store.LoadFailures(username)
+		if err != nil {
+			return nil, err
+		}
+		failures = append(loaded, failures...)
+	}
+	var recent []time.Time
+	for _, t := range failures {
+		if now.Sub(t) < l.window() {
+			recent = append(recent, t)
+		}
+	}
+	return recent, nil
+}
+
+// save keeps the failures in the store, or in memory without it or when the store fails. l.mu must be held.
+func (l *LoginLimiter) save(username string, failures []time.Time) error {
+	if l.Store == nil {
+		l.setFailures(username, failures)
+		return nil
+	}
+	if err := l.Store.SaveFailures(username, failures); err != nil {
+		l.setFailures(username, failures)
+		return err
+	}
+	l.setFailures(username, nil)
+	return nil
+}
+
+func (l *LoginLimiter) setFailures(username string, failures []time.Time) {
+	if l.failures == nil {
+		l.failures = make(map[string][]time.Time)
+	}
+	l.failures[username] = failures
+}
+
+// reserve takes a token, and returns the wait until the token is available. l.mu must be held.
+func (l *LoginLimiter) reserve(now time.Time) time.Duration {
+	interval := l.Interval
+	if interval <= 0 {
+		interval = DefaultLoginInterval
+	}
+	burst := float64(l.Burst)
+	if burst <= 0 {
+		burst = DefaultLoginBurst
+	}
+	if l.filledAt.IsZero() {
+		l.tokens = burst
+	} else if elapsed := now.Sub(l.filledAt); elapsed > 0 {
+		l.tokens += float64(elapsed) / float64(interval)
+	}
+	if l.tokens > burst {
+		l.tokens = burst
+	}
+	l.filledAt = now
+	l.tokens--
+	if l.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-l.tokens * float64(interval))
+}
+
+func (l *LoginLimiter) clock() time.Time {
+	if l.now == nil {
+		return time.Now()
+	}
+	return l.now()
+}
+
+func (l *LoginLimiter) maxFailures() int {
+	if l.MaxFailures <= 0 {
+		return DefaultMaxLoginFailures
+	}
+	return l.MaxFailures
+}
+
+func (l *LoginLimiter) window() time.Duration {
+	if l.Window <= 0 {
+		return DefaultLoginFailureWindow
+	}
+	return l.Window
+}
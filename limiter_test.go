@@ -0,0 +1,200 @@
+package kitwalk
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoginLimiter(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+
+	t.Run("Refuse login after failures", func(t *testing.T) {
+		now := time.Now()
+		l := NewLoginLimiter()
+		l.Interval = time.Microsecond
+		l.now = func() time.Time { return now }
+		for i := 0; i < DefaultMaxLoginFailures; i++ {
+			check(t, l.Wait(ctx, validUsername))
+			check(t, l.Failure(validUsername))
+		}
+		err := l.Wait(ctx, validUsername)
+		switch e := err.(type) {
+		case *LoginLockedError:
+			if !e.Until().Equal(now.Add(DefaultLoginFailureWindow)) {
+				t.Errorf("Expect: %v\nActual: %v\n", now.Add(DefaultLoginFailureWindow), e.Until())
+			}
+		default:
+			t.Fatalf("Expected: LoginLockedError\nActual: %+v\n", e)
+		}
+		check(t, l.Wait(ctx, "m8234567"))
+		now = now.Add(DefaultLoginFailureWindow)
+		check(t, l.Wait(ctx, validUsername))
+	})
+	t.Run("Forget failures after success", func(t *testing.T) {
+		l := NewLoginLimiter()
+		l.Interval = time.Microsecond
+		check(t, l.Failure(validUsername))
+		check(t, l.Failure(validUsername))
+		check(t, l.Success(validUsername))
+		if n, err := l.Failures(validUsername); err != nil || n != 0 {
+			t.Errorf("Expect: 0\nActual: %d %v\n", n, err)
+		}
+	})
+	t.Run("Persist failures in the store", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "kitwalk")
+		check(t, err)
+		defer os.RemoveAll(dir)
+		store := &FileSessionStore{Path: filepath.Join(dir, sessionFileName)}
+		l := NewLoginLimiter()
+		l.Store = store
+		check(t, l.Locked(validUsername))
+
+		restarted := NewLoginLimiter()
+		restarted.Interval = time.Microsecond
+		restarted.Store = store
+		if _, ok := restarted.Wait(ctx, validUsername).(*LoginLockedError); !ok {
+			t.Error("Expect: LoginLockedError after restart\nActual: allowed")
+		}
+		check(t, restarted.Success(validUsername))
+		if failures, err := store.LoadFailures(validUsername); err != nil || len(failures) != 0 {
+			t.Errorf("Expect: no failures\nActual: %v %v\n", failures, err)
+		}
+	})
+	t.Run("Share failures between processes", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "kitwalk")
+		check(t, err)
+		defer os.RemoveAll(dir)
+		store := &FileSessionStore{Path: filepath.Join(dir, sessionFileName)}
+		a, b := NewLoginLimiter(), NewLoginLimiter()
+		a.Store, b.Store = store, store
+		b.Interval = time.Microsecond
+		check(t, b.Wait(ctx, validUsername))
+		for i := 0; i < DefaultMaxLoginFailures; i++ {
+			check(t, a.Failure(validUsername))
+		}
+		if _, ok := b.Wait(ctx, validUsername).(*LoginLockedError); !ok {
+			t.Error("Expect: LoginLockedError by the failures of another process\nActual: allowed")
+		}
+	})
+	t.Run("Limit rate of logins", func(t *testing.T) {
+		l := NewLoginLimiter()
+		l.Burst = 2
+		l.Interval = 50 * time.Millisecond
+		start := time.Now()
+		for i := 0; i < 3; i++ {
+			check(t, l.Wait(ctx, validUsername))
+		}
+		if elapsed := time.Since(start); elapsed < 40*time.Millisecond {
+			t.Errorf("Expect: wait for the third login\nActual: %v\n", elapsed)
+		}
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+		if err := l.Wait(canceled, validUsername); err != context.Canceled {
+			t.Errorf("Expect: %v\nActual: %v\n", context.Canceled, err)
+		}
+	})
+}
+
+func TestSamlAuthenticator_Limiter(t *testing.T) {
+	t.Parallel()
+	t.Run("Stop posting wrong password", func(t *testing.T) {
+		auth, err := NewAuthenticator(context.Background(), validUsername, invalidPasswd)
+		check(t, err)
+		limiter := NewLoginLimiter()
+		limiter.Interval = time.Microsecond
+		limiter.MaxFailures = 2
+		auth.(*SamlAuthenticator).Limiter = limiter
+		for i := 0; i < 2; i++ {
+			err := auth.LoginWith(&http.Client{Transport: &samlMock{}})
+			if _, ok := err.(*ShibbolethAuthError); !ok {
+				t.Fatalf("Expected: ShibbolethAuthError\nActual: %+v\n", err)
+			}
+		}
+		err = auth.LoginWith(&http.Client{Transport: &samlMock{}})
+		if _, ok := err.(*LoginLockedError); !ok {
+			t.Errorf("Expected: LoginLockedError\nActual: %+v\n", err)
+		}
+	})
+	t.Run("Count only rejected credentials", func(t *testing.T) {
+		auth, err := NewAuthenticator(context.Background(), validUsername, validPasswd)
+		check(t, err)
+		limiter := NewLoginLimiter()
+		limiter.Interval = time.Microsecond
+		auth.(*SamlAuthenticator).Limiter = limiter
+		err = auth.LoginWith(&http.Client{Transport: changedLayoutMock{}})
+		if _, ok := err.(*ShibbolethAuthError); !ok {
+			t.Fatalf("Expected: ShibbolethAuthError\nActual: %+v\n", err)
+		}
+		if n, err := limiter.Failures(validUsername); err != nil || n != 0 {
+			t.Errorf("Expect: no failure counted\nActual: %d %v\n", n, err)
+		}
+	})
+	t.Run("No limit without limiter", func(t *testing.T) {
+		auth, err := NewAuthenticator(context.Background(), validUsername, invalidPasswd)
+		check(t, err)
+		if auth.(*SamlAuthenticator).Limiter != DefaultLoginLimiter {
+			t.Fatal("Expect: DefaultLoginLimiter by default")
+		}
+		auth.(*SamlAuthenticator).Limiter = nil
+		for i := 0; i < DefaultMaxLoginFailures+1; i++ {
+			err := auth.LoginWith(&http.Client{Transport: &samlMock{}})
+			if _, ok := err.(*ShibbolethAuthError); !ok {
+				t.Fatalf("Expected: ShibbolethAuthError\nActual: %+v\n", err)
+			}
+		}
+	})
+	t.Run("Report store errors", func(t *testing.T) {
+		auth, err := NewAuthenticator(context.Background(), validUsername, invalidPasswd)
+		check(t, err)
+		limiter := NewLoginLimiter()
+		limiter.Interval = time.Microsecond
+		limiter.Store = failingStore{}
+		var reported []error
+		limiter.OnError = func(err error) { reported = append(reported, err) }
+		auth.(*SamlAuthenticator).Limiter = limiter
+		err = auth.LoginWith(&http.Client{Transport: &samlMock{}})
+		if _, ok := err.(*ShibbolethAuthError); !ok {
+			t.Errorf("Expected: ShibbolethAuthError\nActual: %+v\n", err)
+		}
+		if len(reported) != 1 {
+			t.Errorf("Expected: 1 error reported\nActual: %v\n", reported)
+		}
+	})
+	t.Run("Recognize locked account", func(t *testing.T) {
+		page := `<html><body><p class="form-error">アカウントがロックされています。</p></body></html>`
+		_, _, err := parseSamlResp(strings.NewReader(page))
+		if _, ok := err.(*AccountLockedError); !ok {
+			t.Errorf("Expected: AccountLockedError\nActual: %+v\n", err)
+		}
+	})
+}
+
+// failingStore remembers no failure, and fails to save them.
+type failingStore struct{}
+
+func (failingStore) LoadFailures(username string) ([]time.Time, error) {
+	return nil, nil
+}
+
+func (failingStore) SaveFailures(username string, failures []time.Time) error {
+	return errors.New("disk is full")
+}
+
+// changedLayoutMock is the auth server whose page after posting the credentials is not understood.
+type changedLayoutMock struct{}
+
+func (changedLayoutMock) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method == http.MethodPost {
+		return &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Request: req,
+			Body: ioutil.NopCloser(strings.NewReader("<html><body>Under maintenance</body></html>"))}, nil
+	}
+	return (&samlMock{}).RoundTrip(req)
+}
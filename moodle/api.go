@@ -0,0 +1,340 @@
+package moodle
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// EventsLimit is the number of events fetched at once. Moodle does not return more than 50.
+const EventsLimit = 50
+
+// Course is an enrolled course.
+type Course struct {
+	ID        int       `json:"id"`
+	FullName  string    `json:"fullname"`
+	ShortName string    `json:"shortname"`
+	URL       string    `json:"url"`
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+}
+
+type rawCourse struct {
+	ID        int    `json:"id"`
+	FullName  string `json:"fullname"`
+	ShortName string `json:"shortname"`
+	ViewURL   string `json:"viewurl"`
+	StartDate int64  `json:"startdate"`
+	EndDate   int64  `json:"enddate"`
+}
+
+// Courses returns the courses the user is enrolled in, including past and future ones.
+func (c *Client) Courses(ctx context.Context) ([]Course, error) {
+	var result struct {
+		Courses []rawCourse `json:"courses"`
+	}
+	err := c.Call(ctx, "core_course_get_enrolled_courses_by_timeline_classification", map[string]interface{}{
+		"classification": "all",
+		"limit":          0,
+		"offset":         0,
+	}, &result)
+	if err != nil {
+		return nil, err
+	}
+	courses := make([]Course, len(result.Courses))
+	for i, r := range result.Courses {
+		courses[i] = Course{
+			ID:        r.ID,
+			FullName:  r.FullName,
+			ShortName: r.ShortName,
+			URL:       r.ViewURL,
+			Start:     unix(r.StartDate),
+			End:       unix(r.EndDate),
+		}
+	}
+	return courses, nil
+}
+
+// Event is an event on the timeline, such as the due date of an assignment.
+type Event struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Module is the kind of the activity such as "assign" or "quiz".
+	Module string `json:"module"`
+	// Instance is the ID of the activity in the module.
+	Instance   int    `json:"instance"`
+	CourseID   int    `json:"course_id"`
+	CourseName string `json:"course_name"`
+	// Due is the time the event is sorted by, which is the due date for assignments.
+	Due time.Time `json:"due"`
+	URL string    `json:"url"`
+	// Actionable reports whether the user still has to act, such as submitting the assignment.
+	Actionable bool `json:"actionable"`
+}
+
+type rawEvent struct {
+	ID         int    `json:"id"`
+	Name       string `json:"name"`
+	ModuleName string `json:"modulename"`
+	Instance   int    `json:"instance"`
+	TimeSort   int64  `json:"timesort"`
+	URL        string `json:"url"`
+	Course     struct {
+		ID       int    `json:"id"`
+		FullName string `json:"fullname"`
+	} `json:"course"`
+	Action *struct {
+		Actionable bool `json:"actionable"`
+	} `json:"action"`
+}
+
+// Events returns the events on the timeline from given time, in order of the time.
+func (c *Client) Events(ctx context.Context, from time.Time) ([]Event, error) {
+	var events []Event
+	args := map[string]interface{}{
+		"timesortfrom": from.Unix(),
+		"limitnum":     EventsLimit,
+	}
+	for {
+		var result struct {
+			Events []rawEvent `json:"events"`
+			LastID int        `json:"lastid"`
+		}
+		if err := c.Call(ctx, "core_calendar_get_action_events_by_timesort", args, &result); err != nil {
+			return nil, err
+		}
+		for _, r := range result.Events {
+			e := Event{
+				ID:         r.ID,
+				Name:       r.Name,
+				Module:     r.ModuleName,
+				Instance:   r.Instance,
+				CourseID:   r.Course.ID,
+				CourseName: r.Course.FullName,
+				Due:        unix(r.TimeSort),
+				URL:        r.URL,
+			}
+			if r.Action != nil {
+				e.Actionable = r.Action.Actionable
+			}
+			events = append(events, e)
+		}
+		if len(result.Events) < EventsLimit || result.LastID == 0 {
+			return events, nil
+		}
+		args["aftereventid"] = result.LastID
+	}
+}
+
+// Assignments returns the assignments due from given time, in order of the due date.
+func (c *Client) Assignments(ctx context.Context, from time.Time) ([]Event, error) {
+	events, err := c.Events(ctx, from)
+	if err != nil {
+		return nil, err
+	}
+	var assignments []Event
+	for _, e := range events {
+		if e.Module == "assign" {
+			assignments = append(assignments, e)
+		}
+	}
+	return assignments, nil
+}
+
+// Forum is a forum in a course.
+type Forum struct {
+	ID          int    `json:"id"`
+	CourseID    int    `json:"course"`
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Discussions int    `json:"numdiscussions"`
+}
+
+// Forums returns the forums in the courses.
+func (c *Client) Forums(ctx context.Context, courseIDs ...int) ([]Forum, error) {
+	var forums []Forum
+	err := c.Call(ctx, "mod_forum_get_forums_by_courses", map[string]interface{}{"courseids": courseIDs}, &forums)
+	if err != nil {
+		return nil, err
+	}
+	return forums, nil
+}
+
+// Discussion is a thread in a forum.
+type Discussion struct {
+	// ID is the ID of the discussion. Note that Moodle calls the ID of the first post "id".
+	ID       int       `json:"id"`
+	Subject  string    `json:"subject"`
+	Message  string    `json:"message"`
+	Author   string    `json:"author"`
+	Replies  int       `json:"replies"`
+	Pinned   bool      `json:"pinned"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+}
+
+type rawDiscussion struct {
+	Discussion   int    `json:"discussion"`
+	Subject      string `json:"subject"`
+	Message      string `json:"message"`
+	UserFullName string `json:"userfullname"`
+	NumReplies   int    `json:"numreplies"`
+	Pinned       bool   `json:"pinned"`
+	Created      int64  `json:"created"`
+	TimeModified int64  `json:"timemodified"`
+}
+
+// Discussions returns the discussions in the forum, the latest first.
+func (c *Client) Discussions(ctx context.Context, forumID int) ([]Discussion, error) {
+	var result struct {
+		Discussions []rawDiscussion `json:"discussions"`
+	}
+	err := c.Call(ctx, "mod_forum_get_forum_discussions", map[string]interface{}{"forumid": forumID}, &result)
+	if err != nil {
+		return nil, err
+	}
+	discussions := make([]Discussion, len(result.Discussions))
+	for i, r := range result.Discussions {
+		discussions[i] = Discussion{
+			ID:       r.Discussion,
+			Subject:  r.Subject,
+			Message:  r.Message,
+			Author:   r.UserFullName,
+			Replies:  r.NumReplies,
+			Pinned:   r.Pinned,
+			Created:  unix(r.Created),
+			Modified: unix(r.TimeModified),
+		}
+	}
+	return discussions, nil
+}
+
+// Post is a post in a discussion.
+type Post struct {
+	ID int `json:"id"`
+	// ParentID is the post replied to. It is zero for the first post.
+	ParentID int       `json:"parent_id"`
+	Subject  string    `json:"subject"`
+	Message  string    `json:"message"`
+	Author   string    `json:"author"`
+	Created  time.Time `json:"created"`
+}
+
+type rawPost struct {
+	ID          int    `json:"id"`
+	ParentID    int    `json:"parentid"`
+	Subject     string `json:"subject"`
+	Message     string `json:"message"`
+	TimeCreated int64  `json:"timecreated"`
+	Author      struct {
+		FullName string `json:"fullname"`
+	} `json:"author"`
+}
+
+// Posts returns the posts in the discussion.
+func (c *Client) Posts(ctx context.Context, discussionID int) ([]Post, error) {
+	var result struct {
+		Posts []rawPost `json:"posts"`
+	}
+	err := c.Call(ctx, "mod_forum_get_discussion_posts", map[string]interface{}{"discussionid": discussionID}, &result)
+	if err != nil {
+		return nil, err
+	}
+	posts := make([]Post, len(result.Posts))
+	for i, r := range result.Posts {
+		posts[i] = Post{
+			ID:       r.ID,
+			ParentID: r.ParentID,
+			Subject:  r.Subject,
+			Message:  r.Message,
+			Author:   r.Author.FullName,
+			Created:  unix(r.TimeCreated),
+		}
+	}
+	return posts, nil
+}
+
+// Section is a section of the course page.
+type Section struct {
+	ID      int      `json:"id"`
+	Name    string   `json:"name"`
+	Modules []Module `json:"modules"`
+}
+
+// Module is an activity or a resource in a section.
+type Module struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+	// Type is the kind of the module such as "resource", "folder" or "assign".
+	Type  string `json:"modname"`
+	URL   string `json:"url"`
+	Files []File `json:"contents"`
+}
+
+// File is a file of a resource.
+type File struct {
+	Name     string `json:"filename"`
+	URL      string `json:"fileurl"`
+	Size     int64  `json:"filesize"`
+	MimeType string `json:"mimetype"`
+	// Modified is the unix time the file is modified.
+	Modified int64 `json:"timemodified"`
+}
+
+// Contents returns the sections of the course with the modules and the files in them.
+func (c *Client) Contents(ctx context.Context, courseID int) ([]Section, error) {
+	var sections []Section
+	err := c.Call(ctx, "core_course_get_contents", map[string]interface{}{"courseid": courseID}, &sections)
+	if err != nil {
+		return nil, err
+	}
+	return sections, nil
+}
+
+// Download opens the file. The caller should close the returned body.
+// With the web service token, the file is fetched from the web service instead of with the session.
+// Files outside BaseURL are refused with HostError, so neither the token nor the session leaks to other sites.
+func (c *Client) Download(ctx context.Context, f File) (io.ReadCloser, error) {
+	u, err := c.BaseURL.Parse(f.URL)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme != c.BaseURL.Scheme || !strings.EqualFold(u.Host, c.BaseURL.Host) {
+		return nil, &HostError{URL: u.String()}
+	}
+	c.mu.Lock()
+	token := c.Token
+	c.mu.Unlock()
+	if token != "" {
+		if !strings.Contains(u.Path, "/webservice/pluginfile.php") {
+			u.Path = strings.Replace(u.Path, "/pluginfile.php", "/webservice/pluginfile.php", 1)
+		}
+		q := u.Query()
+		q.Set("token", token)
+		u.RawQuery = q.Encode()
+	} else {
+		// The files from the web service need the token, which the session can not replace.
+		u.Path = strings.Replace(u.Path, "/webservice/pluginfile.php", "/pluginfile.php", 1)
+		q := u.Query()
+		q.Del("token")
+		u.RawQuery = q.Encode()
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.open(req.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+func unix(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
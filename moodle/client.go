@@ -0,0 +1,391 @@
+// Package moodle provides access to Moodle behind the Shibboleth authentication.
+// It signs on with the session of the auth server, and calls the web service functions of Moodle
+// with the session or with the web service token of the mobile app.
+package moodle
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+// DefaultBaseURL is the url of Moodle of the university.
+const DefaultBaseURL = "https://moodle.cis.kit.ac.jp/"
+
+// Paths relative to Client.BaseURL.
+var (
+	// LoginPath starts the Shibboleth authentication of Moodle.
+	LoginPath = "auth/shibboleth/index.php"
+	// DashboardPath is the page to find the session key.
+	DashboardPath = "my/"
+	// AJAXPath is the service called with the session key.
+	AJAXPath = "lib/ajax/service.php"
+	// RESTPath is the service called with the web service token.
+	RESTPath = "webservice/rest/server.php"
+	// LaunchPath issues the web service token of the mobile app.
+	LaunchPath = "admin/tool/mobile/launch.php"
+)
+
+// MobileService is the name of the web service of the mobile app.
+const MobileService = "moodle_mobile_app"
+
+const urlScheme = "moodlemobile"
+
+var sesskeyPattern = regexp.MustCompile(`"sesskey":"([^"]+)"`)
+
+// sessionErrors are the error codes of Moodle telling the session has been expired.
+var sessionErrors = map[string]bool{
+	"servicerequireslogin": true,
+	"requireloginerror":    true,
+	"invalidsesskey":       true,
+}
+
+// Client calls Moodle with the session of the logged-in http.Client.
+// It is safe for concurrent use.
+type Client struct {
+	HTTPClient *http.Client
+	BaseURL    *url.URL
+	// Auth is used to login to the auth server when it has no session. Login fails with
+	// kitwalk.SessionExpiredError in that case when it is nil.
+	Auth kitwalk.Auth
+	// Token is the web service token. The functions are called through the AJAX service
+	// with the session when it is empty, where only the functions allowed for AJAX are available.
+	Token string
+
+	mu      sync.Mutex
+	sesskey string
+}
+
+// NewClient create new Moodle client with given authenticator and http.Client.
+// The client needs a cookie jar, and kitwalk.Jar is set when it has none.
+func NewClient(auth kitwalk.Auth, client *http.Client) *Client {
+	base, _ := url.Parse(DefaultBaseURL)
+	return &Client{HTTPClient: kitwalk.SignOnClient(client), BaseURL: base, Auth: auth}
+}
+
+// Login signs on to Moodle through the auth server, and keeps the session key of Moodle.
+// When the auth server has no session, it logs in with Auth and tries once more.
+func (c *Client) Login(ctx context.Context) error {
+	target, err := c.BaseURL.Parse(LoginPath)
+	if err != nil {
+		return err
+	}
+	if err := kitwalk.SignOnWith(ctx, c.HTTPClient, c.Auth, target.String()); err != nil {
+		return err
+	}
+	body, err := c.get(ctx, DashboardPath)
+	if err != nil {
+		return err
+	}
+	m := sesskeyPattern.FindSubmatch(body)
+	if m == nil {
+		return &ParseError{errMsg: "The session key is not found in the dashboard."}
+	}
+	c.mu.Lock()
+	c.sesskey = string(m[1])
+	c.mu.Unlock()
+	return nil
+}
+
+// RequestToken obtains the web service token of the mobile app with the session, and sets it to Token.
+// TokenError is returned when the site does not allow the mobile app. Login first.
+func (c *Client) RequestToken(ctx context.Context) (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	passport := hex.EncodeToString(b)
+	u, err := c.BaseURL.Parse(LaunchPath)
+	if err != nil {
+		return "", err
+	}
+	u.RawQuery = url.Values{
+		"service":   {MobileService},
+		"passport":  {passport},
+		"urlscheme": {urlScheme},
+	}.Encode()
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	// The token is given as a redirect to the url scheme of the app, which must not be followed.
+	client := *c.HTTPClient
+	client.CheckRedirect = func(req *http.Request, via []*http.Request) error {
+		if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+			return http.ErrUseLastResponse
+		}
+		if len(via) >= 10 {
+			return errors.New("stopped after 10 redirects")
+		}
+		return nil
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.Request.URL.Host == kitwalk.DefaultAuthDomain {
+		return "", &kitwalk.SessionExpiredError{URL: u.String()}
+	}
+	loc := resp.Header.Get("Location")
+	if !strings.HasPrefix(loc, urlScheme+"://token=") {
+		return "", &TokenError{errMsg: "The site does not issue the token of the mobile app."}
+	}
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(loc, urlScheme+"://token="))
+	if err != nil {
+		return "", &TokenError{errMsg: err.Error()}
+	}
+	// The token is "signature:::token" or "signature:::token:::private token",
+	// where the signature is md5 of the site url and the passport.
+	parts := strings.Split(string(decoded), ":::")
+	if len(parts) < 2 || parts[1] == "" {
+		return "", &TokenError{errMsg: "The token is malformed."}
+	}
+	site := strings.TrimSuffix(c.BaseURL.String(), "/")
+	if sum := md5.Sum([]byte(site + passport)); parts[0] != hex.EncodeToString(sum[:]) {
+		return "", &TokenError{errMsg: "The signature of the token does not match."}
+	}
+	c.mu.Lock()
+	c.Token = parts[1]
+	c.mu.Unlock()
+	return parts[1], nil
+}
+
+// Call calls the web service function with the arguments, and decodes the returned data into result.
+// result may be nil. The function is called with Token when it is set, and with the session otherwise.
+// When the session has been expired, it logs in again and calls once more.
+func (c *Client) Call(ctx context.Context, function string, args map[string]interface{}, result interface{}) error {
+	c.mu.Lock()
+	token, sesskey := c.Token, c.sesskey
+	c.mu.Unlock()
+	if token != "" {
+		return c.callREST(ctx, token, function, args, result)
+	}
+	if sesskey == "" {
+		if err := c.Login(ctx); err != nil {
+			return err
+		}
+		return c.Call(ctx, function, args, result)
+	}
+	err := c.callAJAX(ctx, sesskey, function, args, result)
+	if !isSessionError(err) {
+		return err
+	}
+	if err := c.Login(ctx); err != nil {
+		return err
+	}
+	c.mu.Lock()
+	sesskey = c.sesskey
+	c.mu.Unlock()
+	return c.callAJAX(ctx, sesskey, function, args, result)
+}
+
+func isSessionError(err error) bool {
+	switch e := err.(type) {
+	case *kitwalk.SessionExpiredError:
+		return true
+	case *APIError:
+		return sessionErrors[e.ErrorCode]
+	}
+	return false
+}
+
+// ajaxResponse is the response of the AJAX service for a function.
+type ajaxResponse struct {
+	Error     bool            `json:"error"`
+	Data      json.RawMessage `json:"data"`
+	Exception *exception      `json:"exception"`
+}
+
+// exception is the error returned by the web services.
+type exception struct {
+	Exception string `json:"exception"`
+	ErrorCode string `json:"errorcode"`
+	Message   string `json:"message"`
+}
+
+func (c *Client) callAJAX(ctx context.Context, sesskey, function string, args map[string]interface{}, result interface{}) error {
+	if args == nil {
+		args = map[string]interface{}{}
+	}
+	payload, err := json.Marshal([]map[string]interface{}{
+		{"index": 0, "methodname": function, "args": args},
+	})
+	if err != nil {
+		return err
+	}
+	u, err := c.BaseURL.Parse(AJAXPath)
+	if err != nil {
+		return err
+	}
+	u.RawQuery = url.Values{"sesskey": {sesskey}, "info": {function}}.Encode()
+	req, err := http.NewRequest(http.MethodPost, u.String(), bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	body, err := c.do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	// Errors before calling the function, such as an expired session, are returned as an object.
+	if e := new(exception); json.Unmarshal(body, e) == nil && e.ErrorCode != "" {
+		return &APIError{Function: function, ErrorCode: e.ErrorCode, Message: e.Message}
+	}
+	var responses []ajaxResponse
+	if err := json.Unmarshal(body, &responses); err != nil || len(responses) == 0 {
+		return &ParseError{errMsg: fmt.Sprintf("Unexpected response of '%s'.", function)}
+	}
+	r := responses[0]
+	if r.Error {
+		if r.Exception == nil {
+			return &APIError{Function: function}
+		}
+		return &APIError{Function: function, ErrorCode: r.Exception.ErrorCode, Message: r.Exception.Message}
+	}
+	return decode(function, r.Data, result)
+}
+
+func (c *Client) callREST(ctx context.Context, token, function string, args map[string]interface{}, result interface{}) error {
+	values := url.Values{}
+	for name, v := range args {
+		flatten(values, name, reflect.ValueOf(v))
+	}
+	values.Set("wstoken", token)
+	values.Set("wsfunction", function)
+	values.Set("moodlewsrestformat", "json")
+	u, err := c.BaseURL.Parse(RESTPath)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, u.String(), strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	body, err := c.do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if e := new(exception); json.Unmarshal(body, e) == nil && e.Exception != "" {
+		return &APIError{Function: function, ErrorCode: e.ErrorCode, Message: e.Message}
+	}
+	return decode(function, body, result)
+}
+
+func decode(function string, data []byte, result interface{}) error {
+	if result == nil {
+		return nil
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return &ParseError{errMsg: fmt.Sprintf("Unexpected data of '%s'. %s", function, err)}
+	}
+	return nil
+}
+
+// flatten sets the argument in the form of PHP, such as "courseids[0]=1" or "options[0][name]=x".
+func flatten(values url.Values, name string, v reflect.Value) {
+	if v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			flatten(values, fmt.Sprintf("%s[%d]", name, i), v.Index(i))
+		}
+	case reflect.Map:
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return fmt.Sprint(keys[i]) < fmt.Sprint(keys[j]) })
+		for _, key := range keys {
+			flatten(values, fmt.Sprintf("%s[%v]", name, key), v.MapIndex(key))
+		}
+	case reflect.Bool:
+		if v.Bool() {
+			values.Set(name, "1")
+		} else {
+			values.Set(name, "0")
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		values.Set(name, strconv.FormatInt(v.Int(), 10))
+	default:
+		values.Set(name, fmt.Sprint(v.Interface()))
+	}
+}
+
+func (c *Client) get(ctx context.Context, ref string) ([]byte, error) {
+	u, err := c.BaseURL.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req.WithContext(ctx))
+}
+
+// do sends the request and reads the response body.
+func (c *Client) do(req *http.Request) ([]byte, error) {
+	resp, err := c.open(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// open sends the request, and checks the status and the session.
+func (c *Client) open(req *http.Request) (*http.Response, error) {
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		if e, ok := err.(*url.Error); ok {
+			redacted := *e
+			redacted.URL = redact(req.URL)
+			return nil, &redacted
+		}
+		return nil, err
+	}
+	if resp.Request.URL.Host == kitwalk.DefaultAuthDomain || strings.HasSuffix(resp.Request.URL.Path, "/login/index.php") {
+		resp.Body.Close()
+		return nil, &kitwalk.SessionExpiredError{URL: redact(req.URL)}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{URL: redact(req.URL), StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+// redact hides the web service token in the url for error messages.
+func redact(u *url.URL) string {
+	q := u.Query()
+	if q.Get("token") == "" {
+		return u.String()
+	}
+	q.Set("token", "REDACTED")
+	redacted := *u
+	redacted.RawQuery = q.Encode()
+	return redacted.String()
+}
@@ -0,0 +1,51 @@
+package moodle
+
+import "fmt"
+
+// StatusError will be return when Moodle responds with unexpected status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Unexpected status code %d from '%s'.", e.StatusCode, e.URL)
+}
+
+// APIError will be return when a web service function fails.
+type APIError struct {
+	Function  string
+	ErrorCode string
+	Message   string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("Moodle function '%s' failed with '%s'. %s", e.Function, e.ErrorCode, e.Message)
+}
+
+// TokenError will be return when Moodle does not issue the web service token.
+type TokenError struct {
+	errMsg string
+}
+
+func (e *TokenError) Error() string {
+	return fmt.Sprintf("Could not obtain the web service token. %s", e.errMsg)
+}
+
+// ParseError will be return when the page of Moodle does not have expected content.
+type ParseError struct {
+	errMsg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Could not parse the page of Moodle. %s", e.errMsg)
+}
+
+// HostError will be return when a file to download is not on Moodle.
+type HostError struct {
+	URL string
+}
+
+func (e *HostError) Error() string {
+	return fmt.Sprintf("The url '%s' is not on Moodle.", e.URL)
+}
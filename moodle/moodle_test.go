@@ -0,0 +1,278 @@
+package moodle
+
+import (
+	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+const (
+	testSite    = "https://moodle.example.ac.jp"
+	testSesskey = "sess123"
+	testToken   = "token456"
+)
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// responses are the data returned by the web service functions.
+var responses = map[string]string{
+	"core_course_get_enrolled_courses_by_timeline_classification": `{"courses":[` +
+		`{"id":10,"fullname":"線形代数学I","shortname":"LA1","viewurl":"https://moodle.example.ac.jp/course/view.php?id=10","startdate":1554044400,"enddate":0}]}`,
+	"core_calendar_get_action_events_by_timesort": `{"events":[` +
+		`{"id":1,"name":"レポート1の提出期限","modulename":"assign","instance":5,"timesort":1557824400,"url":"https://moodle.example.ac.jp/mod/assign/view.php?id=7","course":{"id":10,"fullname":"線形代数学I"},"action":{"actionable":true}},` +
+		`{"id":2,"name":"小テスト1","modulename":"quiz","instance":3,"timesort":1557910800,"course":{"id":10,"fullname":"線形代数学I"}}],"lastid":2}`,
+	"mod_forum_get_forums_by_courses": `[{"id":3,"course":10,"name":"ニュースフォーラム","type":"news","numdiscussions":1}]`,
+	"mod_forum_get_forum_discussions": `{"discussions":[{"id":100,"discussion":30,"subject":"休講のお知らせ","message":"<p>5月14日は休講です。</p>","userfullname":"山田 太郎","numreplies":0,"pinned":false,"created":1557000000,"timemodified":1557000000}]}`,
+	"mod_forum_get_discussion_posts":  `{"posts":[{"id":100,"parentid":0,"subject":"休講のお知らせ","message":"<p>5月14日は休講です。</p>","timecreated":1557000000,"author":{"fullname":"山田 太郎"}}]}`,
+	"core_course_get_contents": `[{"id":1,"name":"第1回","modules":[{"id":7,"name":"講義資料","modname":"resource","url":"https://moodle.example.ac.jp/mod/resource/view.php?id=7",` +
+		`"contents":[{"filename":"slide.pdf","fileurl":"https://moodle.example.ac.jp/webservice/pluginfile.php/20/mod_resource/content/1/slide.pdf?forcedownload=1","filesize":4,"mimetype":"application/pdf","timemodified":1557000000}]}]}]`,
+}
+
+// mock is Moodle behind the auth server which has the session.
+type mock struct {
+	// Calls are the functions called, with "ajax:" or "rest:" prefix.
+	Calls []string
+	// Expire makes the next AJAX call fail with an expired session.
+	Expire bool
+	// NoToken makes the site refuse the token of the mobile app.
+	NoToken bool
+}
+
+func (m *mock) RoundTrip(req *http.Request) (*http.Response, error) {
+	rec := httptest.NewRecorder()
+	m.serve(rec, req)
+	resp := rec.Result()
+	resp.Request = req
+	return resp, nil
+}
+
+func (m *mock) serve(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Host == kitwalk.DefaultAuthDomain {
+		fmt.Fprint(w, `<form action="`+testSite+`/Shibboleth.sso/SAML2/POST" method="post">`+
+			`<input type="hidden" name="RelayState" value="state"/><input type="hidden" name="SAMLResponse" value="response"/></form>`)
+		return
+	}
+	switch req.URL.Path {
+	case "/Shibboleth.sso/SAML2/POST":
+		http.SetCookie(w, &http.Cookie{Name: "MoodleSession", Value: "moodle", Path: "/"})
+		http.Redirect(w, req, testSite+"/my/", http.StatusFound)
+		return
+	case "/webservice/rest/server.php":
+		req.ParseForm()
+		function := req.PostForm.Get("wsfunction")
+		m.Calls = append(m.Calls, "rest:"+function)
+		if req.PostForm.Get("wstoken") != testToken {
+			fmt.Fprint(w, `{"exception":"moodle_exception","errorcode":"invalidtoken","message":"Invalid token"}`)
+			return
+		}
+		if function == "mod_forum_get_forums_by_courses" && req.PostForm.Get("courseids[0]") != "10" {
+			fmt.Fprint(w, `{"exception":"invalid_parameter_exception","errorcode":"invalidparameter","message":"courseids"}`)
+			return
+		}
+		fmt.Fprint(w, responses[function])
+		return
+	case "/webservice/pluginfile.php/20/mod_resource/content/1/slide.pdf":
+		if req.URL.Query().Get("token") != testToken {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		fmt.Fprint(w, "%PDF")
+		return
+	}
+	if _, err := req.Cookie("MoodleSession"); err != nil {
+		http.Redirect(w, req, "https://"+kitwalk.DefaultAuthDomain+"/idp/profile/SAML2/Redirect/SSO", http.StatusFound)
+		return
+	}
+	switch req.URL.Path {
+	case "/auth/shibboleth/index.php":
+		http.Redirect(w, req, testSite+"/my/", http.StatusFound)
+	case "/my/":
+		fmt.Fprint(w, `<script>M.cfg = {"wwwroot":"`+testSite+`","sesskey":"`+testSesskey+`","themerev":"1"};</script>`)
+	case "/lib/ajax/service.php":
+		var calls []struct {
+			MethodName string                 `json:"methodname"`
+			Args       map[string]interface{} `json:"args"`
+		}
+		json.NewDecoder(req.Body).Decode(&calls)
+		m.Calls = append(m.Calls, "ajax:"+calls[0].MethodName)
+		if m.Expire || req.URL.Query().Get("sesskey") != testSesskey {
+			m.Expire = false
+			fmt.Fprint(w, `{"error":"Your session has most likely timed out.","errorcode":"servicerequireslogin"}`)
+			return
+		}
+		data, ok := responses[calls[0].MethodName]
+		if !ok {
+			fmt.Fprint(w, `[{"error":true,"exception":{"errorcode":"servicenotavailable","message":"Web service is not available"}}]`)
+			return
+		}
+		fmt.Fprint(w, `[{"error":false,"data":`+data+`}]`)
+	case "/admin/tool/mobile/launch.php":
+		if m.NoToken {
+			fmt.Fprint(w, "Mobile app is not enabled.")
+			return
+		}
+		sum := md5.Sum([]byte(testSite + req.URL.Query().Get("passport")))
+		token := base64.StdEncoding.EncodeToString([]byte(hex.EncodeToString(sum[:]) + ":::" + testToken))
+		w.Header().Set("Location", "moodlemobile://token="+token)
+		w.WriteHeader(http.StatusSeeOther)
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+func newTestClient(m *mock) *Client {
+	c := NewClient(nil, &http.Client{Transport: m})
+	c.BaseURL, _ = url.Parse(testSite + "/")
+	return c
+}
+
+func TestClient_Login(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	t.Run("Sign on and call with the session", func(t *testing.T) {
+		m := &mock{}
+		c := newTestClient(m)
+		check(t, c.Login(ctx))
+		courses, err := c.Courses(ctx)
+		check(t, err)
+		if len(courses) != 1 || courses[0].FullName != "線形代数学I" || courses[0].Start.Year() != 2019 || !courses[0].End.IsZero() {
+			t.Errorf("Expect: 線形代数学I from 2019\nActual: %+v\n", courses)
+		}
+		if m.Calls[0] != "ajax:core_course_get_enrolled_courses_by_timeline_classification" {
+			t.Errorf("Expect: called through AJAX\nActual: %v\n", m.Calls)
+		}
+	})
+	t.Run("Login again when the session is expired", func(t *testing.T) {
+		m := &mock{}
+		c := newTestClient(m)
+		check(t, c.Login(ctx))
+		m.Expire = true
+		_, err := c.Courses(ctx)
+		check(t, err)
+		if len(m.Calls) != 2 {
+			t.Errorf("Expect: called twice\nActual: %v\n", m.Calls)
+		}
+	})
+	t.Run("Return API error", func(t *testing.T) {
+		c := newTestClient(&mock{})
+		err := c.Call(ctx, "core_user_get_users", nil, nil)
+		switch e := err.(type) {
+		case *APIError:
+			if e.ErrorCode != "servicenotavailable" {
+				t.Errorf("Expect: servicenotavailable\nActual: %s\n", e.ErrorCode)
+			}
+		default:
+			t.Errorf("Expect: APIError\nActual: %v\n", err)
+		}
+	})
+}
+
+func TestClient_RequestToken(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	t.Run("Call with the token", func(t *testing.T) {
+		m := &mock{}
+		c := newTestClient(m)
+		check(t, c.Login(ctx))
+		token, err := c.RequestToken(ctx)
+		check(t, err)
+		if token != testToken || c.Token != testToken {
+			t.Errorf("Expect: %s\nActual: %s\n", testToken, token)
+		}
+
+		assignments, err := c.Assignments(ctx, time.Unix(1557000000, 0))
+		check(t, err)
+		if len(assignments) != 1 || assignments[0].Name != "レポート1の提出期限" || !assignments[0].Due.Equal(time.Unix(1557824400, 0)) || !assignments[0].Actionable {
+			t.Errorf("Expect: レポート1の提出期限\nActual: %+v\n", assignments)
+		}
+		forums, err := c.Forums(ctx, 10)
+		check(t, err)
+		if len(forums) != 1 || forums[0].Name != "ニュースフォーラム" {
+			t.Fatalf("Expect: ニュースフォーラム\nActual: %+v\n", forums)
+		}
+		discussions, err := c.Discussions(ctx, forums[0].ID)
+		check(t, err)
+		if len(discussions) != 1 || discussions[0].ID != 30 || discussions[0].Author != "山田 太郎" {
+			t.Fatalf("Expect: discussion 30\nActual: %+v\n", discussions)
+		}
+		posts, err := c.Posts(ctx, discussions[0].ID)
+		check(t, err)
+		if len(posts) != 1 || posts[0].Subject != "休講のお知らせ" {
+			t.Errorf("Expect: 休講のお知らせ\nActual: %+v\n", posts)
+		}
+
+		sections, err := c.Contents(ctx, 10)
+		check(t, err)
+		if len(sections) != 1 || len(sections[0].Modules) != 1 || len(sections[0].Modules[0].Files) != 1 {
+			t.Fatalf("Expect: a file in a module\nActual: %+v\n", sections)
+		}
+		body, err := c.Download(ctx, sections[0].Modules[0].Files[0])
+		check(t, err)
+		defer body.Close()
+		data, err := ioutil.ReadAll(body)
+		check(t, err)
+		if string(data) != "%PDF" {
+			t.Errorf("Expect: %%PDF\nActual: %s\n", data)
+		}
+		for _, call := range m.Calls {
+			if call[:5] != "rest:" {
+				t.Errorf("Expect: called with the token\nActual: %s\n", call)
+			}
+		}
+		external := File{URL: "https://files.example.com/pluginfile.php/1/a.pdf"}
+		if _, err := c.Download(ctx, external); err == nil {
+			t.Error("Expect: HostError\nActual: (nil)")
+		} else if _, ok := err.(*HostError); !ok {
+			t.Errorf("Expect: HostError\nActual: %v\n", err)
+		}
+		missing := File{URL: "https://moodle.example.ac.jp/pluginfile.php/1/missing.pdf"}
+		if _, err := c.Download(ctx, missing); err == nil {
+			t.Error("Expect: StatusError\nActual: (nil)")
+		} else if e, ok := err.(*StatusError); !ok || strings.Contains(e.Error(), testToken) {
+			t.Errorf("Expect: StatusError without the token\nActual: %v\n", err)
+		}
+	})
+	t.Run("Fail when the mobile app is disabled", func(t *testing.T) {
+		c := newTestClient(&mock{NoToken: true})
+		check(t, c.Login(ctx))
+		_, err := c.RequestToken(ctx)
+		switch err.(type) {
+		case *TokenError:
+		default:
+			t.Errorf("Expect: TokenError\nActual: %v\n", err)
+		}
+	})
+}
+
+func TestFlatten(t *testing.T) {
+	t.Parallel()
+	values := url.Values{}
+	for name, v := range map[string]interface{}{
+		"courseids": []int{1, 2},
+		"options":   []map[string]interface{}{{"name": "x", "value": true}},
+	} {
+		flatten(values, name, reflect.ValueOf(v))
+	}
+	expected := "courseids%5B0%5D=1&courseids%5B1%5D=2&options%5B0%5D%5Bname%5D=x&options%5B0%5D%5Bvalue%5D=1"
+	if values.Encode() != expected {
+		t.Errorf("Expect: %s\nActual: %s\n", expected, values.Encode())
+	}
+}
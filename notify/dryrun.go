@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// DryRun records messages instead of sending them. It is useful to check filters and templates.
+type DryRun struct {
+	// W is optional. When given, the messages are also written to it.
+	W io.Writer
+
+	mu   sync.Mutex
+	sent []Message
+}
+
+// Send records the message.
+func (d *DryRun) Send(ctx context.Context, m Message) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sent = append(d.sent, m)
+	if d.W != nil {
+		_, err := fmt.Fprintf(d.W, "[%s] %s\n%s\n%s\n\n", m.Category, m.Title, m.Body, m.URL)
+		return err
+	}
+	return nil
+}
+
+// Sent returns the recorded messages.
+func (d *DryRun) Sent() []Message {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]Message(nil), d.sent...)
+}
@@ -0,0 +1,85 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"net/smtp"
+	"strings"
+	"time"
+)
+
+// Mail sends the message as a plain text email through a SMTP server.
+type Mail struct {
+	// Addr is the address of the SMTP server such as "smtp.example.com:587".
+	Addr string
+	// Auth is optional. smtp.PlainAuth is typically used.
+	Auth smtp.Auth
+	From string
+	To   []string
+}
+
+// HeaderError will be return when a header of the mail has a line break, which could inject other headers.
+type HeaderError struct {
+	Header string
+}
+
+func (e *HeaderError) Error() string {
+	return fmt.Sprintf("The mail header '%s' has a line break.", e.Header)
+}
+
+// Send sends the message. The title is used as the subject.
+// Context cancellation is checked only before sending, because net/smtp does not support it.
+func (s *Mail) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := s.validate(m); err != nil {
+		return err
+	}
+	return smtp.SendMail(s.Addr, s.Auth, s.From, s.To, s.build(m))
+}
+
+// validate rejects CR and LF in the addresses and the title, which are written in the headers.
+func (s *Mail) validate(m Message) error {
+	fields := [][2]string{{"From", s.From}, {"Subject", m.Title}}
+	for _, to := range s.To {
+		fields = append(fields, [2]string{"To", to})
+	}
+	for _, f := range fields {
+		if strings.ContainsAny(f[1], "\r\n") {
+			return &HeaderError{Header: f[0]}
+		}
+	}
+	return nil
+}
+
+func (s *Mail) build(m Message) []byte {
+	var b bytes.Buffer
+	body := m.Body
+	if m.URL != "" {
+		body += "\n\n" + m.URL
+	}
+	header := [][2]string{
+		{"From", s.From},
+		{"To", strings.Join(s.To, ", ")},
+		{"Subject", mime.BEncoding.Encode("UTF-8", m.Title)},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", "text/plain; charset=UTF-8"},
+		{"Content-Transfer-Encoding", "base64"},
+	}
+	for _, h := range header {
+		b.WriteString(h[0] + ": " + h[1] + "\r\n")
+	}
+	b.WriteString("\r\n")
+	encoded := base64.StdEncoding.EncodeToString([]byte(strings.Replace(body, "\n", "\r\n", -1)))
+	for len(encoded) > 76 {
+		b.WriteString(encoded[:76] + "\r\n")
+		encoded = encoded[76:]
+	}
+	b.WriteString(encoded + "\r\n")
+	return b.Bytes()
+}
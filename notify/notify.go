@@ -0,0 +1,122 @@
+// Package notify pushes messages such as new notices on the portal to webhooks, chat services and email.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"text/template"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+const (
+	// DefaultTitleTemplate is the template of the title used by DefaultFormatter.
+	DefaultTitleTemplate = `{{if .Category}}《{{.Category}}》{{end}}{{.Title}}`
+	// DefaultBodyTemplate is the template of the body used by DefaultFormatter.
+	DefaultBodyTemplate = `{{.Date.Format "2006/01/02"}}{{if .Office}} 〈{{.Office}}〉{{end}}
+{{.Body}}{{range .Attachments}}
+{{.}}{{end}}`
+)
+
+// Message is a notification sent to a sink.
+type Message struct {
+	Title    string    `json:"title"`
+	Body     string    `json:"body"`
+	URL      string    `json:"url,omitempty"`
+	Category string    `json:"category,omitempty"`
+	Time     time.Time `json:"time"`
+}
+
+// Sink sends messages to somewhere.
+type Sink interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// SinkFunc is an adapter to use an ordinary function as a Sink.
+type SinkFunc func(ctx context.Context, m Message) error
+
+// Send calls f(ctx, m).
+func (f SinkFunc) Send(ctx context.Context, m Message) error {
+	return f(ctx, m)
+}
+
+// Formatter converts a notice into a message with templates.
+// The templates are executed with portal.Notice.
+type Formatter struct {
+	Title *template.Template
+	Body  *template.Template
+}
+
+// NewFormatter parses given templates of the title and the body.
+func NewFormatter(title, body string) (*Formatter, error) {
+	t, err := template.New("title").Parse(title)
+	if err != nil {
+		return nil, err
+	}
+	b, err := template.New("body").Parse(body)
+	if err != nil {
+		return nil, err
+	}
+	return &Formatter{Title: t, Body: b}, nil
+}
+
+// DefaultFormatter returns the formatter with DefaultTitleTemplate and DefaultBodyTemplate.
+func DefaultFormatter() *Formatter {
+	f, err := NewFormatter(DefaultTitleTemplate, DefaultBodyTemplate)
+	if err != nil {
+		panic(err)
+	}
+	return f
+}
+
+// Format executes the templates with given notice.
+func (f *Formatter) Format(n portal.Notice) (Message, error) {
+	var title, body bytes.Buffer
+	if err := f.Title.Execute(&title, n); err != nil {
+		return Message{}, err
+	}
+	if err := f.Body.Execute(&body, n); err != nil {
+		return Message{}, err
+	}
+	m := Message{
+		Title:    title.String(),
+		Body:     body.String(),
+		URL:      n.Link,
+		Category: n.Category,
+		Time:     n.Date,
+	}
+	if m.URL == "" && len(n.Attachments) != 0 {
+		m.URL = n.Attachments[0]
+	}
+	return m, nil
+}
+
+// Filter returns a sink which passes only messages in given categories to the sink.
+// Messages in other categories are dropped without error.
+func Filter(sink Sink, categories ...string) Sink {
+	allowed := make(map[string]bool, len(categories))
+	for _, c := range categories {
+		allowed[c] = true
+	}
+	return SinkFunc(func(ctx context.Context, m Message) error {
+		if !allowed[m.Category] {
+			return nil
+		}
+		return sink.Send(ctx, m)
+	})
+}
+
+// Multi returns a sink which sends messages to all of given sinks.
+// All sinks are tried even if some of them fail, and the first error is returned.
+func Multi(sinks ...Sink) Sink {
+	return SinkFunc(func(ctx context.Context, m Message) error {
+		var first error
+		for _, s := range sinks {
+			if err := s.Send(ctx, m); err != nil && first == nil {
+				first = err
+			}
+		}
+		return first
+	})
+}
@@ -0,0 +1,140 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+func check(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFormatter_Format(t *testing.T) {
+	t.Parallel()
+	n := portal.Notice{
+		Date:        time.Date(2018, time.February, 9, 0, 0, 0, 0, portal.JST),
+		Office:      "学務課",
+		Category:    "休講",
+		Title:       "月曜1限の休講",
+		Attachments: []string{"https://example.com/example.pdf"},
+		Body:        "担当教員の出張のため",
+	}
+	m, err := DefaultFormatter().Format(n)
+	check(t, err)
+	if m.Title != "《休講》月曜1限の休講" {
+		t.Errorf("Unexpected title %q", m.Title)
+	}
+	if m.Body != "2018/02/09 〈学務課〉\n担当教員の出張のため\nhttps://example.com/example.pdf" {
+		t.Errorf("Unexpected body %q", m.Body)
+	}
+	if m.URL != n.Attachments[0] || m.Category != "休講" {
+		t.Errorf("Unexpected message %+v", m)
+	}
+}
+
+func TestFilter(t *testing.T) {
+	t.Parallel()
+	d := &DryRun{}
+	s := Filter(d, "休講", "呼出")
+	for _, c := range []string{"休講", "その他", "呼出"} {
+		check(t, s.Send(context.Background(), Message{Category: c}))
+	}
+	if sent := d.Sent(); len(sent) != 2 || sent[0].Category != "休講" || sent[1].Category != "呼出" {
+		t.Errorf("Unexpected messages %+v", sent)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+	t.Run("Retry on server error", func(t *testing.T) {
+		var count int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&count, 1) < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			var payload map[string]string
+			if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+				t.Error(err)
+			}
+			if payload["text"] != "*title*\nbody" {
+				t.Errorf("Unexpected payload %+v", payload)
+			}
+		}))
+		defer server.Close()
+		s := Retry(&Slack{URL: server.URL}, 3, time.Millisecond)
+		check(t, s.Send(context.Background(), Message{Title: "title", Body: "body"}))
+		if count != 3 {
+			t.Errorf("Expect: 3 attempts\nActual: %d attempts\n", count)
+		}
+	})
+	t.Run("Do not retry on client error", func(t *testing.T) {
+		var count int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			atomic.AddInt32(&count, 1)
+			w.WriteHeader(http.StatusNotFound)
+		}))
+		defer server.Close()
+		s := Retry(&Webhook{URL: server.URL}, 3, time.Millisecond)
+		err := s.Send(context.Background(), Message{})
+		if _, ok := err.(*StatusError); !ok || count != 1 {
+			t.Errorf("Expect: StatusError after 1 attempt\nActual: %+v after %d attempts\n", err, count)
+		}
+	})
+	t.Run("Do not retry on wrapped cancellation", func(t *testing.T) {
+		var count int
+		s := Retry(SinkFunc(func(ctx context.Context, m Message) error {
+			count++
+			return &url.Error{Op: "Post", URL: "https://example.com/", Err: context.Canceled}
+		}), 3, time.Millisecond)
+		err := s.Send(context.Background(), Message{})
+		if !errors.Is(err, context.Canceled) || count != 1 {
+			t.Errorf("Expect: canceled after 1 attempt\nActual: %+v after %d attempts\n", err, count)
+		}
+	})
+}
+
+func TestMail_Send(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		mail   Mail
+		title  string
+		header string
+	}{
+		{"Line break in the subject", Mail{From: "kitwalk@example.com", To: []string{"a@example.com"}}, "title\r\nBcc: b@example.com", "Subject"},
+		{"Line break in the sender", Mail{From: "kitwalk@example.com\nBcc: b@example.com", To: []string{"a@example.com"}}, "title", "From"},
+		{"Line break in the recipient", Mail{From: "kitwalk@example.com", To: []string{"a@example.com\r"}}, "title", "To"},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			err := c.mail.Send(context.Background(), Message{Title: c.title})
+			if e, ok := err.(*HeaderError); !ok || e.Header != c.header {
+				t.Errorf("Expect: HeaderError of %s\nActual: %v\n", c.header, err)
+			}
+		})
+	}
+}
+
+func TestPostJSON(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	err := (&Slack{URL: server.URL + "/services/T000/B000/secret"}).Send(context.Background(), Message{Title: "title"})
+	if err == nil || strings.Contains(err.Error(), "secret") {
+		t.Errorf("Expect: error without the path of the webhook\nActual: %v\n", err)
+	}
+}
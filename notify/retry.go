@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"time"
+)
+
+const (
+	// DefaultAttempts is the number of attempts used by Retry when zero is given.
+	DefaultAttempts = 3
+	// DefaultBackoff is the first wait used by Retry when zero is given.
+	DefaultBackoff = time.Second
+)
+
+// Retry returns a sink which retries sending with exponential backoff.
+// The wait doubles for every attempt with random jitter up to a half of it.
+// Errors which are not worth retrying, such as 4xx responses, are returned immediately.
+func Retry(sink Sink, attempts int, backoff time.Duration) Sink {
+	if attempts <= 0 {
+		attempts = DefaultAttempts
+	}
+	if backoff <= 0 {
+		backoff = DefaultBackoff
+	}
+	return SinkFunc(func(ctx context.Context, m Message) error {
+		var err error
+		wait := backoff
+		for i := 0; i < attempts; i++ {
+			if i != 0 {
+				timer := time.NewTimer(wait + time.Duration(rand.Int63n(int64(wait)/2+1)))
+				select {
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				case <-timer.C:
+				}
+				wait *= 2
+			}
+			if err = sink.Send(ctx, m); err == nil || !retryable(err) {
+				return err
+			}
+		}
+		return err
+	})
+}
+
+func retryable(err error) bool {
+	if e, ok := err.(*StatusError); ok {
+		return e.StatusCode >= http.StatusInternalServerError || e.StatusCode == http.StatusTooManyRequests
+	}
+	if _, ok := err.(*HeaderError); ok {
+		return false
+	}
+	// The error of the context is wrapped in *url.Error by http.Client.
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
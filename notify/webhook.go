@@ -0,0 +1,118 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// StatusError will be return when a webhook responds with non-2xx status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Webhook '%s' responded with status code %d.", e.URL, e.StatusCode)
+}
+
+// Webhook posts the message as JSON to given url.
+type Webhook struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send posts the message as it is.
+func (w *Webhook) Send(ctx context.Context, m Message) error {
+	return postJSON(ctx, w.Client, w.URL, m)
+}
+
+// Slack posts the message to an incoming webhook of Slack or compatible services such as Mattermost.
+type Slack struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send posts the message as {"text": "..."}.
+func (s *Slack) Send(ctx context.Context, m Message) error {
+	text := "*" + m.Title + "*\n" + m.Body
+	if m.URL != "" {
+		text += "\n" + m.URL
+	}
+	return postJSON(ctx, s.Client, s.URL, map[string]string{"text": text})
+}
+
+// Discord posts the message to an incoming webhook of Discord.
+type Discord struct {
+	URL    string
+	Client *http.Client
+}
+
+// discordContentLimit is the maximum length of content accepted by Discord.
+const discordContentLimit = 2000
+
+// Send posts the message as {"content": "..."}. Too long content is truncated.
+func (d *Discord) Send(ctx context.Context, m Message) error {
+	content := "**" + m.Title + "**\n" + m.Body
+	if m.URL != "" {
+		content += "\n" + m.URL
+	}
+	if r := []rune(content); len(r) > discordContentLimit {
+		content = string(r[:discordContentLimit-1]) + "…"
+	}
+	return postJSON(ctx, d.Client, d.URL, map[string]string{"content": content})
+}
+
+func postJSON(ctx context.Context, client *http.Client, rawurl string, v interface{}) error {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	body, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, rawurl, bytes.NewReader(body))
+	if err != nil {
+		return redactError(err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return redactError(err)
+	}
+	defer func() {
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}()
+	if resp.StatusCode/100 != 2 {
+		return &StatusError{URL: redact(rawurl), StatusCode: resp.StatusCode}
+	}
+	return nil
+}
+
+// redactError redacts the url in the error of net/http, such as a failed connection.
+func redactError(err error) error {
+	if e, ok := err.(*url.Error); ok {
+		redacted := *e
+		redacted.URL = redact(e.URL)
+		return &redacted
+	}
+	return err
+}
+
+// redact drops the path of webhook urls from error messages, because it usually contains a secret token.
+func redact(rawurl string) string {
+	if i := strings.Index(rawurl, "://"); i >= 0 {
+		if j := strings.Index(rawurl[i+3:], "/"); j >= 0 {
+			return rawurl[:i+3+j] + "/..."
+		}
+	}
+	return rawurl
+}
@@ -0,0 +1,240 @@
+package kitwalk
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultIdleTimeout is how long Pool keeps an account without requests when zero is given.
+	DefaultIdleTimeout = 30 * time.Minute
+	// DefaultMaxConcurrentLogins is the number of logins Pool runs at once when zero is given.
+	DefaultMaxConcurrentLogins = 2
+)
+
+// PasswordFunc returns the password of given user.
+type PasswordFunc func(username string) (string, error)
+
+// Pool keeps the clients of several accounts isolated from each other.
+// Each account has its own http.Client, Jar and authenticator, so cookies are never mixed.
+// Accounts log in lazily on the first request, and are evicted after they are idle for IdleTimeout.
+type Pool struct {
+	// Password returns the password of the account when it is used first.
+	Password PasswordFunc
+	// Transport is used by the clients of all accounts. http.DefaultTransport is used when it is nil.
+	Transport http.RoundTripper
+	// IdleTimeout is how long an account is kept without requests.
+	IdleTimeout time.Duration
+	// MaxConcurrentLogins caps the logins running at once against the auth server.
+	MaxConcurrentLogins int
+	// Limiter is set to the authenticators of the accounts. NewPool sets DefaultLoginLimiter.
+	// Logins are not limited when it is nil.
+	Limiter *LoginLimiter
+
+	ctx      context.Context
+	mu       sync.Mutex
+	accounts map[string]*poolAccount
+	sem      chan struct{}
+}
+
+type poolAccount struct {
+	client *ReloginClient
+	jar    *Jar
+
+	mu       sync.Mutex
+	lastUsed time.Time
+}
+
+func (a *poolAccount) touch() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.lastUsed = time.Now()
+}
+
+func (a *poolAccount) idle() time.Duration {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return time.Since(a.lastUsed)
+}
+
+// NewPool create new pool of accounts whose passwords are given by the function.
+func NewPool(ctx context.Context, password PasswordFunc) *Pool {
+	return &Pool{
+		Password:            password,
+		IdleTimeout:         DefaultIdleTimeout,
+		MaxConcurrentLogins: DefaultMaxConcurrentLogins,
+		Limiter:             DefaultLoginLimiter,
+		ctx:                 ctx,
+	}
+}
+
+// Client returns the client of given user. It is safe for concurrent use.
+// The same client is returned until the account is evicted.
+func (p *Pool) Client(username string) (*ReloginClient, error) {
+	if err := isValidUsername(username); err != nil {
+		return nil, err
+	}
+	if a := p.lookup(username); a != nil {
+		return a.client, nil
+	}
+	password, err := p.Password(username)
+	if err != nil {
+		return nil, err
+	}
+	ctx := p.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	auth, err := NewAuthenticator(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+	auth.(*SamlAuthenticator).Limiter = p.Limiter
+	a := &poolAccount{jar: NewJar(), lastUsed: time.Now()}
+	transport := p.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	client := &http.Client{Jar: a.jar, Transport: &touchTransport{RoundTripper: transport, account: a}}
+	a.client = NewReloginClient(&limitedAuth{Auth: auth, pool: p}, client)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if old, ok := p.accounts[username]; ok {
+		// Another goroutine has created the account meanwhile.
+		old.touch()
+		return old.client, nil
+	}
+	if p.accounts == nil {
+		p.accounts = make(map[string]*poolAccount)
+	}
+	p.accounts[username] = a
+	return a.client, nil
+}
+
+func (p *Pool) lookup(username string) *poolAccount {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	a, ok := p.accounts[username]
+	if !ok {
+		return nil
+	}
+	a.touch()
+	return a
+}
+
+// Jar returns the cookie jar of given user, or nil when the account is not in the pool.
+func (p *Pool) Jar(username string) *Jar {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if a, ok := p.accounts[username]; ok {
+		return a.jar
+	}
+	return nil
+}
+
+// Usernames returns the users in the pool in sorted order.
+func (p *Pool) Usernames() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	names := make([]string, 0, len(p.accounts))
+	for name := range p.accounts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Evict removes the account and its cookies. It reports whether the account was in the pool.
+// The client returned before logs in again if it is still used.
+func (p *Pool) Evict(username string) bool {
+	p.mu.Lock()
+	a, ok := p.accounts[username]
+	delete(p.accounts, username)
+	p.mu.Unlock()
+	if ok {
+		a.jar.Clear()
+	}
+	return ok
+}
+
+// EvictIdle removes the accounts without requests for IdleTimeout, and returns the number of them.
+func (p *Pool) EvictIdle() int {
+	timeout := p.IdleTimeout
+	if timeout <= 0 {
+		timeout = DefaultIdleTimeout
+	}
+	var evicted []*poolAccount
+	p.mu.Lock()
+	for name, a := range p.accounts {
+		if a.idle() >= timeout {
+			evicted = append(evicted, a)
+			delete(p.accounts, name)
+		}
+	}
+	p.mu.Unlock()
+	for _, a := range evicted {
+		a.jar.Clear()
+	}
+	return len(evicted)
+}
+
+// Run evicts idle accounts periodically until the context is canceled.
+func (p *Pool) Run(ctx context.Context) error {
+	timeout := p.IdleTimeout
+	if timeout <= 0 {
+		timeout = DefaultIdleTimeout
+	}
+	ticker := time.NewTicker(timeout / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			p.EvictIdle()
+		}
+	}
+}
+
+// acquire waits for a slot of login.
+func (p *Pool) acquire() func() {
+	p.mu.Lock()
+	if p.sem == nil {
+		n := p.MaxConcurrentLogins
+		if n <= 0 {
+			n = DefaultMaxConcurrentLogins
+		}
+		p.sem = make(chan struct{}, n)
+	}
+	sem := p.sem
+	p.mu.Unlock()
+	sem <- struct{}{}
+	return func() { <-sem }
+}
+
+// limitedAuth logs in within the cap of concurrent logins of the pool.
+type limitedAuth struct {
+	Auth
+	pool *Pool
+}
+
+func (a *limitedAuth) LoginWith(client *http.Client) error {
+	release := a.pool.acquire()
+	defer release()
+	return a.Auth.LoginWith(client)
+}
+
+// touchTransport records the time of requests to find idle accounts.
+type touchTransport struct {
+	http.RoundTripper
+	account *poolAccount
+}
+
+func (t *touchTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.account.touch()
+	return t.RoundTripper.RoundTrip(req)
+}
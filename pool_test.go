@@ -0,0 +1,143 @@
+package kitwalk
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// accountMock is the website and the auth server which issue a session for each user.
+type accountMock struct {
+	mu      sync.Mutex
+	logins  map[string]int
+	running int
+	maxRun  int
+}
+
+func (m *accountMock) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Request: req}
+	body := ""
+	switch {
+	case req.URL.Host != DefaultAuthDomain && req.Method == http.MethodPost:
+		// The SAML response is the username.
+		req.ParseForm()
+		resp.Header.Add("Set-Cookie", (&http.Cookie{Name: SPSessionCookiePrefix + "test", Value: req.PostForm.Get(DefaultSAMLResponseKey), Path: "/"}).String())
+	case req.URL.Host != DefaultAuthDomain:
+		c, err := req.Cookie(SPSessionCookiePrefix + "test")
+		if err != nil {
+			resp.StatusCode = http.StatusFound
+			resp.Header.Set("Location", idpSSOURL)
+			break
+		}
+		body = c.Value
+	case req.Method == http.MethodPost:
+		req.ParseForm()
+		username := req.PostForm.Get(DefaultUnameKey)
+		m.mu.Lock()
+		m.logins[username]++
+		m.running++
+		if m.running > m.maxRun {
+			m.maxRun = m.running
+		}
+		m.mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		m.mu.Lock()
+		m.running--
+		m.mu.Unlock()
+		body = fmt.Sprintf(`<form action="%sShibboleth.sso/SAML2/POST" method="post">`+
+			`<input type="hidden" name="RelayState" value="state"/><input type="hidden" name="SAMLResponse" value="%s"/></form>`,
+			ShibbolethLoginURL, username)
+	default:
+		b, err := ioutil.ReadFile("./samples/auth_form.html")
+		if err != nil {
+			return nil, err
+		}
+		body = string(b)
+	}
+	resp.Body = ioutil.NopCloser(strings.NewReader(body))
+	return resp, nil
+}
+
+func TestPool(t *testing.T) {
+	t.Parallel()
+	users := []string{"b1234567", "m8234567", "d9234567", "b0234567"}
+	mock := &accountMock{logins: make(map[string]int)}
+	pool := NewPool(context.Background(), func(username string) (string, error) {
+		return "password of " + username, nil
+	})
+	pool.Transport = mock
+	pool.MaxConcurrentLogins = 1
+	// Keep DefaultLoginLimiter for other tests, and do not wait long for the logins.
+	pool.Limiter = NewLoginLimiter()
+	pool.Limiter.Interval = time.Millisecond
+
+	t.Run("Isolate accounts", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for _, u := range users {
+			for i := 0; i < 2; i++ {
+				wg.Add(1)
+				go func(username string) {
+					defer wg.Done()
+					client, err := pool.Client(username)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					resp, err := client.Get(ShibbolethLoginURL)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					defer resp.Body.Close()
+					b, _ := ioutil.ReadAll(resp.Body)
+					if string(b) != username {
+						t.Errorf("Expect: %s\nActual: %s\n", username, b)
+					}
+				}(u)
+			}
+		}
+		wg.Wait()
+		for _, u := range users {
+			if mock.logins[u] != 1 {
+				t.Errorf("Expect: 1 login of %s\nActual: %d\n", u, mock.logins[u])
+			}
+		}
+		if mock.maxRun != 1 {
+			t.Errorf("Expect: 1 concurrent login\nActual: %d\n", mock.maxRun)
+		}
+		if names := pool.Usernames(); len(names) != len(users) {
+			t.Errorf("Unexpected users %v", names)
+		}
+	})
+	t.Run("Reject invalid username", func(t *testing.T) {
+		_, err := pool.Client(invalidUsername)
+		switch e := err.(type) {
+		case *InvalidUsernameError:
+			// Expected
+		default:
+			t.Errorf("Expected: InvalidUsernameError\nActual: %+v\n", e)
+		}
+	})
+	t.Run("Evict idle accounts", func(t *testing.T) {
+		jar := pool.Jar(users[0])
+		if jar == nil || len(jar.All()) == 0 {
+			t.Fatal("Expect: cookies of the account\nActual: none")
+		}
+		pool.IdleTimeout = time.Hour
+		if n := pool.EvictIdle(); n != 0 {
+			t.Errorf("Expect: 0\nActual: %d\n", n)
+		}
+		pool.IdleTimeout = time.Nanosecond
+		if n := pool.EvictIdle(); n != len(users) {
+			t.Errorf("Expect: %d\nActual: %d\n", len(users), n)
+		}
+		if len(jar.All()) != 0 || pool.Jar(users[0]) != nil {
+			t.Error("Expect: evicted account without cookies\nActual: still kept")
+		}
+	})
+}
@@ -0,0 +1,90 @@
+// Package portal provides typed access to the student portal behind the Shibboleth authentication.
+// Login with kitwalk first, and give the logged-in http.Client to this package.
+package portal
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+// Client fetches pages of the portal with the logged-in http.Client.
+type Client struct {
+	HTTPClient *http.Client
+	BaseURL    *url.URL
+	// Fields are the names of the fields of main_form. They have no defaults; see FormFields.
+	Fields FormFields
+	// Pages locate the pages other than the front page. They have no defaults; see Pages.
+	Pages Pages
+}
+
+// Page locates a page of the portal and the table to parse on it.
+type Page struct {
+	// Path is relative to Client.BaseURL, such as "ead/?c=timetable".
+	Path string
+	// Table selects the table, such as "table.timetable".
+	Table string
+}
+
+// IsSet reports whether both of Path and Table are set.
+func (p Page) IsSet() bool {
+	return p.Path != "" && p.Table != ""
+}
+
+// Pages are the pages of the portal shown through main_form. Their urls and layouts differ by account and term,
+// and kitwalk has no capture of them, so set the ones you use from the pages of your account.
+type Pages struct {
+	// Timetable is the page of the registered courses ("時間割").
+	Timetable Page
+	// Unscheduled selects the table of the courses without fixed time, such as intensive courses,
+	// on the timetable page. It may be empty.
+	Unscheduled string
+	// Grades is the page of the grades ("成績照会").
+	Grades Page
+	// Registration is the page of the registration list ("履修登録確認").
+	Registration Page
+}
+
+// NewClient create new portal client with given http.Client.
+// The client should be logged in with kitwalk.Auth beforehand.
+func NewClient(client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base, _ := url.Parse(kitwalk.ShibbolethLoginURL)
+	return &Client{
+		HTTPClient: client,
+		BaseURL:    base,
+	}
+}
+
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.Request.URL.Host == kitwalk.DefaultAuthDomain {
+		resp.Body.Close()
+		return nil, &kitwalk.SessionExpiredError{URL: req.URL.String()}
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, &StatusError{URL: req.URL.String(), StatusCode: resp.StatusCode}
+	}
+	return resp, nil
+}
+
+func (c *Client) get(ctx context.Context, ref string) (*http.Response, error) {
+	u, err := c.BaseURL.Parse(ref)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	return c.do(req)
+}
@@ -0,0 +1,40 @@
+package portal
+
+import "fmt"
+
+// StatusError will be return when the portal responds with unexpected status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Unexpected status code %d from '%s'.", e.StatusCode, e.URL)
+}
+
+// ParseError will be return when the page does not have expected structure.
+type ParseError struct {
+	errMsg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Could not parse the page. %s", e.errMsg)
+}
+
+// FieldError will be return when the name of a field of main_form is needed but not set in Client.Fields.
+type FieldError struct {
+	Field string
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("The name of the field '%s' of main_form is not set in Client.Fields.", e.Field)
+}
+
+// PageError will be return when a page is needed but not set in Client.Pages.
+type PageError struct {
+	Page string
+}
+
+func (e *PageError) Error() string {
+	return fmt.Sprintf("The page '%s' is not set in Client.Pages.", e.Page)
+}
@@ -0,0 +1,120 @@
+package portal
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// MainFormSelector selects main_form, which the portal posts to switch pages and views.
+const MainFormSelector = "form#main_form, form[name=main_form]"
+
+// Form is the state of an HTML form as a browser would submit it.
+type Form struct {
+	Action *url.URL
+	Method string
+	Values url.Values
+}
+
+// ParseForm parses the first form matched by given selector. The action is resolved with given base url.
+func ParseForm(body io.Reader, base *url.URL, selector string) (*Form, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return parseForm(doc, base, selector)
+}
+
+func parseForm(doc *goquery.Document, base *url.URL, selector string) (*Form, error) {
+	s := doc.Find(selector).First()
+	if s.Length() == 0 {
+		return nil, &ParseError{errMsg: "Form '" + selector + "' is not found."}
+	}
+	if base == nil {
+		base = &url.URL{}
+	}
+	action, _ := s.Attr("action")
+	u, err := base.Parse(strings.TrimSpace(action))
+	if err != nil {
+		return nil, &ParseError{errMsg: "Invalid action '" + action + "'."}
+	}
+	f := &Form{Action: u, Method: http.MethodGet, Values: url.Values{}}
+	if method, ok := s.Attr("method"); ok && strings.EqualFold(method, http.MethodPost) {
+		f.Method = http.MethodPost
+	}
+	s.Find("input[name], select[name], textarea[name]").Each(func(_ int, field *goquery.Selection) {
+		if _, disabled := field.Attr("disabled"); disabled {
+			return
+		}
+		name, _ := field.Attr("name")
+		switch goquery.NodeName(field) {
+		case "select":
+			selected := field.Find("option[selected]")
+			if selected.Length() == 0 {
+				if _, multiple := field.Attr("multiple"); multiple {
+					return
+				}
+				selected = field.Find("option").First()
+			}
+			selected.Each(func(_ int, o *goquery.Selection) {
+				f.Values.Add(name, optionValue(o))
+			})
+		case "textarea":
+			f.Values.Add(name, field.Text())
+		default:
+			typ, _ := field.Attr("type")
+			value, _ := field.Attr("value")
+			switch strings.ToLower(typ) {
+			case "submit", "button", "image", "reset", "file":
+				// Only the clicked button is submitted.
+			case "checkbox", "radio":
+				if _, checked := field.Attr("checked"); checked {
+					if _, ok := field.Attr("value"); !ok {
+						value = "on"
+					}
+					f.Values.Add(name, value)
+				}
+			default:
+				f.Values.Add(name, value)
+			}
+		}
+	})
+	return f, nil
+}
+
+func optionValue(o *goquery.Selection) string {
+	if v, ok := o.Attr("value"); ok {
+		return v
+	}
+	return strings.TrimSpace(o.Text())
+}
+
+// Clone returns a copy of the form which can be modified independently.
+func (f *Form) Clone() *Form {
+	action := *f.Action
+	values := make(url.Values, len(f.Values))
+	for k, v := range f.Values {
+		values[k] = append([]string(nil), v...)
+	}
+	return &Form{Action: &action, Method: f.Method, Values: values}
+}
+
+// Submit sends the form with the portal client.
+func (c *Client) Submit(ctx context.Context, f *Form) (*http.Response, error) {
+	if f.Method != http.MethodPost {
+		u := *f.Action
+		u.RawQuery = f.Values.Encode()
+		return c.get(ctx, u.String())
+	}
+	req, err := http.NewRequest(http.MethodPost, f.Action.String(), strings.NewReader(f.Values.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return c.do(req)
+}
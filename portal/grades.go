@@ -0,0 +1,103 @@
+package portal
+
+import (
+	"context"
+	"io"
+	"strconv"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/StudioAquatan/kitwalk/internal/text"
+)
+
+// Grade is the record of a course in the grades.
+type Grade struct {
+	// Year is the academic year which starts in April.
+	Year int    `json:"year"`
+	Term string `json:"term"`
+	// Category is the requirement category such as "専門科目".
+	Category   string  `json:"category"`
+	Code       string  `json:"code"`
+	Course     string  `json:"course"`
+	Instructor string  `json:"instructor,omitempty"`
+	Credits    float64 `json:"credits"`
+	// Letter is the evaluation as shown such as "秀" or "認定". It is empty until the grade is fixed.
+	Letter string `json:"letter"`
+	// Score is the raw score. It is negative when the portal does not show it.
+	Score int `json:"score"`
+}
+
+// gradeColumns maps the headers of the grades table to the setters of the fields.
+var gradeColumns = map[string]func(g *Grade, s string){
+	"年度":     func(g *Grade, s string) { g.Year, _ = strconv.Atoi(s) },
+	"学期":     func(g *Grade, s string) { g.Term = s },
+	"科目区分":   func(g *Grade, s string) { g.Category = s },
+	"時間割コード": func(g *Grade, s string) { g.Code = s },
+	"授業科目名":  func(g *Grade, s string) { g.Course = s },
+	"担当教員":   func(g *Grade, s string) { g.Instructor = s },
+	"単位数":    func(g *Grade, s string) { g.Credits, _ = strconv.ParseFloat(s, 64) },
+	"評価":     func(g *Grade, s string) { g.Letter = s },
+	"素点": func(g *Grade, s string) {
+		if score, err := strconv.Atoi(s); err == nil {
+			g.Score = score
+		}
+	},
+}
+
+// Grades fetches the grades of all years from Pages.Grades.
+func (c *Client) Grades(ctx context.Context) ([]Grade, error) {
+	if !c.Pages.Grades.IsSet() {
+		return nil, &PageError{Page: "Grades"}
+	}
+	resp, err := c.get(ctx, c.Pages.Grades.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ParseGrades(resp.Body, c.Pages.Grades.Table)
+}
+
+// ParseGrades parses the grades in the table of the grades page selected by table.
+// Columns are identified by their headers.
+func ParseGrades(body io.Reader, table string) ([]Grade, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return parseGrades(doc, table)
+}
+
+func parseGrades(doc *goquery.Document, selector string) ([]Grade, error) {
+	table := doc.Find(selector).First()
+	if table.Length() == 0 {
+		return nil, &ParseError{errMsg: "Grades are not found."}
+	}
+	rows := table.Find("tr")
+	var setters []func(g *Grade, s string)
+	rows.First().Find("th").Each(func(_ int, s *goquery.Selection) {
+		setters = append(setters, gradeColumns[text.CollapseSpaces(s.Text())])
+	})
+	found := 0
+	for _, set := range setters {
+		if set != nil {
+			found++
+		}
+	}
+	if found == 0 {
+		return nil, &ParseError{errMsg: "Headers of the grades are not found."}
+	}
+	var grades []Grade
+	rows.Slice(1, rows.Length()).Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find("td")
+		if cells.Length() == 0 {
+			return
+		}
+		g := Grade{Score: -1}
+		cells.Each(func(i int, cell *goquery.Selection) {
+			if i < len(setters) && setters[i] != nil {
+				setters[i](&g, text.CollapseSpaces(cell.Text()))
+			}
+		})
+		grades = append(grades, g)
+	})
+	return grades, nil
+}
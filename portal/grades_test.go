@@ -0,0 +1,33 @@
+package portal
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+const sampleGrades = "../samples/grades.html"
+
+func TestParseGrades(t *testing.T) {
+	t.Parallel()
+	f, err := os.Open(sampleGrades)
+	check(t, err)
+	defer f.Close()
+	grades, err := ParseGrades(f, testPages.Grades.Table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(grades) != 7 {
+		t.Fatalf("Expect: 7 grades\nActual: %d grades\n", len(grades))
+	}
+	expect := Grade{Year: 2017, Term: "前期", Category: "基盤教育科目", Code: "10010101", Course: "英語IA", Instructor: "Smith John", Credits: 1, Letter: "秀", Score: 95}
+	if grades[0] != expect {
+		t.Errorf("Expect: %+v\nActual: %+v\n", expect, grades[0])
+	}
+	if pending := grades[6]; pending.Letter != "" || pending.Score >= 0 || pending.Credits != 2 {
+		t.Errorf("Expect: pending grade\nActual: %+v\n", pending)
+	}
+	if _, err := ParseGrades(strings.NewReader("<table class='grades'><tr><th>x</th></tr></table>"), testPages.Grades.Table); err == nil {
+		t.Error("Expect: ParseError\nActual: (nil)")
+	}
+}
@@ -0,0 +1,241 @@
+package portal
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"io"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/StudioAquatan/kitwalk/internal/text"
+	"golang.org/x/net/html"
+)
+
+const (
+	noticeDateLayout = "2006.1.2"
+	divisionPrefix   = "div"
+	categoryPrefix   = "cat"
+)
+
+// scriptArgPattern matches the first quoted argument of a function call in a script.
+var scriptArgPattern = regexp.MustCompile(`\(\s*['"]([^'"]+)['"]`)
+
+// JST is the time zone used in the portal.
+var JST = time.FixedZone("Asia/Tokyo", 9*60*60)
+
+// Notice is an item of "お知らせ" on the front page of the portal.
+type Notice struct {
+	// Date is the posting date in JST.
+	Date time.Time
+	// Office is the office in charge such as "学務課".
+	Office string
+	// Category is the category such as "その他".
+	Category string
+	// DivisionCode is the code of the office taken from the class "divNN".
+	DivisionCode string
+	// CategoryCode is the code of the category taken from the class "catNN".
+	CategoryCode string
+	Title        string
+	// Link is the url of the notice if it links to a web page.
+	Link string
+	// Attachments are the urls of linked documents such as PDF.
+	Attachments []string
+	// Body is the plain text of the notice.
+	Body string
+	// DetailKey identifies the detail view opened by posting main_form.
+	// It is empty when the notice has no such view.
+	DetailKey string
+}
+
+// Notices fetch the front page of the portal and return the notices on it.
+func (c *Client) Notices(ctx context.Context) ([]Notice, error) {
+	resp, err := c.get(ctx, c.BaseURL.String())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ParseNotices(resp.Body, resp.Request.URL)
+}
+
+// ParseNotices parses the page of the portal. Relative links are resolved with given base url.
+func ParseNotices(body io.Reader, base *url.URL) ([]Notice, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return parseNoticeDocument(doc, base)
+}
+
+func parseNoticeDocument(doc *goquery.Document, base *url.URL) ([]Notice, error) {
+	if base == nil {
+		base = &url.URL{}
+	}
+	var (
+		notices  []Notice
+		parseErr error
+	)
+	// The first list is a header which has <dt> only.
+	doc.Find("dl.notice_list_dl").EachWithBreak(func(_ int, s *goquery.Selection) bool {
+		if s.Find("dd").Length() == 0 {
+			return true
+		}
+		n, err := parseNotice(s, base)
+		if err != nil {
+			parseErr = err
+			return false
+		}
+		notices = append(notices, *n)
+		return true
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return notices, nil
+}
+
+func parseNotice(s *goquery.Selection, base *url.URL) (*Notice, error) {
+	n := &Notice{}
+	dateText := strings.TrimSpace(s.Find("dd.nl_notice_date").Text())
+	date, err := time.ParseInLocation(noticeDateLayout, dateText, JST)
+	if err != nil {
+		return nil, &ParseError{errMsg: "Invalid date '" + dateText + "'."}
+	}
+	n.Date = date
+	n.Office = strings.Trim(strings.TrimSpace(s.Find("dd.nl_div_in_charge").Text()), "〈〉")
+	n.Category = strings.Trim(strings.TrimSpace(s.Find("dd.nl_category").Text()), "《》")
+	class, _ := s.Attr("class")
+	for _, c := range strings.Fields(class) {
+		switch {
+		case strings.HasPrefix(c, divisionPrefix):
+			n.DivisionCode = strings.TrimPrefix(c, divisionPrefix)
+		case strings.HasPrefix(c, categoryPrefix):
+			n.CategoryCode = strings.TrimPrefix(c, categoryPrefix)
+		}
+	}
+
+	content := s.Find("dd.nl_notice").First()
+	n.Body = plainText(content.Find("p.notice_info"))
+	anchor := content.Find("a[href]").First()
+	if key, ok := detailKey(s); ok {
+		// The anchor may open the detail view with a script instead of a link.
+		n.DetailKey = key
+	}
+	if anchor.Length() != 0 {
+		n.Title = text.CollapseSpaces(anchor.Text())
+		href, _ := anchor.Attr("href")
+		href = strings.TrimSpace(href)
+		if isScript(href) {
+			return n, nil
+		}
+		u, err := base.Parse(href)
+		if err != nil {
+			return nil, &ParseError{errMsg: "Invalid link '" + href + "'."}
+		}
+		if isAttachment(anchor, u) {
+			n.Attachments = append(n.Attachments, u.String())
+		} else {
+			n.Link = u.String()
+		}
+	} else {
+		title := content.Clone()
+		title.Find("p.notice_info").Remove()
+		n.Title = text.CollapseSpaces(title.Text())
+	}
+	return n, nil
+}
+
+// isScript reports whether the href runs a script or stays on the page instead of linking to another page.
+func isScript(href string) bool {
+	return href == "" || strings.HasPrefix(href, "#") || strings.HasPrefix(strings.ToLower(href), "javascript:")
+}
+
+// detailKey extracts the key of the detail view from the attribute "data-notice-id",
+// or the first quoted argument of the script such as onclick="showDetail('123')".
+func detailKey(s *goquery.Selection) (string, bool) {
+	if key, ok := s.Find("[data-notice-id]").First().Attr("data-notice-id"); ok {
+		return key, true
+	}
+	var key string
+	s.Find("a[href], [onclick]").EachWithBreak(func(_ int, a *goquery.Selection) bool {
+		onclick, _ := a.Attr("onclick")
+		href, _ := a.Attr("href")
+		scripts := []string{onclick}
+		if strings.HasPrefix(strings.ToLower(href), "javascript:") {
+			scripts = append(scripts, href)
+		}
+		for _, script := range scripts {
+			if m := scriptArgPattern.FindStringSubmatch(script); m != nil {
+				key = m[1]
+				return false
+			}
+		}
+		return true
+	})
+	return key, key != ""
+}
+
+// isAttachment reports whether the link is a document. The portal shows an icon next to such a link.
+func isAttachment(anchor *goquery.Selection, u *url.URL) bool {
+	if anchor.Find("img[src$='pdf.png']").Length() != 0 {
+		return true
+	}
+	switch strings.ToLower(path.Ext(u.Path)) {
+	case ".pdf", ".doc", ".docx", ".xls", ".xlsx", ".ppt", ".pptx", ".zip":
+		return true
+	}
+	return false
+}
+
+// plainText returns the text in the selection. <br> is converted into a line break.
+func plainText(s *goquery.Selection) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		switch {
+		case node.Type == html.TextNode:
+			b.WriteString(node.Data)
+		case node.Type == html.ElementNode && node.Data == "br":
+			b.WriteString("\n")
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, node := range s.Nodes {
+		walk(node)
+	}
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = text.CollapseSpaces(line); len(line) != 0 {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ID returns the stable identifier of the notice derived from its posting date, office, category and title.
+// It does not change when the body or links of the notice are modified.
+func (n *Notice) ID() string {
+	return digest(n.Date.Format(noticeDateLayout), n.DivisionCode, n.Office, n.CategoryCode, n.Category, n.Title)
+}
+
+// Fingerprint returns the digest of whole content of the notice. It changes when any field is modified.
+func (n *Notice) Fingerprint() string {
+	fields := []string{n.ID(), n.Link, n.Body}
+	fields = append(fields, n.Attachments...)
+	return digest(fields...)
+}
+
+func digest(fields ...string) string {
+	h := sha1.New()
+	for _, f := range fields {
+		io.WriteString(h, f)
+		h.Write([]byte{0})
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,93 @@
+package portal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const samplePortal = "../samples/internal_auth.html"
+
+func check(t *testing.T, err error) {
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestParseNotices(t *testing.T) {
+	t.Parallel()
+	f, err := os.Open(samplePortal)
+	check(t, err)
+	defer f.Close()
+	base, _ := url.Parse("https://portal.student.kit.ac.jp/")
+	notices, err := ParseNotices(f, base)
+	check(t, err)
+	if len(notices) != 3 {
+		t.Fatalf("Expect: 3 notices\nActual: %d notices\n", len(notices))
+	}
+	t.Run("Parse common fields", func(t *testing.T) {
+		date := time.Date(2018, time.February, 9, 0, 0, 0, 0, JST)
+		for _, n := range notices {
+			if !n.Date.Equal(date) {
+				t.Errorf("Expect: %+v\nActual: %+v\n", date, n.Date)
+			}
+			if n.Office != "学務課" || n.Category != "その他" {
+				t.Errorf("Expect: 学務課, その他\nActual: %+v, %+v\n", n.Office, n.Category)
+			}
+			if n.DivisionCode != "10" || n.CategoryCode != "14" {
+				t.Errorf("Expect: 10, 14\nActual: %+v, %+v\n", n.DivisionCode, n.CategoryCode)
+			}
+			if n.Body != "お知らせ一行目\nお知らせ二行目" {
+				t.Errorf("Unexpected body %q", n.Body)
+			}
+		}
+	})
+	t.Run("Parse links", func(t *testing.T) {
+		pdf := notices[0]
+		if pdf.Title != "PDFのお知らせ" || len(pdf.Attachments) != 1 || pdf.Link != "" {
+			t.Errorf("Unexpected notice with PDF %+v", pdf)
+		}
+		link := notices[1]
+		if link.Title != "普通のリンクのお知らせ" || link.Link != "https://example.com/" || len(link.Attachments) != 0 {
+			t.Errorf("Unexpected notice with link %+v", link)
+		}
+		noLink := notices[2]
+		if noLink.Title != "リンク無しお知らせ" || noLink.Link != "" || len(noLink.Attachments) != 0 {
+			t.Errorf("Unexpected notice without link %+v", noLink)
+		}
+	})
+	t.Run("Parse links of notice with detail view", func(t *testing.T) {
+		doc := `<dl class="div10 cat14 notice_list_dl"><dd class="nl_notice_date">2018.2.1</dd>
+<dd class="nl_notice" data-notice-id="2018-0043"><a href="files/guide.pdf">募集要項</a></dd></dl>`
+		notices, err := ParseNotices(strings.NewReader(doc), base)
+		check(t, err)
+		if len(notices) != 1 {
+			t.Fatalf("Expect: 1 notice\nActual: %+v\n", notices)
+		}
+		n := notices[0]
+		if n.DetailKey != "2018-0043" || n.Title != "募集要項" || len(n.Attachments) != 1 ||
+			n.Attachments[0] != "https://portal.student.kit.ac.jp/files/guide.pdf" {
+			t.Errorf("Unexpected notice with detail view %+v", n)
+		}
+	})
+}
+
+func TestClient_Notices(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeFile(w, r, samplePortal)
+	}))
+	defer server.Close()
+	client := NewClient(server.Client())
+	client.BaseURL, _ = url.Parse(server.URL)
+	notices, err := client.Notices(context.Background())
+	check(t, err)
+	if len(notices) != 3 {
+		t.Errorf("Expect: 3 notices\nActual: %d notices\n", len(notices))
+	}
+}
@@ -0,0 +1,292 @@
+package portal
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/StudioAquatan/kitwalk/internal/text"
+)
+
+const (
+	// DefaultMaxPages is the number of pages AllNotices fetches at most when zero is given.
+	DefaultMaxPages = 50
+	// DetailSelector selects the content of the detail view of a notice.
+	DetailSelector = ".notice_detail, #notice_detail"
+)
+
+// FormFields are the names of the fields of main_form which switch the view of the portal.
+// The main_form of the front page has no such fields, and the portal adds them only for some accounts and terms,
+// so kitwalk has no defaults. Set Client.Fields with the names found in the page of your account.
+type FormFields struct {
+	// Page is the 1-based page number. AllNotices fetches only the first page without it.
+	Page string
+	// Division and Category are the codes of offices and categories as in the classes "divNN" and "catNN".
+	Division string
+	Category string
+	// From and To are the range of posting dates formatted with DateLayout.
+	From       string
+	To         string
+	DateLayout string
+	// Detail is the key of the notice whose detail view is opened. NoticeDetail needs it for notices with DetailKey.
+	Detail string
+}
+
+// NoticeQuery filters notices. Empty fields match all notices.
+type NoticeQuery struct {
+	// Divisions and Categories are the codes such as "10" of DivisionCode and CategoryCode.
+	Divisions  []string
+	Categories []string
+	// From and To are the inclusive range of the posting date.
+	From time.Time
+	To   time.Time
+	// MaxPages caps the number of pages to fetch.
+	MaxPages int
+}
+
+// Match reports whether the notice satisfies the query.
+func (q *NoticeQuery) Match(n Notice) bool {
+	if len(q.Divisions) != 0 && !text.Contains(q.Divisions, n.DivisionCode) {
+		return false
+	}
+	if len(q.Categories) != 0 && !text.Contains(q.Categories, n.CategoryCode) {
+		return false
+	}
+	if !q.From.IsZero() && n.Date.Before(startOfDay(q.From)) {
+		return false
+	}
+	if !q.To.IsZero() && !n.Date.Before(startOfDay(q.To).AddDate(0, 0, 1)) {
+		return false
+	}
+	return true
+}
+
+func (q *NoticeQuery) filtered() bool {
+	return len(q.Divisions) != 0 || len(q.Categories) != 0 || !q.From.IsZero() || !q.To.IsZero()
+}
+
+// apply sets the query to the fields whose names are known.
+func (q *NoticeQuery) apply(f *Form, fields FormFields) {
+	if len(q.Divisions) != 0 && fields.Division != "" {
+		f.Values[fields.Division] = append([]string(nil), q.Divisions...)
+	}
+	if len(q.Categories) != 0 && fields.Category != "" {
+		f.Values[fields.Category] = append([]string(nil), q.Categories...)
+	}
+	if !q.From.IsZero() && fields.From != "" {
+		f.Values.Set(fields.From, q.From.In(JST).Format(fields.DateLayout))
+	}
+	if !q.To.IsZero() && fields.To != "" {
+		f.Values.Set(fields.To, q.To.In(JST).Format(fields.DateLayout))
+	}
+}
+
+func startOfDay(t time.Time) time.Time {
+	t = t.In(JST)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, JST)
+}
+
+// AllNotices fetches the notices on all pages matching the query by posting main_form.
+// The query is sent to the portal with the fields named in Client.Fields, and applied to the result again
+// in case the portal ignores it. Fetching stops at an empty page, a page which has been seen, or MaxPages.
+// Without Client.Fields.Page, only the first page is fetched and filtered.
+func (c *Client) AllNotices(ctx context.Context, q NoticeQuery) ([]Notice, error) {
+	resp, err := c.get(ctx, c.BaseURL.String())
+	if err != nil {
+		return nil, err
+	}
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	base := resp.Request.URL
+	fields := c.Fields
+	form, err := parseForm(doc, base, MainFormSelector)
+	if err != nil || fields.Page == "" {
+		// Without the form or the page field, only the first page is available.
+		notices, err := parseNoticeDocument(doc, base)
+		return filterNotices(notices, q), err
+	}
+	maxPages := q.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+	q.apply(form, fields)
+
+	var (
+		all  []Notice
+		seen = make(map[string]bool)
+	)
+	for page := 1; page <= maxPages; page++ {
+		if page != 1 || q.filtered() {
+			form.Values.Set(fields.Page, strconv.Itoa(page))
+			if doc, base, err = c.submitDocument(ctx, form); err != nil {
+				return nil, err
+			}
+		}
+		notices, err := parseNoticeDocument(doc, base)
+		if err != nil {
+			return nil, err
+		}
+		fresh := false
+		for _, n := range notices {
+			if !seen[n.Fingerprint()] {
+				fresh = true
+			}
+		}
+		if !fresh {
+			break
+		}
+		for _, n := range notices {
+			seen[n.Fingerprint()] = true
+		}
+		all = append(all, notices...)
+		if next, err := parseForm(doc, base, MainFormSelector); err == nil {
+			// Keep the state of the form the portal returned, such as tokens.
+			q.apply(next, fields)
+			form = next
+		}
+	}
+	return filterNotices(all, q), nil
+}
+
+func (c *Client) submitDocument(ctx context.Context, f *Form) (*goquery.Document, *url.URL, error) {
+	resp, err := c.Submit(ctx, f)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	doc, err := goquery.NewDocumentFromReader(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	return doc, resp.Request.URL, nil
+}
+
+func filterNotices(notices []Notice, q NoticeQuery) []Notice {
+	var matched []Notice
+	for _, n := range notices {
+		if q.Match(n) {
+			matched = append(matched, n)
+		}
+	}
+	return matched
+}
+
+// NoticeDetail is the detail view of a notice.
+type NoticeDetail struct {
+	Notice
+	// Links are the urls linked from the detail view other than attachments.
+	Links []string
+}
+
+// NoticeDetail opens the detail view of the notice.
+// A notice with DetailKey is opened by posting main_form, and a notice linking to a page of the portal is fetched.
+// Otherwise, the list already has the whole content, and the notice is returned as it is.
+func (c *Client) NoticeDetail(ctx context.Context, n Notice) (*NoticeDetail, error) {
+	var (
+		doc  *goquery.Document
+		base *url.URL
+	)
+	switch {
+	case n.DetailKey != "":
+		if c.Fields.Detail == "" {
+			return nil, &FieldError{Field: "Detail"}
+		}
+		resp, err := c.get(ctx, c.BaseURL.String())
+		if err != nil {
+			return nil, err
+		}
+		form, err := ParseForm(resp.Body, resp.Request.URL, MainFormSelector)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		form.Values.Set(c.Fields.Detail, n.DetailKey)
+		if doc, base, err = c.submitDocument(ctx, form); err != nil {
+			return nil, err
+		}
+	case c.onPortal(n.Link):
+		resp, err := c.get(ctx, n.Link)
+		if err != nil {
+			return nil, err
+		}
+		doc, err = goquery.NewDocumentFromReader(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		base = resp.Request.URL
+	default:
+		return &NoticeDetail{Notice: n}, nil
+	}
+	return parseNoticeDetail(doc, base, n)
+}
+
+func (c *Client) onPortal(link string) bool {
+	u, err := url.Parse(link)
+	return err == nil && link != "" && strings.EqualFold(u.Host, c.BaseURL.Host)
+}
+
+// ParseNoticeDetail parses the detail view of given notice.
+// The title, body and links in the view replace or complement those in the list.
+func ParseNoticeDetail(body io.Reader, base *url.URL, n Notice) (*NoticeDetail, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return parseNoticeDetail(doc, base, n)
+}
+
+func parseNoticeDetail(doc *goquery.Document, base *url.URL, n Notice) (*NoticeDetail, error) {
+	content := doc.Find(DetailSelector).First()
+	if content.Length() == 0 {
+		return nil, &ParseError{errMsg: "Detail of the notice is not found."}
+	}
+	if base == nil {
+		base = &url.URL{}
+	}
+	d := &NoticeDetail{Notice: n}
+	d.Attachments = append([]string(nil), n.Attachments...)
+	if title := text.CollapseSpaces(content.Find(".notice_title").First().Text()); title != "" {
+		d.Title = title
+	}
+	bodySel := content.Find(".notice_body").First()
+	if bodySel.Length() == 0 {
+		bodySel = content.Clone()
+		bodySel.Find(".notice_title").Remove()
+	}
+	if text := plainText(bodySel); text != "" {
+		d.Body = text
+	}
+	var parseErr error
+	content.Find("a[href]").EachWithBreak(func(_ int, a *goquery.Selection) bool {
+		href, _ := a.Attr("href")
+		u, err := base.Parse(strings.TrimSpace(href))
+		if err != nil {
+			parseErr = &ParseError{errMsg: "Invalid link '" + href + "'."}
+			return false
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return true
+		}
+		switch {
+		case isAttachment(a, u):
+			if !text.Contains(d.Attachments, u.String()) {
+				d.Attachments = append(d.Attachments, u.String())
+			}
+		case !text.Contains(d.Links, u.String()):
+			d.Links = append(d.Links, u.String())
+		}
+		return true
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	return d, nil
+}
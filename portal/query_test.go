@@ -0,0 +1,205 @@
+package portal
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	samplePage2  = "../samples/notice_page2.html"
+	sampleDetail = "../samples/notice_detail.html"
+)
+
+// testFields are the names of the fields in the synthetic samples.
+var testFields = FormFields{
+	Page:       "page",
+	Division:   "div",
+	Category:   "cat",
+	From:       "date_from",
+	To:         "date_to",
+	DateLayout: "2006/01/02",
+	Detail:     "notice_id",
+}
+
+// formServer serves the samples as the portal driven by main_form.
+type formServer struct {
+	*httptest.Server
+	mu    sync.Mutex
+	posts []url.Values
+}
+
+func newFormServer(t *testing.T) *formServer {
+	s := &formServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sample := samplePortal
+		if r.Method == http.MethodPost {
+			r.ParseForm()
+			s.mu.Lock()
+			s.posts = append(s.posts, r.PostForm)
+			s.mu.Unlock()
+			switch {
+			case r.PostForm.Get("notice_id") == "2018-0042":
+				sample = sampleDetail
+			case r.PostForm.Get("page") == "2":
+				sample = samplePage2
+			case r.PostForm.Get("page") != "1":
+				// The portal shows the last page for pages beyond it.
+				sample = samplePage2
+			}
+		}
+		b, err := ioutil.ReadFile(sample)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=UTF-8")
+		w.Write([]byte(strings.Replace(string(b), "https://portal.student.kit.ac.jp/", s.URL+"/", -1)))
+	}))
+	return s
+}
+
+func (s *formServer) client() *Client {
+	client := NewClient(s.Server.Client())
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	client.Fields = testFields
+	return client
+}
+
+func TestParseForm(t *testing.T) {
+	t.Parallel()
+	page := `<form id="main_form" action="/next" method="post">
+		<input type="hidden" name="token" value="abc">
+		<input type="checkbox" name="div" value="10" checked>
+		<input type="checkbox" name="div" value="20">
+		<input type="radio" name="order" value="new" checked>
+		<select name="term"><option value="1">前期</option><option value="2" selected>後期</option></select>
+		<input type="submit" name="search" value="検索">
+		<input type="text" name="keyword" value="x" disabled>
+	</form>`
+	base, _ := url.Parse("https://portal.student.kit.ac.jp/ead/")
+	f, err := ParseForm(strings.NewReader(page), base, MainFormSelector)
+	check(t, err)
+	if f.Action.String() != "https://portal.student.kit.ac.jp/next" || f.Method != http.MethodPost {
+		t.Errorf("Unexpected action %s %s", f.Method, f.Action)
+	}
+	expect := url.Values{"token": {"abc"}, "div": {"10"}, "order": {"new"}, "term": {"2"}}
+	if f.Values.Encode() != expect.Encode() {
+		t.Errorf("Expect: %s\nActual: %s\n", expect.Encode(), f.Values.Encode())
+	}
+	if _, err := ParseForm(strings.NewReader("<p>no form</p>"), base, MainFormSelector); err == nil {
+		t.Error("Expect: ParseError\nActual: (nil)")
+	}
+}
+
+func TestClient_AllNotices(t *testing.T) {
+	t.Parallel()
+	t.Run("Fetch all pages", func(t *testing.T) {
+		server := newFormServer(t)
+		defer server.Close()
+		notices, err := server.client().AllNotices(context.Background(), NoticeQuery{})
+		check(t, err)
+		if len(notices) != 5 {
+			t.Fatalf("Expect: 5 notices\nActual: %d notices\n", len(notices))
+		}
+		detail := notices[3]
+		if detail.DetailKey != "2018-0042" || detail.Title != "奨学金の募集について" || detail.Link != "" {
+			t.Errorf("Unexpected notice with detail view %+v", detail)
+		}
+		// Page 2 and 3 are posted, and the fields the portal returned are kept.
+		if len(server.posts) != 2 || server.posts[1].Get("token") != "page2-token" {
+			t.Errorf("Unexpected posts %v", server.posts)
+		}
+	})
+	t.Run("Filter notices", func(t *testing.T) {
+		server := newFormServer(t)
+		defer server.Close()
+		q := NoticeQuery{
+			Divisions: []string{"10"},
+			From:      time.Date(2018, time.January, 1, 0, 0, 0, 0, JST),
+			To:        time.Date(2018, time.February, 1, 0, 0, 0, 0, JST),
+		}
+		notices, err := server.client().AllNotices(context.Background(), q)
+		check(t, err)
+		if len(notices) != 1 || notices[0].Title != "古いお知らせ" {
+			t.Errorf("Unexpected notices %+v", notices)
+		}
+		first := server.posts[0]
+		if first.Get("page") != "1" || first.Get("div") != "10" || first.Get("date_from") != "2018/01/01" || first.Get("date_to") != "2018/02/01" {
+			t.Errorf("Unexpected query %v", first)
+		}
+	})
+	t.Run("Cap pages", func(t *testing.T) {
+		server := newFormServer(t)
+		defer server.Close()
+		notices, err := server.client().AllNotices(context.Background(), NoticeQuery{MaxPages: 1})
+		check(t, err)
+		if len(notices) != 3 || len(server.posts) != 0 {
+			t.Errorf("Expect: first page only\nActual: %d notices, %d posts\n", len(notices), len(server.posts))
+		}
+	})
+	t.Run("First page without fields", func(t *testing.T) {
+		server := newFormServer(t)
+		defer server.Close()
+		client := server.client()
+		client.Fields = FormFields{}
+		notices, err := client.AllNotices(context.Background(), NoticeQuery{Divisions: []string{"10"}})
+		check(t, err)
+		if len(server.posts) != 0 {
+			t.Errorf("Expect: no post\nActual: %v\n", server.posts)
+		}
+		for _, n := range notices {
+			if n.DivisionCode != "10" {
+				t.Errorf("Unexpected notice %+v", n)
+			}
+		}
+	})
+}
+
+func TestClient_NoticeDetail(t *testing.T) {
+	t.Parallel()
+	server := newFormServer(t)
+	defer server.Close()
+	client := server.client()
+	ctx := context.Background()
+
+	t.Run("Open detail view with main_form", func(t *testing.T) {
+		n := Notice{Title: "奨学金の募集について", Body: "詳細を確認してください。", DetailKey: "2018-0042"}
+		d, err := client.NoticeDetail(ctx, n)
+		check(t, err)
+		if d.Title != "奨学金の募集について（第二次）" {
+			t.Errorf("Unexpected title %q", d.Title)
+		}
+		if !strings.HasPrefix(d.Body, "奨学金の募集を行います。\n締切は2月28日です。") {
+			t.Errorf("Unexpected body %q", d.Body)
+		}
+		if len(d.Attachments) != 1 || d.Attachments[0] != server.URL+"/files/application.pdf" {
+			t.Errorf("Unexpected attachments %v", d.Attachments)
+		}
+		if len(d.Links) != 1 || d.Links[0] != "https://www.jasso.go.jp/" {
+			t.Errorf("Unexpected links %v", d.Links)
+		}
+	})
+	t.Run("Require detail field", func(t *testing.T) {
+		client := server.client()
+		client.Fields = FormFields{}
+		_, err := client.NoticeDetail(ctx, Notice{DetailKey: "2018-0042"})
+		if _, ok := err.(*FieldError); !ok {
+			t.Errorf("Expect: FieldError\nActual: %v\n", err)
+		}
+	})
+	t.Run("Return notice without detail view", func(t *testing.T) {
+		n := Notice{Title: "普通のリンクのお知らせ", Link: "https://example.com/"}
+		d, err := client.NoticeDetail(ctx, n)
+		check(t, err)
+		if d.Title != n.Title || d.Link != n.Link {
+			t.Errorf("Unexpected detail %+v", d)
+		}
+	})
+}
@@ -0,0 +1,142 @@
+package portal
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/StudioAquatan/kitwalk/internal/text"
+)
+
+var slotPattern = regexp.MustCompile(`([日月火水木金土])\s*(\d)(?:\s*[-・~～〜]\s*(\d))?`)
+
+// Slot is a class period in a week.
+type Slot struct {
+	Day    time.Weekday `json:"day"`
+	Period int          `json:"period"`
+	// Length is the number of consecutive periods.
+	Length int `json:"length"`
+}
+
+// Registration is a course in the registration list.
+type Registration struct {
+	Code       string  `json:"code"`
+	Title      string  `json:"title"`
+	Instructor string  `json:"instructor,omitempty"`
+	Term       string  `json:"term"`
+	Category   string  `json:"category,omitempty"`
+	Credits    float64 `json:"credits"`
+	// Status is the state of the registration as shown such as "登録済" or "抽選中".
+	Status string `json:"status,omitempty"`
+	// Slots are the class periods in a week. It is empty for intensive courses.
+	Slots []Slot `json:"slots,omitempty"`
+}
+
+// Courses returns the courses of the registration in the form of the timetable, one for each slot.
+func (r *Registration) Courses() []Course {
+	if len(r.Slots) == 0 {
+		return []Course{{Code: r.Code, Title: r.Title, Instructor: r.Instructor, Term: r.Term}}
+	}
+	courses := make([]Course, len(r.Slots))
+	for i, s := range r.Slots {
+		courses[i] = Course{
+			Code:       r.Code,
+			Title:      r.Title,
+			Instructor: r.Instructor,
+			Term:       r.Term,
+			Day:        s.Day,
+			Period:     s.Period,
+			Length:     s.Length,
+		}
+	}
+	return courses
+}
+
+// registrationColumns maps the headers of the registration list to the setters of the fields.
+var registrationColumns = map[string]func(r *Registration, s string){
+	"時間割コード": func(r *Registration, s string) { r.Code = s },
+	"授業科目名":  func(r *Registration, s string) { r.Title = s },
+	"担当教員":   func(r *Registration, s string) { r.Instructor = s },
+	"学期":     func(r *Registration, s string) { r.Term = s },
+	"科目区分":   func(r *Registration, s string) { r.Category = s },
+	"単位数":    func(r *Registration, s string) { r.Credits, _ = strconv.ParseFloat(s, 64) },
+	"状態":     func(r *Registration, s string) { r.Status = s },
+	"曜日時限":   func(r *Registration, s string) { r.Slots = ParseSlots(s) },
+	"曜日・時限":  func(r *Registration, s string) { r.Slots = ParseSlots(s) },
+}
+
+// Registrations fetches the registration list of the current term from Pages.Registration.
+// The page is only read, and no form is submitted to it.
+func (c *Client) Registrations(ctx context.Context) ([]Registration, error) {
+	if !c.Pages.Registration.IsSet() {
+		return nil, &PageError{Page: "Registration"}
+	}
+	resp, err := c.get(ctx, c.Pages.Registration.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ParseRegistrations(resp.Body, c.Pages.Registration.Table)
+}
+
+// ParseRegistrations parses the registration list in the table selected by table.
+// Columns are identified by their headers.
+func ParseRegistrations(body io.Reader, table string) ([]Registration, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return parseRegistrations(doc, table)
+}
+
+func parseRegistrations(doc *goquery.Document, selector string) ([]Registration, error) {
+	table := doc.Find(selector).First()
+	if table.Length() == 0 {
+		return nil, &ParseError{errMsg: "Registration list is not found."}
+	}
+	rows := table.Find("tr")
+	var setters []func(r *Registration, s string)
+	found := 0
+	rows.First().Find("th").Each(func(_ int, s *goquery.Selection) {
+		set := registrationColumns[text.CollapseSpaces(s.Text())]
+		if set != nil {
+			found++
+		}
+		setters = append(setters, set)
+	})
+	if found == 0 {
+		return nil, &ParseError{errMsg: "Headers of the registration list are not found."}
+	}
+	var registrations []Registration
+	rows.Slice(1, rows.Length()).Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find("td")
+		if cells.Length() == 0 {
+			return
+		}
+		var r Registration
+		cells.Each(func(i int, cell *goquery.Selection) {
+			if i < len(setters) && setters[i] != nil {
+				setters[i](&r, text.CollapseSpaces(cell.Text()))
+			}
+		})
+		registrations = append(registrations, r)
+	})
+	return registrations, nil
+}
+
+// ParseSlots parses the class periods such as "月1", "火3-4" or "水2 金2". It returns nil for "集中".
+func ParseSlots(s string) []Slot {
+	var slots []Slot
+	for _, m := range slotPattern.FindAllStringSubmatch(s, -1) {
+		period, _ := strconv.Atoi(m[2])
+		length := 1
+		if last, err := strconv.Atoi(m[3]); err == nil && last > period {
+			length = last - period + 1
+		}
+		slots = append(slots, Slot{Day: weekdays[m[1]], Period: period, Length: length})
+	}
+	return slots
+}
@@ -0,0 +1,37 @@
+package portal
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+const sampleRegistration = "../samples/registration.html"
+
+func TestParseRegistrations(t *testing.T) {
+	t.Parallel()
+	f, err := os.Open(sampleRegistration)
+	check(t, err)
+	defer f.Close()
+	registrations, err := ParseRegistrations(f, testPages.Registration.Table)
+	check(t, err)
+	if len(registrations) != 5 {
+		t.Fatalf("Expect: 5 registrations\nActual: %d registrations\n", len(registrations))
+	}
+	expect := Registration{Code: "10140101", Title: "プログラミング演習", Instructor: "佐藤 花子", Term: "前期", Category: "専門科目",
+		Credits: 2, Status: "登録済", Slots: []Slot{{Day: time.Tuesday, Period: 3, Length: 2}}}
+	if !reflect.DeepEqual(registrations[1], expect) {
+		t.Errorf("Expect: %+v\nActual: %+v\n", expect, registrations[1])
+	}
+	if english := registrations[2]; len(english.Courses()) != 2 || english.Courses()[1].Day != time.Friday || english.Status != "抽選中" {
+		t.Errorf("Expect: 英語IA on Wednesday and Friday\nActual: %+v\n", english)
+	}
+	if intensive := registrations[4]; len(intensive.Slots) != 0 || intensive.Courses()[0].Scheduled() {
+		t.Errorf("Expect: intensive course\nActual: %+v\n", intensive)
+	}
+	if _, err := ParseRegistrations(strings.NewReader("<table class='registration'><tr><th>x</th></tr></table>"), testPages.Registration.Table); err == nil {
+		t.Error("Expect: ParseError\nActual: (nil)")
+	}
+}
@@ -0,0 +1,218 @@
+package portal
+
+import (
+	"context"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/StudioAquatan/kitwalk/internal/text"
+)
+
+var (
+	termTitlePattern = regexp.MustCompile(`(\d{4})\s*年度\s*(\S*)`)
+	periodPattern    = regexp.MustCompile(`(\d+)\s*限`)
+	clockPattern     = regexp.MustCompile(`(\d{1,2}):(\d{2})\s*[～〜~-]\s*(\d{1,2}):(\d{2})`)
+)
+
+// weekdays maps the headers of the timetable to the days of the week.
+var weekdays = map[string]time.Weekday{
+	"日": time.Sunday,
+	"月": time.Monday,
+	"火": time.Tuesday,
+	"水": time.Wednesday,
+	"木": time.Thursday,
+	"金": time.Friday,
+	"土": time.Saturday,
+}
+
+// Period is the time of a class period. Start and End are the offsets from the midnight in JST.
+type Period struct {
+	Number int
+	Start  time.Duration
+	End    time.Duration
+}
+
+// DefaultPeriods are the class periods of KIT.
+var DefaultPeriods = []Period{
+	{Number: 1, Start: 8*time.Hour + 50*time.Minute, End: 10*time.Hour + 20*time.Minute},
+	{Number: 2, Start: 10*time.Hour + 30*time.Minute, End: 12 * time.Hour},
+	{Number: 3, Start: 13 * time.Hour, End: 14*time.Hour + 30*time.Minute},
+	{Number: 4, Start: 14*time.Hour + 40*time.Minute, End: 16*time.Hour + 10*time.Minute},
+	{Number: 5, Start: 16*time.Hour + 20*time.Minute, End: 17*time.Hour + 50*time.Minute},
+}
+
+// Course is a registered course in the timetable.
+type Course struct {
+	// Code is the timetable code such as "10110101".
+	Code       string
+	Title      string
+	Instructor string
+	Room       string
+	// Term is the term as shown in the portal such as "前期" or "第1クォーター".
+	Term string
+	// Day and Period are the first class period in a week. Period is zero for intensive courses without fixed time.
+	Day    time.Weekday
+	Period int
+	// Length is the number of consecutive periods. It is 2 for a course spanning 3rd and 4th periods.
+	Length int
+}
+
+// Scheduled reports whether the course has a fixed time in a week.
+func (c *Course) Scheduled() bool {
+	return c.Period != 0
+}
+
+// Timetable is the courses registered for a term.
+type Timetable struct {
+	// Year is the academic year which starts in April.
+	Year int
+	// Term is the term of the timetable such as "前期". Courses may have shorter terms such as quarters.
+	Term    string
+	Courses []Course
+	// Periods are the class periods shown in the timetable. DefaultPeriods is used when the page does not show them.
+	Periods []Period
+}
+
+// Period returns the class period of given number.
+func (t *Timetable) Period(number int) (Period, bool) {
+	for _, p := range t.Periods {
+		if p.Number == number {
+			return p, true
+		}
+	}
+	return Period{}, false
+}
+
+// Timetable fetches the courses registered for the current term from Pages.Timetable.
+func (c *Client) Timetable(ctx context.Context) (*Timetable, error) {
+	if !c.Pages.Timetable.IsSet() {
+		return nil, &PageError{Page: "Timetable"}
+	}
+	resp, err := c.get(ctx, c.Pages.Timetable.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ParseTimetable(resp.Body, c.Pages.Timetable.Table, c.Pages.Unscheduled)
+}
+
+// ParseTimetable parses the timetable page of the portal. table selects the grid of days and periods, and
+// unscheduled selects the table of the courses without fixed time. unscheduled may be empty.
+// The layout inside them follows the synthetic samples/timetable.html: the courses are the elements of the class
+// "course" with "course_code", "course_title", "instructor", "room" and "term", and the year and the term
+// are read from ".timetable_term".
+func ParseTimetable(body io.Reader, table, unscheduled string) (*Timetable, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return parseTimetable(doc, table, unscheduled)
+}
+
+func parseTimetable(doc *goquery.Document, selector, unscheduled string) (*Timetable, error) {
+	table := doc.Find(selector).First()
+	if table.Length() == 0 {
+		return nil, &ParseError{errMsg: "Timetable is not found."}
+	}
+	tt := &Timetable{}
+	if m := termTitlePattern.FindStringSubmatch(doc.Find(".timetable_term").First().Text()); m != nil {
+		tt.Year, _ = strconv.Atoi(m[1])
+		tt.Term = m[2]
+	}
+
+	rows := table.Find("tr")
+	var days []time.Weekday
+	rows.First().Find("th, td").Each(func(i int, s *goquery.Selection) {
+		if i == 0 {
+			return
+		}
+		day, ok := weekdays[strings.TrimSpace(s.Text())]
+		if !ok {
+			day = -1
+		}
+		days = append(days, day)
+	})
+	// occupied counts the rows each column is still covered by a cell with rowspan.
+	occupied := make([]int, len(days))
+	var parseErr error
+	rows.Slice(1, rows.Length()).EachWithBreak(func(_ int, row *goquery.Selection) bool {
+		header := row.Find("th").First()
+		m := periodPattern.FindStringSubmatch(header.Text())
+		if m == nil {
+			parseErr = &ParseError{errMsg: "Invalid period '" + text.CollapseSpaces(header.Text()) + "'."}
+			return false
+		}
+		number, _ := strconv.Atoi(m[1])
+		if c := clockPattern.FindStringSubmatch(header.Text()); c != nil {
+			tt.Periods = append(tt.Periods, Period{Number: number, Start: clock(c[1], c[2]), End: clock(c[3], c[4])})
+		}
+		col := 0
+		row.Find("td").Each(func(_ int, cell *goquery.Selection) {
+			for col < len(occupied) && occupied[col] > 0 {
+				col++
+			}
+			if col >= len(days) {
+				return
+			}
+			span := 1
+			if v, ok := cell.Attr("rowspan"); ok {
+				if n, err := strconv.Atoi(v); err == nil && n > 1 {
+					span = n
+				}
+			}
+			occupied[col] = span
+			if days[col] >= 0 {
+				cell.Find(".course").Each(func(_ int, s *goquery.Selection) {
+					course := parseCourse(s, tt.Term)
+					course.Day, course.Period, course.Length = days[col], number, span
+					tt.Courses = append(tt.Courses, course)
+				})
+			}
+			col++
+		})
+		for i := range occupied {
+			if occupied[i] > 0 {
+				occupied[i]--
+			}
+		}
+		return true
+	})
+	if parseErr != nil {
+		return nil, parseErr
+	}
+	if unscheduled != "" {
+		doc.Find(unscheduled).Find("tr").Each(func(_ int, s *goquery.Selection) {
+			if s.Find("td").Length() != 0 {
+				tt.Courses = append(tt.Courses, parseCourse(s, tt.Term))
+			}
+		})
+	}
+	if len(tt.Periods) == 0 {
+		tt.Periods = append([]Period(nil), DefaultPeriods...)
+	}
+	return tt, nil
+}
+
+func parseCourse(s *goquery.Selection, term string) Course {
+	c := Course{
+		Code:       text.CollapseSpaces(s.Find(".course_code").First().Text()),
+		Title:      text.CollapseSpaces(s.Find(".course_title").First().Text()),
+		Instructor: text.CollapseSpaces(s.Find(".instructor").First().Text()),
+		Room:       text.CollapseSpaces(s.Find(".room").First().Text()),
+		Term:       text.CollapseSpaces(s.Find(".term").First().Text()),
+	}
+	if c.Term == "" {
+		c.Term = term
+	}
+	return c
+}
+
+func clock(hour, minute string) time.Duration {
+	h, _ := strconv.Atoi(hour)
+	m, _ := strconv.Atoi(minute)
+	return time.Duration(h)*time.Hour + time.Duration(m)*time.Minute
+}
@@ -0,0 +1,84 @@
+package portal
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+)
+
+const sampleTimetable = "../samples/timetable.html"
+
+// testPages locate the synthetic samples.
+var testPages = Pages{
+	Timetable:    Page{Path: "ead/?c=timetable", Table: "table.timetable"},
+	Unscheduled:  "table.timetable_other",
+	Grades:       Page{Path: "ead/?c=grades", Table: "table.grades"},
+	Registration: Page{Path: "ead/?c=registration", Table: "table.registration"},
+}
+
+func TestParseTimetable(t *testing.T) {
+	t.Parallel()
+	f, err := os.Open(sampleTimetable)
+	check(t, err)
+	defer f.Close()
+	tt, err := ParseTimetable(f, testPages.Timetable.Table, testPages.Unscheduled)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tt.Year != 2018 || tt.Term != "前期" {
+		t.Errorf("Expect: 2018 前期\nActual: %d %s\n", tt.Year, tt.Term)
+	}
+	expect := []Course{
+		{Code: "10110101", Title: "線形代数学I", Instructor: "山田 太郎", Room: "1-111", Term: "前期", Day: time.Monday, Period: 1, Length: 1},
+		{Code: "10120202", Title: "英語IA", Instructor: "Smith John", Room: "3-201", Term: "第1クォーター", Day: time.Wednesday, Period: 1, Length: 1},
+		{Code: "10120203", Title: "英語IB", Instructor: "Smith John", Room: "3-201", Term: "第2クォーター", Day: time.Wednesday, Period: 1, Length: 1},
+		{Code: "10130301", Title: "情報工学実験", Instructor: "佐藤 花子、鈴木 一郎", Room: "8-301", Term: "前期", Day: time.Tuesday, Period: 3, Length: 2},
+		// The cell with rowspan shifts the cells in the next row.
+		{Code: "10140401", Title: "確率統計", Instructor: "田中 次郎", Room: "2-102", Term: "前期", Day: time.Friday, Period: 4, Length: 1},
+		{Code: "10190901", Title: "学外実習", Instructor: "高橋 三郎", Term: "通年"},
+	}
+	if len(tt.Courses) != len(expect) {
+		t.Fatalf("Expect: %d courses\nActual: %+v\n", len(expect), tt.Courses)
+	}
+	for i, c := range expect {
+		if tt.Courses[i] != c {
+			t.Errorf("Expect: %+v\nActual: %+v\n", c, tt.Courses[i])
+		}
+	}
+	if tt.Courses[5].Scheduled() {
+		t.Error("Expect: intensive course is not scheduled")
+	}
+	p, ok := tt.Period(3)
+	if !ok || p.Start != 13*time.Hour || p.End != 14*time.Hour+30*time.Minute {
+		t.Errorf("Unexpected period %+v", p)
+	}
+}
+
+func TestClient_Timetable(t *testing.T) {
+	t.Parallel()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ead/" || r.URL.Query().Get("c") != "timetable" {
+			http.NotFound(w, r)
+			return
+		}
+		http.ServeFile(w, r, sampleTimetable)
+	}))
+	defer server.Close()
+	client := NewClient(server.Client())
+	client.BaseURL, _ = url.Parse(server.URL + "/")
+	if _, err := client.Timetable(context.Background()); err == nil {
+		t.Error("Expect: PageError\nActual: (nil)")
+	} else if _, ok := err.(*PageError); !ok {
+		t.Errorf("Expect: PageError\nActual: %v\n", err)
+	}
+	client.Pages = testPages
+	tt, err := client.Timetable(context.Background())
+	check(t, err)
+	if tt == nil || len(tt.Courses) != 6 {
+		t.Errorf("Unexpected timetable %+v", tt)
+	}
+}
@@ -0,0 +1,225 @@
+package proxy
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	caCommonName  = "kitwalk local CA"
+	caValidity    = 365 * 24 * time.Hour
+	leafValidity  = 30 * 24 * time.Hour
+	certPEMType   = "CERTIFICATE"
+	keyPEMType    = "EC PRIVATE KEY"
+	clockSkewSpan = time.Hour
+)
+
+// DefaultCADomains are the domains the CA created by LoadOrCreateCA can sign certificates for.
+var DefaultCADomains = []string{"kit.ac.jp"}
+
+// CA is a local certificate authority to intercept HTTPS connections to the configured hosts.
+// Clients of the proxy have to trust the certificate of the CA.
+type CA struct {
+	Cert *x509.Certificate
+	Key  crypto.Signer
+
+	mu      sync.Mutex
+	leafKey *ecdsa.PrivateKey
+	leaves  map[string]*tls.Certificate
+}
+
+// NewCA generates new CA with ECDSA P-256 key. The CA is constrained to the domains, including their subdomains,
+// and IP addresses among them, so a leaked key cannot sign a certificate trusted for other hosts.
+// DefaultCADomains are used when none is given.
+func NewCA(domains ...string) (*CA, error) {
+	if len(domains) == 0 {
+		domains = DefaultCADomains
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: caCommonName},
+		NotBefore:             now.Add(-clockSkewSpan),
+		NotAfter:              now.Add(caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		// Clients must reject the certificates for other hosts even if they do not understand the constraints.
+		PermittedDNSDomainsCritical: true,
+	}
+	for _, d := range domains {
+		if ip := net.ParseIP(d); ip != nil {
+			if ip4 := ip.To4(); ip4 != nil {
+				ip = ip4
+			}
+			tmpl.PermittedIPRanges = append(tmpl.PermittedIPRanges, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+		} else {
+			tmpl.PermittedDNSDomains = append(tmpl.PermittedDNSDomains, d)
+		}
+	}
+	if len(tmpl.PermittedIPRanges) == 0 {
+		// IP addresses are not constrained by the domains; exclude all of them.
+		tmpl.ExcludedIPRanges = []*net.IPNet{
+			{IP: net.IPv4zero.To4(), Mask: net.CIDRMask(0, 32)},
+			{IP: net.IPv6zero, Mask: net.CIDRMask(0, 128)},
+		}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// LoadCA reads the certificate and the key in PEM.
+func LoadCA(certPath, keyPath string) (*CA, error) {
+	certPEM, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, err
+	}
+	keyPEM, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	certBlock, _ := pem.Decode(certPEM)
+	keyBlock, _ := pem.Decode(keyPEM)
+	if certBlock == nil || keyBlock == nil {
+		return nil, errors.New("invalid PEM of the CA")
+	}
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParseECPrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	return &CA{Cert: cert, Key: key}, nil
+}
+
+// LoadOrCreateCA reads the CA, or generates and saves new one constrained to DefaultCADomains when neither file
+// exists. It fails when only one of them exists, rather than replacing a certificate which may be trusted already.
+func LoadOrCreateCA(certPath, keyPath string) (*CA, error) {
+	_, certErr := os.Stat(certPath)
+	_, keyErr := os.Stat(keyPath)
+	if os.IsNotExist(certErr) != os.IsNotExist(keyErr) {
+		return nil, fmt.Errorf("only one of the CA certificate %s and the key %s exists; restore or remove it", certPath, keyPath)
+	}
+	ca, err := LoadCA(certPath, keyPath)
+	if !os.IsNotExist(err) {
+		return ca, err
+	}
+	if ca, err = NewCA(); err != nil {
+		return nil, err
+	}
+	return ca, ca.Save(certPath, keyPath)
+}
+
+// Save writes the certificate and the key in PEM. The key is readable only by the owner.
+func (ca *CA) Save(certPath, keyPath string) error {
+	key, ok := ca.Key.(*ecdsa.PrivateKey)
+	if !ok {
+		return errors.New("only ECDSA key can be saved")
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return err
+	}
+	for _, p := range []string{certPath, keyPath} {
+		if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+			return err
+		}
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: certPEMType, Bytes: ca.Cert.Raw})
+	if err := ioutil.WriteFile(certPath, certPEM, 0644); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: keyPEMType, Bytes: keyDER}), 0600)
+}
+
+// Certificate returns the certificate for given host signed by the CA. The certificates are cached.
+func (ca *CA) Certificate(host string) (*tls.Certificate, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+	if c, ok := ca.leaves[host]; ok && time.Now().Before(c.Leaf.NotAfter) {
+		return c, nil
+	}
+	if ca.leafKey == nil {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		ca.leafKey = key
+	}
+	serial, err := serialNumber()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	tmpl := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: host},
+		NotBefore:    now.Add(-clockSkewSpan),
+		NotAfter:     now.Add(leafValidity),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		tmpl.IPAddresses = []net.IP{ip}
+	} else {
+		tmpl.DNSNames = []string{host}
+	}
+	if tmpl.NotAfter.After(ca.Cert.NotAfter) {
+		tmpl.NotAfter = ca.Cert.NotAfter
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.Cert, ca.leafKey.Public(), ca.Key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	c := &tls.Certificate{
+		Certificate: [][]byte{der, ca.Cert.Raw},
+		PrivateKey:  ca.leafKey,
+		Leaf:        leaf,
+	}
+	if ca.leaves == nil {
+		ca.leaves = make(map[string]*tls.Certificate)
+	}
+	ca.leaves[host] = c
+	return c, nil
+}
+
+func serialNumber() (*big.Int, error) {
+	return rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+}
@@ -0,0 +1,56 @@
+package proxy
+
+import (
+	"crypto/x509"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewCA(t *testing.T) {
+	t.Parallel()
+	ca, err := NewCA()
+	check(t, err)
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.Cert)
+	cases := map[string]bool{
+		"portal.student.kit.ac.jp": true,
+		"example.com":              false,
+		"kit.ac.jp.example.com":    false,
+		"192.0.2.1":                false,
+	}
+	for host, expected := range cases {
+		c, err := ca.Certificate(host)
+		check(t, err)
+		_, err = c.Leaf.Verify(x509.VerifyOptions{DNSName: host, Roots: roots})
+		if (err == nil) != expected {
+			t.Errorf("Expect: trusted for %s is %v\nActual: %v\n", host, expected, err)
+		}
+	}
+}
+
+func TestLoadOrCreateCA(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "kitwalk")
+	check(t, err)
+	defer os.RemoveAll(dir)
+	certPath, keyPath := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca-key.pem")
+	ca, err := LoadOrCreateCA(certPath, keyPath)
+	check(t, err)
+	loaded, err := LoadOrCreateCA(certPath, keyPath)
+	check(t, err)
+	if !loaded.Cert.Equal(ca.Cert) {
+		t.Error("Expect: the saved CA loaded")
+	}
+
+	check(t, os.Remove(keyPath))
+	if _, err := LoadOrCreateCA(certPath, keyPath); err == nil {
+		t.Error("Expect: error without the key\nActual: (nil)")
+	}
+	b, err := ioutil.ReadFile(certPath)
+	check(t, err)
+	if string(b) == "" {
+		t.Error("Expect: the certificate left as it is")
+	}
+}
@@ -0,0 +1,259 @@
+// Package proxy lets programs in any language reach the websites protected with Shibboleth
+// through a local HTTP proxy which sends requests with kitwalk's session.
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/base64"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+const dialTimeout = 30 * time.Second
+
+// hopHeaders are removed when a request or a response is forwarded. See RFC 7230 section 6.1.
+var hopHeaders = []string{
+	"Connection",
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Proxy-Connection",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// Forward is an HTTP forward proxy. Requests to Hosts are sent with Client,
+// so they carry the session cookies and log in again when the session expires.
+// Requests to other hosts are forwarded as they are.
+type Forward struct {
+	Client *kitwalk.ReloginClient
+	// Hosts are the hosts of the websites protected with Shibboleth, such as "portal.student.kit.ac.jp".
+	Hosts []string
+	// CA is used to intercept CONNECT to Hosts. Without it, CONNECT is always tunneled,
+	// and HTTPS requests to Hosts do not carry the session.
+	CA *CA
+	// Transport is used for requests to other hosts. http.DefaultTransport is used when it is nil.
+	Transport http.RoundTripper
+	// Token is the access token required for all requests when it is not empty.
+	// It is given with "Proxy-Authorization: Bearer", or as the password of "Proxy-Authorization: Basic"
+	// such as HTTP_PROXY=http://kitwalk:<token>@127.0.0.1:8080.
+	Token string
+}
+
+// NewForward create new forward proxy for given hosts.
+// Redirects are not followed by the proxy; they are passed to the proxy client.
+func NewForward(client *kitwalk.ReloginClient, ca *CA, hosts ...string) *Forward {
+	client.Client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Forward{Client: client, Hosts: hosts, CA: ca}
+}
+
+func (f *Forward) intercepts(hostport string) bool {
+	host := hostport
+	if h, _, err := net.SplitHostPort(hostport); err == nil {
+		host = h
+	}
+	for _, h := range f.Hosts {
+		if strings.EqualFold(h, host) {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *Forward) authorized(r *http.Request) bool {
+	if f.Token == "" {
+		return true
+	}
+	var given string
+	h := r.Header.Get("Proxy-Authorization")
+	if strings.HasPrefix(h, "Bearer ") {
+		given = strings.TrimPrefix(h, "Bearer ")
+	} else if strings.HasPrefix(h, "Basic ") {
+		b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(h, "Basic "))
+		if err != nil {
+			return false
+		}
+		if i := strings.IndexByte(string(b), ':'); i >= 0 {
+			given = string(b[i+1:])
+		}
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(f.Token)) == 1
+}
+
+// ServeHTTP handles both of absolute-form requests and CONNECT.
+// The requests in an intercepted connection are authorized by its CONNECT.
+func (f *Forward) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !f.authorized(r) {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="kitwalk"`)
+		http.Error(w, http.StatusText(http.StatusProxyAuthRequired), http.StatusProxyAuthRequired)
+		return
+	}
+	if r.Method == http.MethodConnect {
+		if f.CA != nil && f.intercepts(r.Host) {
+			f.intercept(w, r)
+		} else {
+			f.tunnel(w, r)
+		}
+		return
+	}
+	if !r.URL.IsAbs() {
+		http.Error(w, "This is a proxy server. Request with an absolute url.", http.StatusBadRequest)
+		return
+	}
+	resp, err := f.roundTrip(r, r.URL.String())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	copyHeader(w.Header(), resp.Header)
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+}
+
+// roundTrip forwards the request to given url.
+func (f *Forward) roundTrip(r *http.Request, target string) (*http.Response, error) {
+	// Read the body to send it again after login.
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	var reqBody io.Reader
+	if len(body) != 0 {
+		reqBody = bytes.NewReader(body)
+	}
+	out, err := http.NewRequest(r.Method, target, reqBody)
+	if err != nil {
+		return nil, err
+	}
+	out = out.WithContext(r.Context())
+	copyHeader(out.Header, r.Header)
+	if !f.intercepts(out.URL.Host) {
+		transport := f.Transport
+		if transport == nil {
+			transport = http.DefaultTransport
+		}
+		return transport.RoundTrip(out)
+	}
+	return f.Client.Do(out)
+}
+
+// intercept terminates TLS with the certificate signed by CA, and forwards the requests in the connection.
+func (f *Forward) intercept(w http.ResponseWriter, r *http.Request) {
+	host, _, err := net.SplitHostPort(r.Host)
+	if err != nil {
+		host = r.Host
+	}
+	cert, err := f.CA.Certificate(host)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	conn, err := hijack(w)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{*cert}})
+	if err := tlsConn.Handshake(); err != nil {
+		return
+	}
+	reader := bufio.NewReader(tlsConn)
+	for {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			return
+		}
+		req = req.WithContext(r.Context())
+		resp, err := f.roundTrip(req, "https://"+r.Host+req.URL.RequestURI())
+		if err != nil {
+			resp = &http.Response{
+				StatusCode: http.StatusBadGateway,
+				ProtoMajor: 1,
+				ProtoMinor: 1,
+				Header:     http.Header{"Content-Type": {"text/plain; charset=utf-8"}},
+				Body:       ioutil.NopCloser(strings.NewReader(err.Error())),
+			}
+		}
+		for _, h := range hopHeaders {
+			resp.Header.Del(h)
+		}
+		err = resp.Write(tlsConn)
+		resp.Body.Close()
+		if err != nil || req.Close {
+			return
+		}
+	}
+}
+
+// tunnel connects the client to the target without looking into the connection.
+func (f *Forward) tunnel(w http.ResponseWriter, r *http.Request) {
+	upstream, err := net.DialTimeout("tcp", r.Host, dialTimeout)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer upstream.Close()
+	conn, err := hijack(w)
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	var wg sync.WaitGroup
+	wg.Add(2)
+	pipe := func(dst, src net.Conn) {
+		defer wg.Done()
+		io.Copy(dst, src)
+		if c, ok := dst.(*net.TCPConn); ok {
+			c.CloseWrite()
+		}
+	}
+	go pipe(upstream, conn)
+	go pipe(conn, upstream)
+	wg.Wait()
+}
+
+// hijack takes over the connection and tells the client that the tunnel is established.
+func hijack(w http.ResponseWriter) (net.Conn, error) {
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Hijacking is not supported", http.StatusInternalServerError)
+		return nil, http.ErrNotSupported
+	}
+	conn, buf, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := buf.WriteString("HTTP/1.1 200 Connection Established\r\n\r\n"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := buf.Flush(); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+func copyHeader(dst, src http.Header) {
+	for k, v := range src {
+		dst[k] = append([]string(nil), v...)
+	}
+	for _, h := range hopHeaders {
+		dst.Del(h)
+	}
+}
@@ -0,0 +1,111 @@
+package proxy
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+func check(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// cookieEcho responds with the value of the session cookie.
+func cookieEcho(w http.ResponseWriter, r *http.Request) {
+	c, err := r.Cookie("_shibsession_test")
+	if err != nil {
+		http.Error(w, "no session", http.StatusForbidden)
+		return
+	}
+	w.Write([]byte(c.Value))
+}
+
+func sessionClient(t *testing.T, upstream *httptest.Server) *kitwalk.ReloginClient {
+	jar, err := cookiejar.New(nil)
+	check(t, err)
+	u, _ := url.Parse(upstream.URL)
+	jar.SetCookies(u, []*http.Cookie{{Name: "_shibsession_test", Value: "secret"}})
+	client := upstream.Client()
+	client.Jar = jar
+	return kitwalk.NewReloginClient(nil, client)
+}
+
+func get(t *testing.T, client *http.Client, target string) string {
+	resp, err := client.Get(target)
+	check(t, err)
+	defer resp.Body.Close()
+	b, err := ioutil.ReadAll(resp.Body)
+	check(t, err)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("Expect: 200\nActual: %d %s\n", resp.StatusCode, b)
+	}
+	return string(b)
+}
+
+func TestForward(t *testing.T) {
+	t.Parallel()
+	t.Run("Inject session to plain HTTP request", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(cookieEcho))
+		defer upstream.Close()
+		u, _ := url.Parse(upstream.URL)
+		proxy := httptest.NewServer(NewForward(sessionClient(t, upstream), nil, u.Hostname()))
+		defer proxy.Close()
+		proxyURL, _ := url.Parse(proxy.URL)
+		client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+		if body := get(t, client, upstream.URL); body != "secret" {
+			t.Errorf("Expect: secret\nActual: %s\n", body)
+		}
+	})
+	t.Run("Intercept CONNECT with local CA", func(t *testing.T) {
+		upstream := httptest.NewTLSServer(http.HandlerFunc(cookieEcho))
+		defer upstream.Close()
+		u, _ := url.Parse(upstream.URL)
+		ca, err := NewCA(u.Hostname())
+		check(t, err)
+		proxy := httptest.NewServer(NewForward(sessionClient(t, upstream), ca, u.Hostname()))
+		defer proxy.Close()
+		proxyURL, _ := url.Parse(proxy.URL)
+		roots := x509.NewCertPool()
+		roots.AddCert(ca.Cert)
+		client := &http.Client{Transport: &http.Transport{
+			Proxy:           http.ProxyURL(proxyURL),
+			TLSClientConfig: &tls.Config{RootCAs: roots},
+		}}
+		if body := get(t, client, upstream.URL); body != "secret" {
+			t.Errorf("Expect: secret\nActual: %s\n", body)
+		}
+	})
+	t.Run("Require token", func(t *testing.T) {
+		upstream := httptest.NewServer(http.HandlerFunc(cookieEcho))
+		defer upstream.Close()
+		u, _ := url.Parse(upstream.URL)
+		forward := NewForward(sessionClient(t, upstream), nil, u.Hostname())
+		forward.Token = "token"
+		proxy := httptest.NewServer(forward)
+		defer proxy.Close()
+		proxyURL, _ := url.Parse(proxy.URL)
+
+		client := &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+		resp, err := client.Get(upstream.URL)
+		check(t, err)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusProxyAuthRequired {
+			t.Errorf("Expect: 407\nActual: %d\n", resp.StatusCode)
+		}
+
+		proxyURL.User = url.UserPassword("kitwalk", "token")
+		client = &http.Client{Transport: &http.Transport{Proxy: http.ProxyURL(proxyURL)}}
+		if body := get(t, client, upstream.URL); body != "secret" {
+			t.Errorf("Expect: secret\nActual: %s\n", body)
+		}
+	})
+}
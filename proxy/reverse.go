@@ -0,0 +1,269 @@
+package proxy
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/StudioAquatan/kitwalk"
+)
+
+const (
+	// TokenCookie is the cookie to keep the access token in browsers.
+	TokenCookie = "kitwalk_token"
+	// TokenQuery is the query parameter to give the access token. It is moved into TokenCookie,
+	// and never forwarded to the upstream.
+	TokenQuery = "kitwalk_token"
+)
+
+// rewriteTypes are the content types whose absolute links to the upstream are rewritten.
+var rewriteTypes = []string{
+	"text/html",
+	"text/css",
+	"text/javascript",
+	"application/javascript",
+	"application/json",
+	"application/xhtml+xml",
+}
+
+// Reverse is a reverse proxy which presents the upstream website on the local host as if no login were needed.
+// Links and redirects pointing the upstream are rewritten to the local host. The cookies of the upstream are kept
+// in the jar of the client, and only the other cookies of browsers, such as the ones set by scripts, are forwarded.
+type Reverse struct {
+	Client   *kitwalk.ReloginClient
+	Upstream *url.URL
+	// Token is the access token required for all requests when it is not empty.
+	// It is given with "Authorization: Bearer", TokenQuery or TokenCookie.
+	Token string
+}
+
+// NewReverse create new reverse proxy for given upstream.
+// Redirects are not followed by the proxy; they are rewritten and passed to browsers.
+func NewReverse(client *kitwalk.ReloginClient, upstream *url.URL) *Reverse {
+	client.Client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	return &Reverse{Client: client, Upstream: upstream}
+}
+
+// IsLoopback reports whether the address to listen such as "127.0.0.1:8000" accepts only local connections.
+func IsLoopback(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authorized reports whether the request has the token. fromQuery reports that it is given with TokenQuery.
+func (p *Reverse) authorized(r *http.Request) (ok, fromQuery bool) {
+	if p.Token == "" {
+		return true, false
+	}
+	var given string
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		given = strings.TrimPrefix(h, "Bearer ")
+	} else if q := r.URL.Query().Get(TokenQuery); q != "" {
+		given, fromQuery = q, true
+	} else if c, err := r.Cookie(TokenCookie); err == nil {
+		given = c.Value
+	}
+	return subtle.ConstantTimeCompare([]byte(given), []byte(p.Token)) == 1, fromQuery
+}
+
+// ServeHTTP forwards the request to the upstream.
+// A token given with TokenQuery is moved into TokenCookie, and GET and HEAD requests are redirected
+// to the url without it, so the token is left neither in the address bar nor in the history.
+func (p *Reverse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ok, fromQuery := p.authorized(r)
+	if !ok {
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+	if fromQuery {
+		http.SetCookie(w, &http.Cookie{Name: TokenCookie, Value: p.Token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode})
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			u := *r.URL
+			u.RawQuery = withoutToken(r.URL.RawQuery)
+			http.Redirect(w, r, u.RequestURI(), http.StatusFound)
+			return
+		}
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var reqBody io.Reader
+	if len(body) != 0 {
+		reqBody = bytes.NewReader(body)
+	}
+	out, err := http.NewRequest(r.Method, p.upstreamURL(r.URL), reqBody)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	out = out.WithContext(r.Context())
+	copyHeader(out.Header, r.Header)
+	// Let the transport handle compression, so the body can be rewritten.
+	out.Header.Del("Accept-Encoding")
+	out.Header.Del("Authorization")
+	managed := p.managed(out.URL)
+	removeCookies(out.Header, func(name string) bool {
+		return name == TokenCookie || managed(name)
+	})
+
+	resp, err := p.Client.Do(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+	local := localOrigin(r)
+	copyHeader(w.Header(), resp.Header)
+	p.rewriteHeader(w.Header(), local, r.TLS != nil)
+	if !p.rewritable(resp.Header.Get("Content-Type")) {
+		w.WriteHeader(resp.StatusCode)
+		io.Copy(w, resp.Body)
+		return
+	}
+	b, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	b = p.rewriteBody(b, local)
+	w.Header().Set("Content-Length", strconv.Itoa(len(b)))
+	w.WriteHeader(resp.StatusCode)
+	w.Write(b)
+}
+
+func (p *Reverse) upstreamURL(local *url.URL) string {
+	u := *p.Upstream
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + strings.TrimPrefix(local.Path, "/")
+	u.RawPath = ""
+	u.RawQuery = withoutToken(local.RawQuery)
+	return u.String()
+}
+
+// withoutToken removes TokenQuery from the query.
+func withoutToken(rawQuery string) string {
+	q, err := url.ParseQuery(rawQuery)
+	if err != nil || q[TokenQuery] == nil {
+		return rawQuery
+	}
+	q.Del(TokenQuery)
+	return q.Encode()
+}
+
+// upstreamBase is the absolute url of the upstream which is mapped to the root of the local host.
+func (p *Reverse) upstreamBase() string {
+	return p.Upstream.Scheme + "://" + p.Upstream.Host + strings.TrimSuffix(p.Upstream.Path, "/")
+}
+
+// localPath converts the path on the upstream into the one on the local host.
+func (p *Reverse) localPath(upstreamPath string) string {
+	prefix := strings.TrimSuffix(p.Upstream.Path, "/")
+	if strings.HasPrefix(upstreamPath, prefix) {
+		upstreamPath = strings.TrimPrefix(upstreamPath, prefix)
+	}
+	if !strings.HasPrefix(upstreamPath, "/") {
+		upstreamPath = "/" + upstreamPath
+	}
+	return upstreamPath
+}
+
+func (p *Reverse) rewriteHeader(h http.Header, local string, secure bool) {
+	if loc := h.Get("Location"); loc != "" {
+		if u, err := url.Parse(loc); err == nil && strings.EqualFold(u.Host, p.Upstream.Host) {
+			h.Set("Location", local+p.localPath(u.Path)+queryOf(u))
+		}
+	}
+	cookies := h["Set-Cookie"]
+	h.Del("Set-Cookie")
+	if p.Client.Client.Jar != nil {
+		// The jar of the client has stored them, and sends them to the upstream.
+		return
+	}
+	for _, line := range cookies {
+		resp := http.Response{Header: http.Header{"Set-Cookie": {line}}}
+		for _, c := range resp.Cookies() {
+			c.Domain = ""
+			c.Path = p.localPath(c.Path)
+			if !secure {
+				c.Secure = false
+			}
+			h.Add("Set-Cookie", c.String())
+		}
+	}
+}
+
+func (p *Reverse) rewritable(contentType string) bool {
+	for _, t := range rewriteTypes {
+		if strings.HasPrefix(contentType, t) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Reverse) rewriteBody(b []byte, local string) []byte {
+	base := p.upstreamBase()
+	b = bytes.Replace(b, []byte(base), []byte(local), -1)
+	// Escaped form in JSON and scripts.
+	b = bytes.Replace(b, []byte(strings.Replace(base, "/", `\/`, -1)), []byte(strings.Replace(local, "/", `\/`, -1)), -1)
+	return b
+}
+
+func localOrigin(r *http.Request) string {
+	if r.TLS != nil {
+		return "https://" + r.Host
+	}
+	return "http://" + r.Host
+}
+
+func queryOf(u *url.URL) string {
+	if u.RawQuery == "" {
+		return ""
+	}
+	return "?" + u.RawQuery
+}
+
+// managed returns the function reporting whether the cookie of given name is kept by the jar of the client.
+// Such cookies in browsers are stale copies, and the session cookies of Shibboleth are never taken from browsers.
+func (p *Reverse) managed(target *url.URL) func(name string) bool {
+	names := make(map[string]bool)
+	if jar := p.Client.Client.Jar; jar != nil {
+		for _, c := range jar.Cookies(target) {
+			names[c.Name] = true
+		}
+	}
+	return func(name string) bool {
+		return names[name] || strings.HasPrefix(name, kitwalk.SPSessionCookiePrefix)
+	}
+}
+
+// removeCookies drops the cookies whose name matches from the Cookie header.
+func removeCookies(h http.Header, match func(name string) bool) {
+	r := http.Request{Header: h}
+	var kept []string
+	for _, c := range r.Cookies() {
+		if !match(c.Name) {
+			kept = append(kept, (&http.Cookie{Name: c.Name, Value: c.Value}).String())
+		}
+	}
+	h.Del("Cookie")
+	if len(kept) != 0 {
+		h.Set("Cookie", strings.Join(kept, "; "))
+	}
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestReverse(t *testing.T) {
+	t.Parallel()
+	var upstream *httptest.Server
+	upstream = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query()[TokenQuery] != nil {
+			t.Errorf("The token is forwarded to the upstream: %s", r.URL)
+		}
+		if _, err := r.Cookie("_shibsession_test"); err != nil {
+			http.Error(w, "no session", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/redirect":
+			http.Redirect(w, r, upstream.URL+"/page?a=b", http.StatusFound)
+		case "/form":
+			w.WriteHeader(http.StatusNoContent)
+		case "/cookies":
+			for _, c := range r.Cookies() {
+				w.Write([]byte(c.Name + "=" + c.Value + ";"))
+			}
+		case "/page":
+			u, _ := url.Parse(upstream.URL)
+			http.SetCookie(w, &http.Cookie{Name: "JSESSIONID", Value: "id", Domain: u.Hostname(), Path: "/"})
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte(`<a href="` + upstream.URL + `/next">next</a>`))
+		}
+	}))
+	defer upstream.Close()
+	u, _ := url.Parse(upstream.URL)
+	p := NewReverse(sessionClient(t, upstream), u)
+	p.Token = "token"
+	local := httptest.NewServer(p)
+	defer local.Close()
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+
+	t.Run("Reject without token", func(t *testing.T) {
+		resp, err := client.Get(local.URL + "/page")
+		check(t, err)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Expect: 401\nActual: %d\n", resp.StatusCode)
+		}
+	})
+	t.Run("Move token into cookie", func(t *testing.T) {
+		resp, err := client.Get(local.URL + "/redirect?x=1&" + TokenQuery + "=token")
+		check(t, err)
+		resp.Body.Close()
+		if loc := resp.Header.Get("Location"); loc != "/redirect?x=1" {
+			t.Errorf("Expect: /redirect?x=1\nActual: %s\n", loc)
+		}
+		var cookie *http.Cookie
+		for _, c := range resp.Cookies() {
+			if c.Name == TokenCookie {
+				cookie = c
+			}
+		}
+		if cookie == nil || cookie.Value != "token" {
+			t.Fatalf("Expect: %s cookie\nActual: %v\n", TokenCookie, resp.Cookies())
+		}
+
+		req, _ := http.NewRequest(http.MethodPost, local.URL+"/form?"+TokenQuery+"=token", strings.NewReader("a=b"))
+		resp, err = client.Do(req)
+		check(t, err)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusNoContent {
+			t.Errorf("Expect: 204\nActual: %d\n", resp.StatusCode)
+		}
+	})
+	t.Run("Rewrite redirect", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, local.URL+"/redirect", nil)
+		req.AddCookie(&http.Cookie{Name: TokenCookie, Value: "token"})
+		resp, err := client.Do(req)
+		check(t, err)
+		resp.Body.Close()
+		if loc := resp.Header.Get("Location"); loc != local.URL+"/page?a=b" {
+			t.Errorf("Expect: %s/page?a=b\nActual: %s\n", local.URL, loc)
+		}
+	})
+	t.Run("Rewrite links and cookies", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, local.URL+"/page", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		resp, err := client.Do(req)
+		check(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		check(t, err)
+		if string(b) != `<a href="`+local.URL+`/next">next</a>` {
+			t.Errorf("Unexpected body %s", b)
+		}
+		if c := resp.Header.Get("Set-Cookie"); c != "" {
+			t.Errorf("Expect: cookies kept in the jar\nActual: %s\n", c)
+		}
+	})
+	t.Run("Send only the cookies of browsers not in the jar", func(t *testing.T) {
+		req, _ := http.NewRequest(http.MethodGet, local.URL+"/cookies", nil)
+		req.Header.Set("Authorization", "Bearer token")
+		req.Header.Set("Cookie", "_shibsession_test=stale; JSESSIONID=stale; pref=1")
+		resp, err := client.Do(req)
+		check(t, err)
+		defer resp.Body.Close()
+		b, err := ioutil.ReadAll(resp.Body)
+		check(t, err)
+		if string(b) != "pref=1;_shibsession_test=secret;JSESSIONID=id;" {
+			t.Errorf("Expect: the cookies in the jar and pref\nActual: %s\n", b)
+		}
+	})
+}
+
+func TestIsLoopback(t *testing.T) {
+	t.Parallel()
+	cases := map[string]bool{
+		"127.0.0.1:8000": true,
+		"localhost:8000": true,
+		"[::1]:8000":     true,
+		":8000":          false,
+		"0.0.0.0:8000":   false,
+		"192.0.2.1:8000": false,
+	}
+	for addr, expected := range cases {
+		if IsLoopback(addr) != expected {
+			t.Errorf("IsLoopback(%q) should be %v", addr, expected)
+		}
+	}
+}
@@ -0,0 +1,338 @@
+// Package registration checks the course registration ("履修登録") on the portal for period conflicts,
+// credit caps and missing required courses. It only reads the portal, and never submits changes.
+package registration
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/StudioAquatan/kitwalk/internal/text"
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+// Kind is the kind of a problem in the registration.
+type Kind int
+
+const (
+	// Conflict means courses are held at the same period.
+	Conflict Kind = iota + 1
+	// OverCap means the credits of a term exceed the cap.
+	OverCap
+	// MissingRequired means a required course is not registered.
+	MissingRequired
+	// Unconfirmed means the registration has not been accepted yet, such as during a lottery.
+	Unconfirmed
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Conflict:
+		return "conflict"
+	case OverCap:
+		return "over_cap"
+	case MissingRequired:
+		return "missing_required"
+	case Unconfirmed:
+		return "unconfirmed"
+	}
+	return fmt.Sprintf("Kind(%d)", int(k))
+}
+
+// Problem is a problem found in the registration.
+type Problem struct {
+	Kind Kind `json:"kind"`
+	// Term is the term of the problem. It is empty for MissingRequired.
+	Term string `json:"term,omitempty"`
+	// Codes are the timetable codes of the courses concerned.
+	Codes   []string `json:"codes,omitempty"`
+	Message string   `json:"message"`
+}
+
+// ConfirmedStatus is the status of an accepted registration.
+var ConfirmedStatus = []string{"登録済", "確定"}
+
+// quarters maps the quarters to the semesters containing them.
+var quarters = map[string]string{
+	"第1クォーター": "前期",
+	"第2クォーター": "前期",
+	"第3クォーター": "後期",
+	"第4クォーター": "後期",
+}
+
+// yearLong is the term of the courses through the year.
+const yearLong = "通年"
+
+// halves are the semesters of the year, which share the credits of the courses through the year.
+var halves = []string{"前期", "後期"}
+
+// Rules are the conditions of the registration.
+type Rules struct {
+	// Caps are the maximum credits of the semesters such as "前期". Quarters are counted in their semester,
+	// and a course through the year is counted in 前期 and 後期 by half.
+	Caps map[string]float64
+	// DefaultCap is the maximum credits of the semesters not in Caps. No cap is applied when it is zero.
+	DefaultCap float64
+	// Exempt are the categories not counted toward the caps, such as "教職科目".
+	Exempt []string
+	// Required are the timetable codes or the titles of the courses to register.
+	Required []string
+}
+
+func (r *Rules) capOf(semester string) float64 {
+	if c, ok := r.Caps[semester]; ok {
+		return c
+	}
+	return r.DefaultCap
+}
+
+// Validate checks the registrations and returns the problems found.
+// The courses in the timetable which are not in the registrations are also checked for conflicts. tt may be nil.
+func Validate(registrations []portal.Registration, tt *portal.Timetable, rules Rules) []Problem {
+	var problems []Problem
+	problems = append(problems, conflicts(registrations, tt)...)
+
+	credits := Credits(registrations, rules.Exempt)
+	var semesters []string
+	for s := range credits {
+		semesters = append(semesters, s)
+	}
+	sort.Strings(semesters)
+	for _, s := range semesters {
+		if limit := rules.capOf(s); limit > 0 && credits[s] > limit {
+			var codes []string
+			for _, r := range registrations {
+				if (semester(r.Term) == s || r.Term == yearLong && text.Contains(halves, s)) && !text.Contains(rules.Exempt, r.Category) {
+					codes = append(codes, r.Code)
+				}
+			}
+			problems = append(problems, Problem{
+				Kind:    OverCap,
+				Term:    s,
+				Codes:   codes,
+				Message: fmt.Sprintf("%s: %g credits exceed the cap of %g.", s, credits[s], limit),
+			})
+		}
+	}
+
+	for _, required := range rules.Required {
+		found := false
+		for _, r := range registrations {
+			if r.Code == required || text.Normalize(r.Title) == text.Normalize(required) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			problems = append(problems, Problem{Kind: MissingRequired, Message: fmt.Sprintf("%s is not registered.", required)})
+		}
+	}
+
+	for _, r := range registrations {
+		if r.Status != "" && !text.Contains(ConfirmedStatus, r.Status) {
+			problems = append(problems, Problem{
+				Kind:    Unconfirmed,
+				Term:    r.Term,
+				Codes:   []string{r.Code},
+				Message: fmt.Sprintf("%s is %s.", r.Title, r.Status),
+			})
+		}
+	}
+	return problems
+}
+
+// conflicts finds the pairs of courses held at the same period in overlapping terms.
+func conflicts(registrations []portal.Registration, tt *portal.Timetable) []Problem {
+	var courses []portal.Course
+	registered := make(map[string]bool)
+	for _, r := range registrations {
+		registered[r.Code] = true
+		courses = append(courses, r.Courses()...)
+	}
+	if tt != nil {
+		for _, c := range tt.Courses {
+			if !registered[c.Code] {
+				courses = append(courses, c)
+			}
+		}
+	}
+	var problems []Problem
+	reported := make(map[string]bool)
+	for i, a := range courses {
+		for _, b := range courses[i+1:] {
+			if a.Code == b.Code || !a.Scheduled() || !b.Scheduled() || a.Day != b.Day ||
+				!overlaps(a.Term, b.Term) || a.Period+length(a) <= b.Period || b.Period+length(b) <= a.Period {
+				continue
+			}
+			key := a.Code + "/" + b.Code
+			if reported[key] {
+				continue
+			}
+			reported[key] = true
+			term := a.Term
+			if term == yearLong || term == "" {
+				term = b.Term
+			}
+			problems = append(problems, Problem{
+				Kind:  Conflict,
+				Term:  term,
+				Codes: []string{a.Code, b.Code},
+				Message: fmt.Sprintf("%s%d: %s and %s are held at the same period.",
+					dayNames[a.Day], maxPeriod(a, b), a.Title, b.Title),
+			})
+		}
+	}
+	return problems
+}
+
+var dayNames = []string{"日", "月", "火", "水", "木", "金", "土"}
+
+func length(c portal.Course) int {
+	if c.Length < 1 {
+		return 1
+	}
+	return c.Length
+}
+
+// maxPeriod returns the first period both courses are held.
+func maxPeriod(a, b portal.Course) int {
+	if a.Period > b.Period {
+		return a.Period
+	}
+	return b.Period
+}
+
+// semester returns the semester containing the term.
+func semester(term string) string {
+	if s, ok := quarters[term]; ok {
+		return s
+	}
+	return term
+}
+
+// overlaps reports whether the terms share any week. A course through the year overlaps with all terms.
+func overlaps(a, b string) bool {
+	if a == "" || b == "" || a == b || a == yearLong || b == yearLong {
+		return true
+	}
+	_, qa := quarters[a]
+	_, qb := quarters[b]
+	if qa && qb {
+		return false
+	}
+	return semester(a) == semester(b)
+}
+
+// Credits returns the credits registered in each semester except the exempt categories.
+// The credits of a course through the year are split into halves for 前期 and 後期.
+func Credits(registrations []portal.Registration, exempt []string) map[string]float64 {
+	credits := make(map[string]float64)
+	for _, r := range registrations {
+		if text.Contains(exempt, r.Category) {
+			continue
+		}
+		if r.Term == yearLong {
+			for _, s := range halves {
+				credits[s] += r.Credits / float64(len(halves))
+			}
+			continue
+		}
+		credits[semester(r.Term)] += r.Credits
+	}
+	return credits
+}
+
+// Simulate returns the registrations after adding and dropping courses, to validate planned changes
+// before registering them on the portal. drop are the timetable codes of the courses to drop.
+func Simulate(registrations []portal.Registration, add []portal.Registration, drop ...string) []portal.Registration {
+	var result []portal.Registration
+	for _, r := range registrations {
+		if !text.Contains(drop, r.Code) {
+			result = append(result, r)
+		}
+	}
+	for _, r := range add {
+		if r.Status == "" {
+			r.Status = ConfirmedStatus[0]
+		}
+		result = append(result, r)
+	}
+	return result
+}
+
+// Report is the result of a check.
+type Report struct {
+	Registrations []portal.Registration `json:"registrations"`
+	// Credits are the credits counted toward the caps in each semester.
+	Credits  map[string]float64 `json:"credits"`
+	Problems []Problem          `json:"problems"`
+}
+
+// OK reports whether no problem is found.
+func (r *Report) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// NewReport validates the registrations and creates the report.
+func NewReport(registrations []portal.Registration, tt *portal.Timetable, rules Rules) *Report {
+	return &Report{
+		Registrations: registrations,
+		Credits:       Credits(registrations, rules.Exempt),
+		Problems:      Validate(registrations, tt, rules),
+	}
+}
+
+// WriteReport writes the report as plain text.
+func WriteReport(w io.Writer, r *Report) error {
+	var b strings.Builder
+	for _, reg := range r.Registrations {
+		fmt.Fprintf(&b, "%s %s (%s, %g単位) %s\n", reg.Code, reg.Title, reg.Term, reg.Credits, reg.Status)
+	}
+	var semesters []string
+	for s := range r.Credits {
+		semesters = append(semesters, s)
+	}
+	sort.Strings(semesters)
+	for _, s := range semesters {
+		fmt.Fprintf(&b, "%s: %g単位\n", s, r.Credits[s])
+	}
+	if r.OK() {
+		b.WriteString("No problem found.\n")
+	}
+	for _, p := range r.Problems {
+		fmt.Fprintf(&b, "[%s] %s\n", p.Kind, p.Message)
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Checker fetches the registration list and the timetable from the portal, and validates them.
+// Set Portal.Pages.Registration, and Portal.Pages.Timetable to check the courses only in the timetable.
+type Checker struct {
+	Portal *portal.Client
+	Rules  Rules
+}
+
+// NewChecker create new checker with given http.Client logged in with kitwalk.
+func NewChecker(client *http.Client, rules Rules) *Checker {
+	return &Checker{Portal: portal.NewClient(client), Rules: rules}
+}
+
+// Check fetches the registrations and the timetable, and validates them. Nothing is submitted to the portal.
+// The timetable is not fetched when Portal.Pages.Timetable is not set.
+func (c *Checker) Check(ctx context.Context) (*Report, error) {
+	registrations, err := c.Portal.Registrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var tt *portal.Timetable
+	if c.Portal.Pages.Timetable.IsSet() {
+		if tt, err = c.Portal.Timetable(ctx); err != nil {
+			return nil, err
+		}
+	}
+	return NewReport(registrations, tt, c.Rules), nil
+}
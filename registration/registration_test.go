@@ -0,0 +1,158 @@
+package registration
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+var rules = Rules{
+	Caps:     map[string]float64{"前期": 6},
+	Exempt:   []string{"教職科目"},
+	Required: []string{"線形代数学 I", "10990999"},
+}
+
+func samples(t *testing.T) ([]portal.Registration, *portal.Timetable) {
+	t.Helper()
+	f, err := os.Open("../samples/registration.html")
+	check(t, err)
+	defer f.Close()
+	registrations, err := portal.ParseRegistrations(f, "table.registration")
+	check(t, err)
+	g, err := os.Open("../samples/timetable.html")
+	check(t, err)
+	defer g.Close()
+	tt, err := portal.ParseTimetable(g, "table.timetable", "table.timetable_other")
+	check(t, err)
+	return registrations, tt
+}
+
+func kinds(problems []Problem) map[Kind]int {
+	count := make(map[Kind]int)
+	for _, p := range problems {
+		count[p.Kind]++
+	}
+	return count
+}
+
+func TestValidate(t *testing.T) {
+	t.Parallel()
+	registrations, tt := samples(t)
+	problems := Validate(registrations, tt, rules)
+	count := kinds(problems)
+	if count[Conflict] != 2 || count[OverCap] != 1 || count[MissingRequired] != 1 || count[Unconfirmed] != 1 || len(problems) != 5 {
+		t.Fatalf("Expect: 2 conflicts, 1 over cap, 1 missing and 1 unconfirmed\nActual: %+v\n", problems)
+	}
+	// The registered course conflicts with the course only in the timetable.
+	if p := problems[1]; p.Codes[0] != "10140101" || p.Codes[1] != "10130301" || p.Message != "火3: プログラミング演習 and 情報工学実験 are held at the same period." {
+		t.Errorf("Unexpected conflict %+v", p)
+	}
+	if p := problems[2]; p.Term != "前期" || p.Message != "前期: 7 credits exceed the cap of 6." {
+		t.Errorf("Unexpected over cap %+v", p)
+	}
+	if p := problems[3]; p.Message != "10990999 is not registered." {
+		t.Errorf("Unexpected missing course %+v", p)
+	}
+}
+
+func TestOverlaps(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		a, b   string
+		expect bool
+	}{
+		{"前期", "前期", true},
+		{"前期", "後期", false},
+		{"第1クォーター", "前期", true},
+		{"第1クォーター", "第2クォーター", false},
+		{"第3クォーター", "前期", false},
+		{"通年", "後期", true},
+	}
+	for _, c := range cases {
+		if overlaps(c.a, c.b) != c.expect {
+			t.Errorf("Expect: overlaps(%s, %s) = %v\n", c.a, c.b, c.expect)
+		}
+	}
+}
+
+func TestCredits(t *testing.T) {
+	t.Parallel()
+	credits := Credits([]portal.Registration{
+		{Code: "1", Term: "前期", Credits: 2},
+		{Code: "2", Term: "第3クォーター", Credits: 1},
+		{Code: "3", Term: "通年", Credits: 4},
+		{Code: "4", Term: "通年", Credits: 2, Category: "教職科目"},
+	}, []string{"教職科目"})
+	if len(credits) != 2 || credits["前期"] != 4 || credits["後期"] != 3 {
+		t.Errorf("Expect: the course through the year is counted in both semesters\nActual: %v\n", credits)
+	}
+	problems := Validate([]portal.Registration{{Code: "3", Term: "通年", Credits: 4}}, nil, Rules{Caps: map[string]float64{"後期": 1}})
+	if len(problems) != 1 || problems[0].Term != "後期" || problems[0].Codes[0] != "3" {
+		t.Errorf("Expect: 後期 exceeds the cap\nActual: %+v\n", problems)
+	}
+}
+
+func TestSimulate(t *testing.T) {
+	t.Parallel()
+	registrations, tt := samples(t)
+	planned := Simulate(registrations, []portal.Registration{
+		{Code: "10990999", Title: "卒業研究準備", Term: "後期", Credits: 2},
+	}, "10150101", "10140101")
+	problems := Validate(planned, tt, rules)
+	if len(problems) != 1 || problems[0].Kind != Unconfirmed {
+		t.Errorf("Expect: only the unconfirmed course\nActual: %+v\n", problems)
+	}
+	if len(registrations) != 5 {
+		t.Errorf("Expect: registrations not modified\nActual: %d registrations\n", len(registrations))
+	}
+}
+
+func TestChecker_Check(t *testing.T) {
+	t.Parallel()
+	pages := map[string]string{
+		"registration": "../samples/registration.html",
+		"timetable":    "../samples/timetable.html",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			t.Errorf("Expect: only GET\nActual: %s %s\n", r.Method, r.URL)
+		}
+		body, err := ioutil.ReadFile(pages[r.URL.Query().Get("c")])
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write(body)
+	}))
+	defer server.Close()
+	checker := NewChecker(server.Client(), rules)
+	checker.Portal.BaseURL, _ = url.Parse(server.URL + "/")
+	checker.Portal.Pages.Registration = portal.Page{Path: "ead/?c=registration", Table: "table.registration"}
+	checker.Portal.Pages.Timetable = portal.Page{Path: "ead/?c=timetable", Table: "table.timetable"}
+	checker.Portal.Pages.Unscheduled = "table.timetable_other"
+	report, err := checker.Check(context.Background())
+	check(t, err)
+	if report.OK() || len(report.Problems) != 5 || report.Credits["前期"] != 7 {
+		t.Errorf("Unexpected report %+v", report)
+	}
+	var b bytes.Buffer
+	check(t, WriteReport(&b, report))
+	if !strings.Contains(b.String(), "前期: 7単位\n") || !strings.Contains(b.String(), "[over_cap] 前期: 7 credits exceed the cap of 6.\n") {
+		t.Errorf("Unexpected text report\n%s", b.String())
+	}
+}
@@ -26,9 +26,12 @@ func parseSamlResp(body io.Reader) (string, url.Values, error) {
 		panic("Cannot parse response")
 	}
 	// When invalid auth info is posted
-	errorForm := doc.Find("p[class=\"form-error\"]").First()
+	errorForm := doc.Find("p.form-error").First()
 	if errorForm.Length() != 0 {
-		return "", nil, &ShibbolethAuthError{errMsg: errorForm.Text()}
+		if isLockoutMessage(errorForm.Text()) {
+			return "", nil, &AccountLockedError{errMsg: errorForm.Text()}
+		}
+		return "", nil, &ShibbolethAuthError{errMsg: errorForm.Text(), rejected: true}
 	}
 	// Parse SAML response form.
 	// When you use a normal browser such as Chrome or FireFox, this form will be submitted automatically.
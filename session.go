@@ -0,0 +1,211 @@
+package kitwalk
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultSessionLifetime is the typical lifetime of the session of Shibboleth SP.
+	// It is used to estimate when a session expires.
+	DefaultSessionLifetime = 8 * time.Hour
+	sessionDirName         = "kitwalk"
+	sessionFileName        = "session.json"
+	failuresFileName       = "login_failures.json"
+)
+
+// Session is a login state which can be saved and restored later.
+type Session struct {
+	Username   string    `json:"username"`
+	LoggedInAt time.Time `json:"logged_in_at"`
+	// NotOnOrAfter is SessionNotOnOrAfter in the SAML assertion. It is zero when unknown.
+	NotOnOrAfter time.Time      `json:"not_on_or_after"`
+	Cookies      []*http.Cookie `json:"cookies"`
+}
+
+// sessionURLs are the urls which cookies are captured from.
+func sessionURLs(config *Config) []*url.URL {
+	var urls []*url.URL
+	if u, err := url.Parse(config.ShibbolethLoginURL); err == nil {
+		urls = append(urls, u)
+	}
+	urls = append(urls, &url.URL{Scheme: "https", Host: config.ShibbolethAuthDomain, Path: "/idp/"})
+	return urls
+}
+
+// CaptureSession takes the cookies for the website and the auth server from given jar.
+// The attributes of the cookies are kept when the jar is *Jar.
+func CaptureSession(username string, jar http.CookieJar) *Session {
+	s := &Session{Username: username, LoggedInAt: time.Now()}
+	for _, u := range sessionURLs(GetDefaultConfig()) {
+		if j, ok := jar.(*Jar); ok {
+			for _, c := range j.Domain(u.Hostname()) {
+				if !containsCookie(s.Cookies, c) {
+					s.Cookies = append(s.Cookies, c)
+				}
+			}
+			continue
+		}
+		for _, c := range jar.Cookies(u) {
+			s.Cookies = append(s.Cookies, &http.Cookie{
+				Name:   c.Name,
+				Value:  c.Value,
+				Domain: u.Hostname(),
+				Path:   u.Path,
+				Secure: u.Scheme == "https",
+			})
+		}
+	}
+	return s
+}
+
+// Restore puts the cookies of the session into given jar.
+func (s *Session) Restore(jar http.CookieJar) {
+	for _, c := range s.Cookies {
+		cookie := *c
+		if isHostOnly(c) {
+			cookie.Domain = ""
+		}
+		jar.SetCookies(cookieURL(c), []*http.Cookie{&cookie})
+	}
+}
+
+// ExpiresAt returns the estimated time when the session expires.
+// It is the earliest of DefaultSessionLifetime after login, NotOnOrAfter and the expiry of the session cookie of the website.
+func (s *Session) ExpiresAt() time.Time {
+	expires := s.LoggedInAt.Add(DefaultSessionLifetime)
+	if !s.NotOnOrAfter.IsZero() && s.NotOnOrAfter.Before(expires) {
+		expires = s.NotOnOrAfter
+	}
+	for _, c := range s.Cookies {
+		if strings.HasPrefix(c.Name, SPSessionCookiePrefix) && !c.Expires.IsZero() && c.Expires.Before(expires) {
+			expires = c.Expires
+		}
+	}
+	return expires
+}
+
+// FileSessionStore saves a session as a JSON file.
+type FileSessionStore struct {
+	Path string
+}
+
+// DefaultSessionPath returns the path of the session file in the user config directory.
+func DefaultSessionPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, sessionDirName, sessionFileName), nil
+}
+
+// Load reads the session. It returns SessionNotFoundError when the file does not exist.
+func (f *FileSessionStore) Load() (*Session, error) {
+	b, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return nil, &SessionNotFoundError{path: f.Path}
+	}
+	if err != nil {
+		return nil, err
+	}
+	s := &Session{}
+	if err := json.Unmarshal(b, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Save writes the session. The file is readable only by the owner because it has credentials.
+func (f *FileSessionStore) Save(s *Session) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.Path, b)
+}
+
+// writeFileAtomic replaces the file with a temporary file, so that readers never see a partial file.
+// The file is readable only by the owner.
+func writeFileAtomic(path string, b []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(b); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// Delete removes the session. It does nothing when the file does not exist.
+func (f *FileSessionStore) Delete() error {
+	err := os.Remove(f.Path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// failuresPath is the file of login failures next to the session file.
+func (f *FileSessionStore) failuresPath() string {
+	return filepath.Join(filepath.Dir(f.Path), failuresFileName)
+}
+
+func (f *FileSessionStore) loadAllFailures() (map[string][]time.Time, error) {
+	all := make(map[string][]time.Time)
+	b, err := ioutil.ReadFile(f.failuresPath())
+	if os.IsNotExist(err) {
+		return all, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &all); err != nil {
+		return nil, err
+	}
+	return all, nil
+}
+
+// LoadFailures reads the login failures of the user, so that FileSessionStore can be LoginLimiter.Store.
+func (f *FileSessionStore) LoadFailures(username string) ([]time.Time, error) {
+	all, err := f.loadAllFailures()
+	if err != nil {
+		return nil, err
+	}
+	return all[username], nil
+}
+
+// SaveFailures writes the login failures of the user. The user is removed from the file when failures are empty.
+func (f *FileSessionStore) SaveFailures(username string, failures []time.Time) error {
+	all, err := f.loadAllFailures()
+	if err != nil {
+		return err
+	}
+	if len(failures) == 0 {
+		if _, ok := all[username]; !ok {
+			return nil
+		}
+		delete(all, username)
+	} else {
+		all[username] = failures
+	}
+	b, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(f.failuresPath(), b)
+}
@@ -0,0 +1,61 @@
+package kitwalk
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileSessionStore(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "kitwalk")
+	check(t, err)
+	defer os.RemoveAll(dir)
+	store := &FileSessionStore{Path: filepath.Join(dir, "sub", sessionFileName)}
+
+	t.Run("Load without session", func(t *testing.T) {
+		_, err := store.Load()
+		switch e := err.(type) {
+		case *SessionNotFoundError:
+			// Expected
+		default:
+			t.Errorf("Expected: SessionNotFoundError\nActual: %+v\n", e)
+		}
+	})
+	t.Run("Save, load and restore session", func(t *testing.T) {
+		jar, err := cookiejar.New(nil)
+		check(t, err)
+		portal, _ := url.Parse(ShibbolethLoginURL)
+		jar.SetCookies(portal, []*http.Cookie{{Name: "_shibsession_test", Value: "value"}})
+		check(t, store.Save(CaptureSession(validUsername, jar)))
+
+		info, err := os.Stat(store.Path)
+		check(t, err)
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("Expect: 0600\nActual: %o\n", info.Mode().Perm())
+		}
+		session, err := store.Load()
+		check(t, err)
+		if session.Username != validUsername || len(session.Cookies) != 1 {
+			t.Fatalf("Unexpected session %+v", session)
+		}
+		restored, err := cookiejar.New(nil)
+		check(t, err)
+		session.Restore(restored)
+		cookies := restored.Cookies(portal)
+		if len(cookies) != 1 || cookies[0].Value != "value" {
+			t.Errorf("Unexpected cookies %+v", cookies)
+		}
+	})
+	t.Run("Delete session", func(t *testing.T) {
+		check(t, store.Delete())
+		check(t, store.Delete())
+		if _, err := store.Load(); err == nil {
+			t.Error("Expect: SessionNotFoundError\nActual: (nil)")
+		}
+	})
+}
@@ -0,0 +1,224 @@
+package kitwalk
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+const (
+	// IdPSessionCookie is the cookie of the session of the auth server.
+	IdPSessionCookie = "shib_idp_session"
+	// SPSessionCookiePrefix is the prefix of the cookie of the session of Shibboleth SP.
+	SPSessionCookiePrefix = "_shibsession_"
+)
+
+var sessionNotOnOrAfterPattern = regexp.MustCompile(`SessionNotOnOrAfter="([^"]+)"`)
+
+// SessionState is the state of the login session.
+type SessionState int
+
+const (
+	// SessionValid means that the website accepts the session.
+	SessionValid SessionState = iota
+	// SessionIdPOnly means that the session of the website is gone, but the auth server still remembers the user.
+	// Login succeeds without a password.
+	SessionIdPOnly
+	// SessionExpired means that both sessions are gone, and a password is required to login.
+	SessionExpired
+)
+
+func (s SessionState) String() string {
+	switch s {
+	case SessionValid:
+		return "valid"
+	case SessionIdPOnly:
+		return "idp_only"
+	case SessionExpired:
+		return "expired"
+	}
+	return "unknown"
+}
+
+// SessionStatus is the result of CheckSession.
+type SessionStatus struct {
+	State     SessionState
+	CheckedAt time.Time
+	// ExpiresAt is the estimated time when the session of the website expires. It is zero when unknown.
+	ExpiresAt time.Time
+	// IdPExpiresAt is when the session of the auth server expires. It is zero when unknown.
+	IdPExpiresAt time.Time
+}
+
+// Valid reports whether the website accepts the session.
+func (s *SessionStatus) Valid() bool {
+	return s.State == SessionValid
+}
+
+// Remaining returns the estimated remaining lifetime of the session, or zero when it is unknown or expired.
+func (s *SessionStatus) Remaining() time.Duration {
+	if s.State != SessionValid || s.ExpiresAt.IsZero() {
+		return 0
+	}
+	if d := time.Until(s.ExpiresAt); d > 0 {
+		return d
+	}
+	return 0
+}
+
+// CheckSession reports whether the website accepts the session of given client.
+// The website is requested without following redirects, so a bounce to the auth server is detected
+// without logging in again. When it is bounced, the auth server is asked whether it still remembers the user.
+// The expiry is estimated from the cookies when the jar of the client is *Jar. The session cookies of Shibboleth
+// usually have no expiry, so ExpiresAt is zero then; use CheckSessionWithExpiry with the expiry known at login.
+func CheckSession(ctx context.Context, client *http.Client) (*SessionStatus, error) {
+	return CheckSessionWithExpiry(ctx, client, time.Time{})
+}
+
+// CheckSessionWithExpiry is CheckSession with the expiry of the session known at login,
+// such as SamlAuthenticator.SessionNotOnOrAfter or Session.ExpiresAt. ExpiresAt is the earlier of it
+// and the expiry of the cookies. It is ignored when zero.
+func CheckSessionWithExpiry(ctx context.Context, client *http.Client, expiresAt time.Time) (*SessionStatus, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	status := &SessionStatus{CheckedAt: time.Now()}
+	if jar, ok := client.Jar.(*Jar); ok {
+		status.ExpiresAt, status.IdPExpiresAt = cookieExpiry(jar)
+	}
+	if !expiresAt.IsZero() && (status.ExpiresAt.IsZero() || expiresAt.Before(status.ExpiresAt)) {
+		status.ExpiresAt = expiresAt
+	}
+	probe := *client
+	probe.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}
+	resp, err := probeRequest(ctx, &probe, http.MethodHead, ShibbolethLoginURL)
+	if err == nil && (resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented) {
+		drain(resp)
+		resp, err = probeRequest(ctx, &probe, http.MethodGet, ShibbolethLoginURL)
+	}
+	if err != nil {
+		return nil, err
+	}
+	drain(resp)
+	if !isBounced(resp) {
+		status.State = SessionValid
+		return status, nil
+	}
+	status.State = SessionExpired
+	loc, err := resp.Location()
+	if err != nil {
+		return status, nil
+	}
+	alive, err := idpAlive(ctx, client, loc.String())
+	if err != nil {
+		return nil, err
+	}
+	if alive {
+		status.State = SessionIdPOnly
+	}
+	return status, nil
+}
+
+func probeRequest(ctx context.Context, client *http.Client, method, rawurl string) (*http.Response, error) {
+	req, err := http.NewRequest(method, rawurl, nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req.WithContext(ctx))
+}
+
+// idpAlive follows the bounce to the auth server, and reports whether it answers with a SAML response
+// instead of the login form. The SAML response is not posted, so the session of the website is not created.
+func idpAlive(ctx context.Context, client *http.Client, location string) (bool, error) {
+	follow := *client
+	follow.CheckRedirect = nil
+	resp, err := probeRequest(ctx, &follow, http.MethodGet, location)
+	if err != nil {
+		return false, err
+	}
+	body, err := skipConfirmation(ctx, &follow, resp)
+	if err != nil {
+		return false, err
+	}
+	return hasSamlResp(body), nil
+}
+
+// skipConfirmation reads the page of the auth server. When it is the Web Storage confirmation page,
+// the confirmation is posted as auth does, and the next page is returned.
+func skipConfirmation(ctx context.Context, client *http.Client, resp *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	if !isContinueRequired(bytes.NewReader(body)) || hasSamlResp(body) {
+		return body, nil
+	}
+	param := strings.NewReader(GetDefaultConfig().ShibbolethPassConfirmationParams.Encode())
+	req, err := http.NewRequest(http.MethodPost, resp.Request.URL.String(), param)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add(contentTypeHead, contentTypeVal)
+	if resp, err = client.Do(req.WithContext(ctx)); err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+func hasSamlResp(body []byte) bool {
+	doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+	return doc.Find("form input[name=\""+DefaultSAMLResponseKey+"\"]").Length() != 0
+}
+
+// cookieExpiry returns the earliest expiry of the session cookies of the website and the auth server.
+// Session cookies without expiry are ignored.
+func cookieExpiry(jar *Jar) (sp, idp time.Time) {
+	for _, c := range jar.All() {
+		if c.Expires.IsZero() {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(c.Name, SPSessionCookiePrefix):
+			if sp.IsZero() || c.Expires.Before(sp) {
+				sp = c.Expires
+			}
+		case c.Name == IdPSessionCookie:
+			if idp.IsZero() || c.Expires.Before(idp) {
+				idp = c.Expires
+			}
+		}
+	}
+	return sp, idp
+}
+
+// sessionNotOnOrAfter extracts SessionNotOnOrAfter of AuthnStatement from the base64 encoded SAML response.
+// It returns the zero time when the assertion is encrypted or the attribute does not exist.
+func sessionNotOnOrAfter(samlResponse string) time.Time {
+	b, err := base64.StdEncoding.DecodeString(samlResponse)
+	if err != nil {
+		return time.Time{}
+	}
+	m := sessionNotOnOrAfterPattern.FindSubmatch(b)
+	if m == nil {
+		return time.Time{}
+	}
+	t, err := time.Parse(time.RFC3339, string(m[1]))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
@@ -0,0 +1,122 @@
+package kitwalk
+
+import (
+	"context"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+const idpSSOURL = "https://auth.cis.kit.ac.jp/idp/profile/SAML2/Redirect/SSO"
+
+// sessionMock is the website and the auth server which keep sessions independently.
+type sessionMock struct {
+	SPAlive  bool
+	IdPAlive bool
+	Methods  []string
+}
+
+func (s *sessionMock) RoundTrip(req *http.Request) (*http.Response, error) {
+	s.Methods = append(s.Methods, req.Method)
+	resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Request: req, Body: ioutil.NopCloser(strings.NewReader(""))}
+	switch {
+	case req.URL.Host != DefaultAuthDomain && req.Method == http.MethodPost:
+		// The SAML response creates the session of the website.
+		s.SPAlive = true
+		resp.Header.Add("Set-Cookie", SPSessionCookiePrefix+"test=sso; Path=/")
+	case req.URL.Host != DefaultAuthDomain && s.SPAlive:
+	case req.URL.Host != DefaultAuthDomain:
+		resp.StatusCode = http.StatusFound
+		resp.Header.Set("Location", idpSSOURL+"?SAMLRequest=request")
+	case s.IdPAlive:
+		resp.Body = ioutil.NopCloser(strings.NewReader(`<form action="https://portal.student.kit.ac.jp/Shibboleth.sso/SAML2/POST" method="post">` +
+			`<input type="hidden" name="RelayState" value="state"/><input type="hidden" name="SAMLResponse" value="response"/></form>`))
+	default:
+		body, err := ioutil.ReadFile("./samples/auth_form.html")
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+	}
+	return resp, nil
+}
+
+func TestCheckSession(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		name   string
+		mock   *sessionMock
+		expect SessionState
+	}{
+		{"Valid session", &sessionMock{SPAlive: true, IdPAlive: true}, SessionValid},
+		{"Session only on the auth server", &sessionMock{IdPAlive: true}, SessionIdPOnly},
+		{"Expired session", &sessionMock{}, SessionExpired},
+	}
+	for _, c := range cases {
+		c := c
+		t.Run(c.name, func(t *testing.T) {
+			t.Parallel()
+			status, err := CheckSession(context.Background(), &http.Client{Transport: c.mock})
+			check(t, err)
+			if status.State != c.expect {
+				t.Errorf("Expect: %v\nActual: %v\n", c.expect, status.State)
+			}
+			if c.mock.Methods[0] != http.MethodHead {
+				t.Errorf("Expect: %s\nActual: %s\n", http.MethodHead, c.mock.Methods[0])
+			}
+		})
+	}
+	t.Run("Estimate expiry from cookies", func(t *testing.T) {
+		t.Parallel()
+		jar := NewJar()
+		portal := mustParse(t, ShibbolethLoginURL)
+		jar.SetCookies(portal, []*http.Cookie{{Name: SPSessionCookiePrefix + "test", Value: "sp", Path: "/", MaxAge: 3600}})
+		jar.SetCookies(mustParse(t, idpSSOURL), []*http.Cookie{{Name: IdPSessionCookie, Value: "idp", Path: "/idp", MaxAge: 7200}})
+		status, err := CheckSession(context.Background(), &http.Client{Jar: jar, Transport: &sessionMock{SPAlive: true}})
+		check(t, err)
+		if remaining := status.Remaining(); remaining < 59*time.Minute || remaining > time.Hour {
+			t.Errorf("Expect: about 1h\nActual: %v\n", remaining)
+		}
+		if d := time.Until(status.IdPExpiresAt); d < 119*time.Minute || d > 2*time.Hour {
+			t.Errorf("Expect: about 2h\nActual: %v\n", d)
+		}
+	})
+	t.Run("Use the known expiry", func(t *testing.T) {
+		t.Parallel()
+		jar := NewJar()
+		jar.SetCookies(mustParse(t, ShibbolethLoginURL), []*http.Cookie{{Name: SPSessionCookiePrefix + "test", Value: "sp", Path: "/"}})
+		client := &http.Client{Jar: jar, Transport: &sessionMock{SPAlive: true}}
+		status, err := CheckSession(context.Background(), client)
+		check(t, err)
+		if !status.ExpiresAt.IsZero() {
+			t.Errorf("Expect: unknown expiry\nActual: %v\n", status.ExpiresAt)
+		}
+		expiresAt := time.Now().Add(30 * time.Minute)
+		status, err = CheckSessionWithExpiry(context.Background(), client, expiresAt)
+		check(t, err)
+		if !status.ExpiresAt.Equal(expiresAt) {
+			t.Errorf("Expect: %v\nActual: %v\n", expiresAt, status.ExpiresAt)
+		}
+	})
+}
+
+func TestSessionNotOnOrAfter(t *testing.T) {
+	t.Parallel()
+	assertion := `<saml2p:Response><saml2:Assertion><saml2:AuthnStatement AuthnInstant="2020-04-01T00:00:00.000Z" ` +
+		`SessionIndex="_index" SessionNotOnOrAfter="2020-04-01T08:00:00.000Z"></saml2:AuthnStatement></saml2:Assertion></saml2p:Response>`
+	actual := sessionNotOnOrAfter(base64.StdEncoding.EncodeToString([]byte(assertion)))
+	expect := time.Date(2020, 4, 1, 8, 0, 0, 0, time.UTC)
+	if !actual.Equal(expect) {
+		t.Errorf("Expect: %v\nActual: %v\n", expect, actual)
+	}
+	if actual := sessionNotOnOrAfter(base64.StdEncoding.EncodeToString([]byte("<EncryptedAssertion/>"))); !actual.IsZero() {
+		t.Errorf("Expect: zero time\nActual: %v\n", actual)
+	}
+	session := &Session{LoggedInAt: expect.Add(-time.Hour), NotOnOrAfter: expect}
+	if !session.ExpiresAt().Equal(expect) {
+		t.Errorf("Expect: %v\nActual: %v\n", expect, session.ExpiresAt())
+	}
+}
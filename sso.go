@@ -0,0 +1,77 @@
+package kitwalk
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+)
+
+// SingleSignOn opens the website protected with the same auth server, such as Moodle, with the session of the auth server
+// in the jar of the client. The SAML response issued by the auth server is posted to the website as a browser does,
+// and the jar receives the session of the website.
+// SessionExpiredError is returned when the auth server asks the password. Login with Auth first in that case.
+func SingleSignOn(ctx context.Context, client *http.Client, target string) error {
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	if resp.Request.URL.Host != DefaultAuthDomain {
+		// The website has the session already.
+		drain(resp)
+		return nil
+	}
+	body, err := skipConfirmation(ctx, client, resp)
+	if err != nil {
+		return err
+	}
+	if !hasSamlResp(body) {
+		return &SessionExpiredError{URL: target}
+	}
+	actionURL, data, err := parseSamlResp(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	postReq, err := http.NewRequest(http.MethodPost, actionURL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
+	}
+	postReq.Header.Add(contentTypeHead, contentTypeVal)
+	result, err := client.Do(postReq.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer drain(result)
+	if result.Request.URL.Host == DefaultAuthDomain {
+		return &ShibbolethAuthError{errMsg: "Posted the SAML response, but returned to the auth server."}
+	}
+	return nil
+}
+
+// SignOnWith signs on to the target like SingleSignOn. When the auth server has no session, it logs in with auth
+// and tries once more. The login is canceled with ctx. auth may be nil to only use the session in the jar.
+func SignOnWith(ctx context.Context, client *http.Client, auth Auth, target string) error {
+	err := SingleSignOn(ctx, client, target)
+	if _, ok := err.(*SessionExpiredError); ok && auth != nil {
+		if err = auth.LoginWith(WithContext(ctx, client)); err == nil {
+			err = SingleSignOn(ctx, client, target)
+		}
+	}
+	return err
+}
+
+// SignOnClient returns the client to sign on to other websites with. It creates one when client is nil,
+// and sets a Jar when it has none, since the sessions of the auth server and the website are kept in the jar.
+func SignOnClient(client *http.Client) *http.Client {
+	if client == nil {
+		client = &http.Client{}
+	}
+	if client.Jar == nil {
+		client.Jar = NewJar()
+	}
+	return client
+}
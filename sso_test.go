@@ -0,0 +1,111 @@
+package kitwalk
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+const moodleURL = "https://moodle.cis.kit.ac.jp/auth/shibboleth/index.php"
+
+// ssoMock is a website which creates its session from the SAML response, and the auth server.
+type ssoMock struct {
+	IdPAlive bool
+	Posted   int
+}
+
+func (s *ssoMock) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header), Request: req, Body: ioutil.NopCloser(strings.NewReader(""))}
+	switch {
+	case req.URL.Host == DefaultAuthDomain && s.IdPAlive:
+		resp.Body = ioutil.NopCloser(strings.NewReader(`<form action="https://moodle.cis.kit.ac.jp/Shibboleth.sso/SAML2/POST" method="post">` +
+			`<input type="hidden" name="RelayState" value="state"/><input type="hidden" name="SAMLResponse" value="response"/></form>`))
+	case req.URL.Host == DefaultAuthDomain:
+		body, err := ioutil.ReadFile("./samples/auth_form.html")
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = ioutil.NopCloser(strings.NewReader(string(body)))
+	case req.Method == http.MethodPost:
+		s.Posted++
+		req.ParseForm()
+		if req.PostForm.Get(DefaultSAMLResponseKey) != "response" {
+			resp.StatusCode = http.StatusBadRequest
+			break
+		}
+		resp.StatusCode = http.StatusFound
+		resp.Header.Set("Location", moodleURL)
+		resp.Header.Add("Set-Cookie", SPSessionCookiePrefix+"moodle=sp; Path=/")
+	default:
+		if _, err := req.Cookie(SPSessionCookiePrefix + "moodle"); err != nil {
+			resp.StatusCode = http.StatusFound
+			resp.Header.Set("Location", idpSSOURL+"?SAMLRequest=request")
+		}
+	}
+	return resp, nil
+}
+
+func TestSingleSignOn(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	t.Run("Sign on with the session of the auth server", func(t *testing.T) {
+		mock := &ssoMock{IdPAlive: true}
+		client := &http.Client{Jar: NewJar(), Transport: mock}
+		check(t, SingleSignOn(ctx, client, moodleURL))
+		if _, ok := client.Jar.(*Jar).Lookup(SPSessionCookiePrefix+"moodle", "moodle.cis.kit.ac.jp"); !ok {
+			t.Error("Expect: session of the website in the jar")
+		}
+		// The website has the session now.
+		check(t, SingleSignOn(ctx, client, moodleURL))
+		if mock.Posted != 1 {
+			t.Errorf("Expect: SAML response posted once\nActual: %d times\n", mock.Posted)
+		}
+	})
+	t.Run("Fail without the session of the auth server", func(t *testing.T) {
+		client := &http.Client{Jar: NewJar(), Transport: &ssoMock{}}
+		err := SingleSignOn(ctx, client, moodleURL)
+		switch err.(type) {
+		case *SessionExpiredError:
+		default:
+			t.Errorf("Expect: SessionExpiredError\nActual: %v\n", err)
+		}
+	})
+}
+
+// ssoAuth logs in to the auth server of ssoMock.
+type ssoAuth struct {
+	mock   *ssoMock
+	logins int
+}
+
+func (a *ssoAuth) LoginWith(client *http.Client) error {
+	a.logins++
+	a.mock.IdPAlive = true
+	return nil
+}
+
+func (a *ssoAuth) SetupWith(config Config) error                  { return nil }
+func (a *ssoAuth) LoginAs(username string, password string) error { return nil }
+
+func TestSignOnWith(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	t.Run("Login when the auth server has no session", func(t *testing.T) {
+		mock := &ssoMock{}
+		auth := &ssoAuth{mock: mock}
+		client := SignOnClient(&http.Client{Transport: mock})
+		check(t, SignOnWith(ctx, client, auth, moodleURL))
+		check(t, SignOnWith(ctx, client, auth, moodleURL))
+		if auth.logins != 1 || mock.Posted != 1 {
+			t.Errorf("Expect: login and post once\nActual: %d logins, %d posts\n", auth.logins, mock.Posted)
+		}
+	})
+	t.Run("Fail without auth", func(t *testing.T) {
+		client := SignOnClient(&http.Client{Transport: &ssoMock{}})
+		if _, ok := SignOnWith(ctx, client, nil, moodleURL).(*SessionExpiredError); !ok {
+			t.Error("Expect: SessionExpiredError")
+		}
+	})
+}
@@ -0,0 +1,116 @@
+package kitwalk
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// DegreeCourse is the course a student belongs to. It decides the prefix of the username.
+type DegreeCourse int
+
+const (
+	// Bachelor is an undergraduate student. The username starts with 'b'.
+	Bachelor DegreeCourse = iota + 1
+	// Master is a student in the master's course. The username starts with 'm'.
+	Master
+	// Doctor is a student in the doctoral course. The username starts with 'd'.
+	Doctor
+)
+
+var (
+	studentNumberRegex = regexp.MustCompile("^\\d{8}$")
+	degreeCoursePrefix = map[DegreeCourse]string{
+		Bachelor: "b",
+		Master:   "m",
+		Doctor:   "d",
+	}
+)
+
+// Prefix returns the character put in front of the student number to make the username.
+func (d DegreeCourse) Prefix() string {
+	return degreeCoursePrefix[d]
+}
+
+func (d DegreeCourse) String() string {
+	switch d {
+	case Bachelor:
+		return "bachelor"
+	case Master:
+		return "master"
+	case Doctor:
+		return "doctor"
+	}
+	return fmt.Sprintf("DegreeCourse(%d)", int(d))
+}
+
+func degreeCourseOf(prefix byte) (DegreeCourse, bool) {
+	for course, p := range degreeCoursePrefix {
+		if p[0] == prefix {
+			return course, true
+		}
+	}
+	return 0, false
+}
+
+// StudentID is a parsed form of the username.
+// A student number consists of the last two digits of the enrollment year and a serial of six digits,
+// and the username is a prefix of the degree course with the student number excluding the first digit.
+type StudentID struct {
+	Course DegreeCourse
+	// EnrollmentYear is the year in four digits.
+	EnrollmentYear int
+	// Serial is the rest of the student number after the enrollment year.
+	Serial string
+}
+
+// ParseStudentID parses given username such as 'b1234567'.
+// The username has only the last digit of the enrollment year,
+// so the year is resolved to the latest one which is not in the future.
+func ParseStudentID(username string) (*StudentID, error) {
+	return ParseStudentIDAt(username, time.Now())
+}
+
+// ParseStudentIDAt works as well as ParseStudentID, but the enrollment year is resolved relative to given time.
+func ParseStudentIDAt(username string, at time.Time) (*StudentID, error) {
+	if !usernameRegex.MatchString(username) {
+		return nil, &InvalidUsernameError{username: username}
+	}
+	course, _ := degreeCourseOf(username[0])
+	digit, _ := strconv.Atoi(username[1:2])
+	year := at.Year() - (at.Year()%10-digit+10)%10
+	return &StudentID{
+		Course:         course,
+		EnrollmentYear: year,
+		Serial:         username[2:],
+	}, nil
+}
+
+// ParseStudentNumber parses the full student number such as '18123456'.
+// The enrollment year is taken as a year in 2000s.
+func ParseStudentNumber(course DegreeCourse, number string) (*StudentID, error) {
+	if !studentNumberRegex.MatchString(number) || course.Prefix() == "" {
+		return nil, &InvalidStudentNumberError{number: number}
+	}
+	year, _ := strconv.Atoi(number[:2])
+	return &StudentID{
+		Course:         course,
+		EnrollmentYear: 2000 + year,
+		Serial:         number[2:],
+	}, nil
+}
+
+// StudentNumber returns the full student number.
+func (s *StudentID) StudentNumber() string {
+	return fmt.Sprintf("%02d%s", s.EnrollmentYear%100, s.Serial)
+}
+
+// Username returns the form used to login.
+func (s *StudentID) Username() string {
+	return s.Course.Prefix() + s.StudentNumber()[1:]
+}
+
+func (s *StudentID) String() string {
+	return s.Username()
+}
@@ -0,0 +1,78 @@
+package kitwalk
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseStudentIDAt(t *testing.T) {
+	t.Parallel()
+	at := time.Date(2019, time.May, 1, 0, 0, 0, 0, time.UTC)
+	t.Run("Parse username of each course", func(t *testing.T) {
+		cases := map[string]DegreeCourse{
+			"b8123456": Bachelor,
+			"m9123456": Master,
+			"d7123456": Doctor,
+		}
+		for uName, course := range cases {
+			sid, err := ParseStudentIDAt(uName, at)
+			check(t, err)
+			if sid.Course != course {
+				t.Errorf("Expect: %+v\nActual: %+v\n", course, sid.Course)
+			}
+			if sid.Serial != "123456" {
+				t.Errorf("Expect: 123456\nActual: %+v\n", sid.Serial)
+			}
+			if sid.Username() != uName {
+				t.Errorf("Expect: %+v\nActual: %+v\n", uName, sid.Username())
+			}
+		}
+	})
+	t.Run("Resolve enrollment year", func(t *testing.T) {
+		cases := map[string]int{
+			"b9123456": 2019,
+			"b8123456": 2018,
+			"b0123456": 2010,
+		}
+		for uName, year := range cases {
+			sid, err := ParseStudentIDAt(uName, at)
+			check(t, err)
+			if sid.EnrollmentYear != year {
+				t.Errorf("Expect: %+v\nActual: %+v\n", year, sid.EnrollmentYear)
+			}
+		}
+	})
+	t.Run("Parse invalid username", func(t *testing.T) {
+		_, err := ParseStudentIDAt("x8123456", at)
+		switch e := err.(type) {
+		case *InvalidUsernameError:
+			// Expected
+		default:
+			t.Errorf("Expected: InvalidUsernameError\nActual: %+v\n", e)
+		}
+	})
+}
+
+func TestParseStudentNumber(t *testing.T) {
+	t.Parallel()
+	t.Run("Convert student number to username", func(t *testing.T) {
+		sid, err := ParseStudentNumber(Master, "18123456")
+		check(t, err)
+		if sid.EnrollmentYear != 2018 {
+			t.Errorf("Expect: 2018\nActual: %+v\n", sid.EnrollmentYear)
+		}
+		if sid.Username() != "m8123456" {
+			t.Errorf("Expect: m8123456\nActual: %+v\n", sid.Username())
+		}
+		if sid.StudentNumber() != "18123456" {
+			t.Errorf("Expect: 18123456\nActual: %+v\n", sid.StudentNumber())
+		}
+	})
+	t.Run("Parse invalid student number", func(t *testing.T) {
+		for _, number := range []string{"1812345", "181234567", "1812345a"} {
+			if _, err := ParseStudentNumber(Bachelor, number); err == nil {
+				t.Errorf("It recognized '%s' as valid", number)
+			}
+		}
+	})
+}
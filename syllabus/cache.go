@@ -0,0 +1,102 @@
+package syllabus
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// Cache keeps the pages fetched from the syllabus site. The pages are stored in UTF-8.
+type Cache interface {
+	// Get returns the page and the time it was stored. It returns false when the page is not cached.
+	Get(key string) ([]byte, time.Time, bool)
+	Put(key string, page []byte) error
+}
+
+// FileCache stores the pages as files in Dir. It is shared by processes such as course planners run by cron.
+type FileCache struct {
+	Dir string
+}
+
+// NewFileCache create new cache storing files into given directory.
+func NewFileCache(dir string) *FileCache {
+	return &FileCache{Dir: dir}
+}
+
+func (c *FileCache) path(key string) string {
+	h := sha1.Sum([]byte(key))
+	return filepath.Join(c.Dir, hex.EncodeToString(h[:])+".html")
+}
+
+// Get reads the page from the file. The modification time of the file is the time it was stored.
+func (c *FileCache) Get(key string) ([]byte, time.Time, bool) {
+	name := c.path(key)
+	info, err := os.Stat(name)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+	return b, info.ModTime(), true
+}
+
+// Put writes the page into the file atomically.
+func (c *FileCache) Put(key string, page []byte) error {
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(c.Dir, "page")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(page); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), c.path(key))
+}
+
+// MemoryCache keeps the pages in memory. The zero value is an empty cache.
+type MemoryCache struct {
+	mu    sync.Mutex
+	pages map[string]cachedPage
+}
+
+type cachedPage struct {
+	page     []byte
+	storedAt time.Time
+}
+
+// NewMemoryCache create new empty cache in memory.
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{pages: make(map[string]cachedPage)}
+}
+
+// Get returns the page in memory.
+func (c *MemoryCache) Get(key string) ([]byte, time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	p, ok := c.pages[key]
+	return p.page, p.storedAt, ok
+}
+
+// Put keeps the page in memory.
+func (c *MemoryCache) Put(key string, page []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.pages == nil {
+		c.pages = make(map[string]cachedPage)
+	}
+	c.pages[key] = cachedPage{page: page, storedAt: time.Now()}
+	return nil
+}
@@ -0,0 +1,113 @@
+// Package syllabus searches the syllabus site behind the Shibboleth authentication, and parses the entries.
+// The site is encoded in Shift_JIS, and the pages are converted into UTF-8 before parsing and caching.
+package syllabus
+
+import (
+	"bytes"
+	"context"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/StudioAquatan/kitwalk"
+	"golang.org/x/net/html/charset"
+)
+
+const (
+	// DefaultBaseURL is the url of the syllabus site.
+	DefaultBaseURL = "https://www.syllabus.kit.ac.jp/"
+	// DefaultTTL is the duration to use the cached pages.
+	DefaultTTL = 24 * time.Hour
+)
+
+// Client fetches pages of the syllabus site with the logged-in http.Client.
+type Client struct {
+	HTTPClient *http.Client
+	BaseURL    *url.URL
+	// Layout locates the parts of the pages. It has no defaults; see Layout.
+	Layout Layout
+	// Cache keeps the fetched pages for TTL. Pages are always fetched when it is nil.
+	Cache Cache
+	TTL   time.Duration
+}
+
+// NewClient create new syllabus client with given http.Client.
+// The client should be logged in with kitwalk.Auth beforehand.
+func NewClient(client *http.Client) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	base, _ := url.Parse(DefaultBaseURL)
+	return &Client{
+		HTTPClient: client,
+		BaseURL:    base,
+		TTL:        DefaultTTL,
+	}
+}
+
+// page returns the page in UTF-8 from the cache or the site, and the url of the page to resolve links.
+func (c *Client) page(ctx context.Context, ref string) ([]byte, *url.URL, error) {
+	u, err := c.BaseURL.Parse(ref)
+	if err != nil {
+		return nil, nil, err
+	}
+	key := u.String()
+	if c.Cache != nil {
+		ttl := c.TTL
+		if ttl <= 0 {
+			ttl = DefaultTTL
+		}
+		if page, storedAt, ok := c.Cache.Get(key); ok && time.Since(storedAt) < ttl {
+			return page, u, nil
+		}
+	}
+
+	req, err := http.NewRequest(http.MethodGet, key, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req = req.WithContext(ctx)
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.Request.URL.Host == kitwalk.DefaultAuthDomain {
+		return nil, nil, &kitwalk.SessionExpiredError{URL: key}
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, &StatusError{URL: key, StatusCode: resp.StatusCode}
+	}
+	page, err := decode(resp)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.Cache != nil {
+		// A failure of the cache only makes the next call slower.
+		c.Cache.Put(key, page)
+	}
+	return page, u, nil
+}
+
+// decode converts the body into UTF-8. The encoding is taken from the header, <meta> or the content.
+func decode(resp *http.Response) ([]byte, error) {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if _, params, err := mime.ParseMediaType(contentType); err == nil &&
+		strings.EqualFold(params["charset"], "utf-8") && !utf8.Valid(body) {
+		// Some pages in Shift_JIS are served as UTF-8. Trust <meta> instead.
+		contentType = "text/html"
+	}
+	r, err := charset.NewReader(bytes.NewReader(body), contentType)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(r)
+}
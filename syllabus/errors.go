@@ -0,0 +1,31 @@
+package syllabus
+
+import "fmt"
+
+// StatusError will be return when the syllabus site responds with unexpected status code.
+type StatusError struct {
+	URL        string
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("Unexpected status code %d from '%s'.", e.StatusCode, e.URL)
+}
+
+// ParseError will be return when the page does not have expected structure.
+type ParseError struct {
+	errMsg string
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("Could not parse the syllabus. %s", e.errMsg)
+}
+
+// LayoutError will be return when a part of Client.Layout is needed but not set.
+type LayoutError struct {
+	Field string
+}
+
+func (e *LayoutError) Error() string {
+	return fmt.Sprintf("Client.Layout needs %s.", e.Field)
+}
@@ -0,0 +1,378 @@
+package syllabus
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/StudioAquatan/kitwalk/internal/text"
+	"golang.org/x/net/html"
+)
+
+// Layout locates the search results and the parts of the syllabus entries on the site.
+// kitwalk has no capture of the site, so it has no defaults; set it from the pages of the site.
+type Layout struct {
+	// SearchPath is the page of the search results relative to Client.BaseURL, such as "?c=search_list".
+	SearchPath string
+	// Params are the names of the query parameters of the search.
+	Params Params
+	// Results selects the table of the search results, and Next selects the link to the next page of them.
+	// Only the first page is fetched when Next is empty.
+	Results string
+	Next    string
+	// Detail selects the table of the labeled fields of an entry.
+	// Schedule and Evaluation select the tables of the classes and the evaluation. They may be empty.
+	Detail     string
+	Schedule   string
+	Evaluation string
+}
+
+// Params are the names of the query parameters for the fields of Query.
+// The fields without a name are not sent, and all fields are matched against the results again.
+type Params struct {
+	Keyword    string
+	Department string
+	Term       string
+	Instructor string
+	Year       string
+}
+
+// DefaultMaxPages is the number of pages of search results fetched at most when zero is given.
+const DefaultMaxPages = 20
+
+// Course is a course in the search results.
+type Course struct {
+	Year int    `json:"year"`
+	Code string `json:"code"`
+	// Title is the name of the course such as "線形代数学I".
+	Title      string `json:"title"`
+	Instructor string `json:"instructor"`
+	Term       string `json:"term"`
+	// Department is the program offering the course such as "情報工学課程".
+	Department string  `json:"department"`
+	Credits    float64 `json:"credits"`
+	// URL is the url of the syllabus entry.
+	URL string `json:"url"`
+}
+
+// Entry is the whole syllabus of a course.
+type Entry struct {
+	Course
+	Objectives    string      `json:"objectives"`
+	Overview      string      `json:"overview"`
+	Prerequisites string      `json:"prerequisites"`
+	Textbooks     string      `json:"textbooks"`
+	Schedule      []Session   `json:"schedule"`
+	Evaluation    []Criterion `json:"evaluation"`
+}
+
+// Session is a class in the schedule.
+type Session struct {
+	Number  int    `json:"number"`
+	Theme   string `json:"theme"`
+	Content string `json:"content"`
+}
+
+// Criterion is a method of the evaluation and its weight in percent.
+type Criterion struct {
+	Method string `json:"method"`
+	Weight int    `json:"weight"`
+}
+
+// Query is the conditions of the search. Empty fields match all courses.
+type Query struct {
+	Keyword    string
+	Department string
+	Term       string
+	Instructor string
+	// Year is the academic year. The current year of the site is used when it is zero.
+	Year int
+	// MaxPages caps the number of pages to fetch.
+	MaxPages int
+}
+
+func (q *Query) values(p Params) url.Values {
+	v := url.Values{}
+	year := ""
+	if q.Year != 0 {
+		year = strconv.Itoa(q.Year)
+	}
+	for name, value := range map[string]string{
+		p.Keyword:    q.Keyword,
+		p.Department: q.Department,
+		p.Term:       q.Term,
+		p.Instructor: q.Instructor,
+		p.Year:       year,
+	} {
+		if name != "" && value != "" {
+			v.Set(name, value)
+		}
+	}
+	return v
+}
+
+// Match reports whether the course satisfies the query except Keyword, which is matched against the whole syllabus by the site.
+func (q *Query) Match(c Course) bool {
+	switch {
+	case q.Department != "" && !strings.Contains(c.Department, q.Department):
+		return false
+	case q.Term != "" && !strings.Contains(c.Term, q.Term):
+		return false
+	case q.Instructor != "" && !strings.Contains(text.Normalize(c.Instructor), text.Normalize(q.Instructor)):
+		return false
+	case q.Year != 0 && c.Year != 0 && c.Year != q.Year:
+		return false
+	}
+	return true
+}
+
+// Search returns the courses matching the query on all pages of the results.
+// It needs Layout.SearchPath and Layout.Results.
+func (c *Client) Search(ctx context.Context, q Query) ([]Course, error) {
+	if c.Layout.SearchPath == "" || c.Layout.Results == "" {
+		return nil, &LayoutError{Field: "SearchPath and Results"}
+	}
+	ref, err := url.Parse(c.Layout.SearchPath)
+	if err != nil {
+		return nil, err
+	}
+	values := ref.Query()
+	for name, v := range q.values(c.Layout.Params) {
+		values[name] = v
+	}
+	ref.RawQuery = values.Encode()
+	maxPages := q.MaxPages
+	if maxPages <= 0 {
+		maxPages = DefaultMaxPages
+	}
+
+	var (
+		courses []Course
+		seen    = make(map[string]bool)
+		visited = make(map[string]bool)
+		next    = ref.String()
+	)
+	for page := 0; page < maxPages && next != ""; page++ {
+		body, base, err := c.page(ctx, next)
+		if err != nil {
+			return nil, err
+		}
+		visited[base.String()] = true
+		doc, err := goquery.NewDocumentFromReader(bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		found, err := parseSearch(doc, base, c.Layout.Results)
+		if err != nil {
+			return nil, err
+		}
+		for _, course := range found {
+			key := courseKey(course)
+			if key != "" && seen[key] {
+				continue
+			}
+			if q.Match(course) {
+				courses = append(courses, course)
+			}
+			if key != "" {
+				seen[key] = true
+			}
+		}
+		next = ""
+		if c.Layout.Next == "" {
+			break
+		}
+		if href, ok := doc.Find(c.Layout.Next).First().Attr("href"); ok {
+			if u, err := base.Parse(href); err == nil && !visited[u.String()] {
+				next = u.String()
+			}
+		}
+	}
+	return courses, nil
+}
+
+// courseKey identifies the course in the results by its url, or by its year and code without url.
+// It is empty when the course has neither, and such courses are not deduplicated.
+func courseKey(c Course) string {
+	if c.URL != "" {
+		return "url:" + c.URL
+	}
+	if c.Code != "" {
+		return "code:" + strconv.Itoa(c.Year) + ":" + c.Code
+	}
+	return ""
+}
+
+// Entry fetches the syllabus of the course. It needs Layout.Detail.
+func (c *Client) Entry(ctx context.Context, course Course) (*Entry, error) {
+	if c.Layout.Detail == "" {
+		return nil, &LayoutError{Field: "Detail"}
+	}
+	if course.URL == "" {
+		return nil, &ParseError{errMsg: "The course '" + course.Title + "' has no url of the syllabus."}
+	}
+	body, base, err := c.page(ctx, course.URL)
+	if err != nil {
+		return nil, err
+	}
+	return ParseEntry(bytes.NewReader(body), base, &c.Layout)
+}
+
+// courseFields maps the labels in the site to the setters of the fields of Course.
+var courseFields = map[string]func(c *Course, s string){
+	"年度":     func(c *Course, s string) { c.Year, _ = strconv.Atoi(s) },
+	"時間割コード": func(c *Course, s string) { c.Code = s },
+	"授業科目名":  func(c *Course, s string) { c.Title = s },
+	"担当教員":   func(c *Course, s string) { c.Instructor = s },
+	"開講学期":   func(c *Course, s string) { c.Term = s },
+	"開講課程":   func(c *Course, s string) { c.Department = s },
+	"単位数":    func(c *Course, s string) { c.Credits, _ = strconv.ParseFloat(s, 64) },
+}
+
+// ParseSearch parses the table of the search results selected by table. Columns are identified by their headers,
+// and relative links are resolved with given base url.
+func ParseSearch(body io.Reader, base *url.URL, table string) ([]Course, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+	return parseSearch(doc, base, table)
+}
+
+func parseSearch(doc *goquery.Document, base *url.URL, selector string) ([]Course, error) {
+	table := doc.Find(selector).First()
+	if table.Length() == 0 {
+		return nil, &ParseError{errMsg: "Search results are not found."}
+	}
+	if base == nil {
+		base = &url.URL{}
+	}
+	rows := table.Find("tr")
+	var setters []func(c *Course, s string)
+	rows.First().Find("th").Each(func(_ int, s *goquery.Selection) {
+		setters = append(setters, courseFields[text.Normalize(s.Text())])
+	})
+	var courses []Course
+	rows.Slice(1, rows.Length()).Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find("td")
+		if cells.Length() == 0 {
+			return
+		}
+		var c Course
+		cells.Each(func(i int, cell *goquery.Selection) {
+			if i < len(setters) && setters[i] != nil {
+				setters[i](&c, text.CollapseSpaces(cell.Text()))
+			}
+		})
+		if href, ok := row.Find("a[href]").First().Attr("href"); ok {
+			if u, err := base.Parse(strings.TrimSpace(href)); err == nil {
+				c.URL = u.String()
+			}
+		}
+		courses = append(courses, c)
+	})
+	return courses, nil
+}
+
+// entryFields maps the labels of the syllabus to the text fields of Entry. A label containing the key matches.
+var entryFields = []struct {
+	label string
+	field func(e *Entry) *string
+}{
+	{"目標", func(e *Entry) *string { return &e.Objectives }},
+	{"概要", func(e *Entry) *string { return &e.Overview }},
+	{"履修条件", func(e *Entry) *string { return &e.Prerequisites }},
+	{"前提", func(e *Entry) *string { return &e.Prerequisites }},
+	{"教科書", func(e *Entry) *string { return &e.Textbooks }},
+}
+
+// ParseEntry parses the page of a syllabus entry with the tables selected by the layout.
+// The page should be decoded into UTF-8.
+func ParseEntry(body io.Reader, base *url.URL, l *Layout) (*Entry, error) {
+	doc, err := goquery.NewDocumentFromReader(body)
+	if err != nil {
+		return nil, err
+	}
+	detail := doc.Find(l.Detail).First()
+	if detail.Length() == 0 {
+		return nil, &ParseError{errMsg: "Syllabus entry is not found."}
+	}
+	e := &Entry{}
+	detail.Find("tr").Each(func(_ int, row *goquery.Selection) {
+		label := text.Normalize(row.Find("th").First().Text())
+		value := row.Find("td").First()
+		if set, ok := courseFields[label]; ok {
+			set(&e.Course, text.CollapseSpaces(value.Text()))
+			return
+		}
+		for _, f := range entryFields {
+			if strings.Contains(label, f.label) {
+				*f.field(e) = plainText(value)
+				return
+			}
+		}
+	})
+	find(doc, l.Schedule).Find("tr").Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find("td")
+		if cells.Length() < 2 {
+			return
+		}
+		number, _ := strconv.Atoi(text.CollapseSpaces(cells.Eq(0).Text()))
+		e.Schedule = append(e.Schedule, Session{
+			Number:  number,
+			Theme:   text.CollapseSpaces(cells.Eq(1).Text()),
+			Content: plainText(cells.Eq(2)),
+		})
+	})
+	find(doc, l.Evaluation).Find("tr").Each(func(_ int, row *goquery.Selection) {
+		cells := row.Find("td")
+		if cells.Length() < 2 {
+			return
+		}
+		weight, _ := strconv.Atoi(strings.TrimRight(text.CollapseSpaces(cells.Eq(1).Text()), "%％ "))
+		e.Evaluation = append(e.Evaluation, Criterion{Method: text.CollapseSpaces(cells.Eq(0).Text()), Weight: weight})
+	})
+	if base != nil {
+		e.URL = base.String()
+	}
+	return e, nil
+}
+
+// find selects nothing for an empty selector.
+func find(doc *goquery.Document, selector string) *goquery.Selection {
+	if selector == "" {
+		return doc.Selection.Slice(0, 0)
+	}
+	return doc.Find(selector)
+}
+
+// plainText returns the text in the selection. <br> is converted into a line break.
+func plainText(s *goquery.Selection) string {
+	var b strings.Builder
+	var walk func(*html.Node)
+	walk = func(node *html.Node) {
+		switch {
+		case node.Type == html.TextNode:
+			b.WriteString(node.Data)
+		case node.Type == html.ElementNode && node.Data == "br":
+			b.WriteString("\n")
+		}
+		for c := node.FirstChild; c != nil; c = c.NextSibling {
+			walk(c)
+		}
+	}
+	for _, node := range s.Nodes {
+		walk(node)
+	}
+	var lines []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if line = text.CollapseSpaces(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,201 @@
+package syllabus
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func check(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// testLayout locates the parts of the synthetic samples.
+var testLayout = Layout{
+	SearchPath: "?c=search_list",
+	Params:     Params{Keyword: "keyword", Department: "department", Term: "term", Instructor: "instructor", Year: "year"},
+	Results:    "table.syllabus_list",
+	Next:       "a.next[href]",
+	Detail:     "table.syllabus_detail",
+	Schedule:   "table.syllabus_schedule",
+	Evaluation: "table.syllabus_evaluation",
+}
+
+// syllabusServer serves the samples in Shift_JIS, and counts the requests.
+type syllabusServer struct {
+	*httptest.Server
+	mu       sync.Mutex
+	requests int
+	queries  []url.Values
+}
+
+func newSyllabusServer(t *testing.T) *syllabusServer {
+	s := &syllabusServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		s.requests++
+		s.queries = append(s.queries, r.URL.Query())
+		s.mu.Unlock()
+		q := r.URL.Query()
+		var sample string
+		switch {
+		case q.Get("c") == "detail" && q.Get("code") == "10110101":
+			sample = "../samples/syllabus_entry.html"
+		case q.Get("c") == "search_list" && q.Get("page") == "2":
+			sample = "../samples/syllabus_search2.html"
+		case q.Get("c") == "search_list":
+			sample = "../samples/syllabus_search.html"
+			// The other pages declare the encoding only in <meta>.
+			w.Header().Set("Content-Type", "text/html; charset=Shift_JIS")
+		default:
+			http.NotFound(w, r)
+			return
+		}
+		b, err := ioutil.ReadFile(sample)
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		w.Write(b)
+	}))
+	return s
+}
+
+func (s *syllabusServer) client() *Client {
+	client := NewClient(s.Server.Client())
+	client.BaseURL, _ = url.Parse(s.URL + "/")
+	client.Layout = testLayout
+	return client
+}
+
+func TestClient_Search(t *testing.T) {
+	t.Parallel()
+	ctx := context.Background()
+	t.Run("Fetch all pages", func(t *testing.T) {
+		server := newSyllabusServer(t)
+		defer server.Close()
+		courses, err := server.client().Search(ctx, Query{Keyword: "線形"})
+		check(t, err)
+		if len(courses) != 3 {
+			t.Fatalf("Expect: 3 courses\nActual: %+v\n", courses)
+		}
+		expect := Course{
+			Year: 2018, Code: "10110101", Title: "線形代数学I", Instructor: "山田 太郎", Term: "前期",
+			Department: "情報工学課程", Credits: 2, URL: server.URL + "/?c=detail&year=2018&code=10110101",
+		}
+		if courses[0] != expect {
+			t.Errorf("Expect: %+v\nActual: %+v\n", expect, courses[0])
+		}
+		if courses[2].Title != "線形システム論" {
+			t.Errorf("Expect: decoded title on the page without Content-Type\nActual: %q\n", courses[2].Title)
+		}
+		if server.queries[0].Get("keyword") != "線形" || server.queries[0].Get("c") != "search_list" {
+			t.Errorf("Unexpected query %v", server.queries[0])
+		}
+	})
+	t.Run("Filter courses", func(t *testing.T) {
+		server := newSyllabusServer(t)
+		defer server.Close()
+		courses, err := server.client().Search(ctx, Query{Department: "情報工学", Instructor: "山田太郎", Term: "前期"})
+		check(t, err)
+		if len(courses) != 1 || courses[0].Code != "10110101" {
+			t.Errorf("Unexpected courses %+v", courses)
+		}
+	})
+	t.Run("Require layout", func(t *testing.T) {
+		client := NewClient(nil)
+		if _, err := client.Search(ctx, Query{}); err == nil {
+			t.Error("Expect: LayoutError\nActual: (nil)")
+		} else if _, ok := err.(*LayoutError); !ok {
+			t.Errorf("Expect: LayoutError\nActual: %v\n", err)
+		}
+	})
+}
+
+func TestClient_Entry(t *testing.T) {
+	t.Parallel()
+	server := newSyllabusServer(t)
+	defer server.Close()
+	dir, err := ioutil.TempDir("", "syllabus")
+	check(t, err)
+	defer os.RemoveAll(dir)
+	client := server.client()
+	client.Cache = NewFileCache(dir)
+	ctx := context.Background()
+	course := Course{Title: "線形代数学I", URL: server.URL + "/?c=detail&year=2018&code=10110101"}
+
+	t.Run("Parse entry", func(t *testing.T) {
+		e, err := client.Entry(ctx, course)
+		check(t, err)
+		if e.Code != "10110101" || e.Title != "線形代数学I" || e.Year != 2018 || e.Credits != 2 {
+			t.Errorf("Unexpected course %+v", e.Course)
+		}
+		if e.Objectives != "行列と線形写像の基礎を理解する。\n連立一次方程式を解けるようになる。" {
+			t.Errorf("Unexpected objectives %q", e.Objectives)
+		}
+		if e.Prerequisites != "高校数学の知識を前提とする。" || e.Textbooks != "「線形代数入門」" {
+			t.Errorf("Unexpected entry %+v", e)
+		}
+		if len(e.Schedule) != 3 || e.Schedule[1] != (Session{Number: 2, Theme: "行列", Content: "行列の積と逆行列"}) {
+			t.Errorf("Unexpected schedule %+v", e.Schedule)
+		}
+		if len(e.Evaluation) != 2 || e.Evaluation[0] != (Criterion{Method: "期末試験", Weight: 60}) {
+			t.Errorf("Unexpected evaluation %+v", e.Evaluation)
+		}
+	})
+	t.Run("Use cache", func(t *testing.T) {
+		before := server.requests
+		_, err := client.Entry(ctx, course)
+		check(t, err)
+		if server.requests != before {
+			t.Errorf("Expect: cached entry\nActual: %d requests\n", server.requests-before)
+		}
+		client.TTL = time.Nanosecond
+		_, err = client.Entry(ctx, course)
+		check(t, err)
+		if server.requests != before+1 {
+			t.Errorf("Expect: expired cache is refreshed\nActual: %d requests\n", server.requests-before)
+		}
+	})
+	t.Run("Fail on missing entry", func(t *testing.T) {
+		_, err := client.Entry(ctx, Course{URL: server.URL + "/?c=detail&code=0"})
+		switch err := err.(type) {
+		case *StatusError:
+			if err.StatusCode != http.StatusNotFound {
+				t.Errorf("Expect: 404\nActual: %d\n", err.StatusCode)
+			}
+		default:
+			t.Errorf("Expect: StatusError\nActual: %v\n", err)
+		}
+	})
+}
+
+func TestCourseKey(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		a, b Course
+		same bool
+	}{
+		{Course{Code: "1", URL: "https://example.com/1"}, Course{Code: "2", URL: "https://example.com/1"}, true},
+		{Course{Year: 2018, Code: "1"}, Course{Year: 2018, Code: "2"}, false},
+		{Course{Year: 2018, Code: "1"}, Course{Year: 2019, Code: "1"}, false},
+		{Course{Year: 2018, Code: "1", Title: "a"}, Course{Year: 2018, Code: "1", Title: "b"}, true},
+	}
+	for _, c := range cases {
+		if (courseKey(c.a) == courseKey(c.b)) != c.same {
+			t.Errorf("Expect: same key %v for %+v and %+v\n", c.same, c.a, c.b)
+		}
+	}
+	if key := courseKey(Course{Title: "a"}); key != "" {
+		t.Errorf("Expect: no key without url and code\nActual: %q\n", key)
+	}
+}
@@ -7,8 +7,6 @@ var (
 )
 
 func isValidUsername(username string) error {
-	if !usernameRegex.MatchString(username) {
-		return &InvalidUsernameError{username: username}
-	}
-	return nil
+	_, err := ParseStudentID(username)
+	return err
 }
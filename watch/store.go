@@ -0,0 +1,92 @@
+package watch
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+	bolt "go.etcd.io/bbolt"
+)
+
+var noticeBucket = []byte("notices")
+
+// Store keeps notices which have been seen. The key of the map is Event.ID of the notice.
+type Store interface {
+	Load() (map[string]portal.Notice, error)
+	Save(notices map[string]portal.Notice) error
+	Close() error
+}
+
+// BoltStore is a Store backed by a local bbolt database file.
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// OpenBoltStore opens the database file at given path. The file is created if it does not exist.
+func OpenBoltStore(path string) (*BoltStore, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(noticeBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Load returns all seen notices.
+func (s *BoltStore) Load() (map[string]portal.Notice, error) {
+	notices := make(map[string]portal.Notice)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(noticeBucket).ForEach(func(k, v []byte) error {
+			var n portal.Notice
+			if err := json.Unmarshal(v, &n); err != nil {
+				return err
+			}
+			notices[string(k)] = n
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return notices, nil
+}
+
+// Save replaces seen notices with given ones in a transaction.
+func (s *BoltStore) Save(notices map[string]portal.Notice) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(noticeBucket); err != nil {
+			return err
+		}
+		b, err := tx.CreateBucket(noticeBucket)
+		if err != nil {
+			return err
+		}
+		for id, n := range notices {
+			v, err := json.Marshal(n)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(id), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Close closes the database file.
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+// Path returns the path of the database file.
+func (s *BoltStore) Path() string {
+	return s.db.Path()
+}
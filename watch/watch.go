@@ -0,0 +1,244 @@
+// Package watch tracks the notices on the portal and tells what has been added, changed or removed.
+// Seen notices are kept in a Store, so the watcher does not report the same notice again after restart.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+// DefaultInterval is the interval between checks of Run.
+const DefaultInterval = 10 * time.Minute
+
+// EventType is the kind of the change of a notice.
+type EventType int
+
+const (
+	// Added means a new notice appeared.
+	Added EventType = iota + 1
+	// Changed means the body or links of a seen notice, or the title of one with DetailKey, have been modified.
+	Changed
+	// Removed means a seen notice disappeared from the portal, while newer notices are still shown.
+	Removed
+)
+
+func (t EventType) String() string {
+	switch t {
+	case Added:
+		return "added"
+	case Changed:
+		return "changed"
+	case Removed:
+		return "removed"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+// Event is a change of a notice.
+type Event struct {
+	Type EventType
+	// ID is the stable identifier of the notice; its DetailKey, or Notice.ID without one.
+	ID     string
+	Notice portal.Notice
+	// Previous is the notice seen before. It is nil when Type is Added.
+	Previous *portal.Notice
+}
+
+// Fetcher returns current notices. portal.Client implements this interface.
+type Fetcher interface {
+	Notices(ctx context.Context) ([]portal.Notice, error)
+}
+
+// Watcher compares fetched notices with seen ones.
+type Watcher struct {
+	Fetcher Fetcher
+	Store   Store
+	// Interval is the interval between checks of Run. DefaultInterval is used when it is zero.
+	Interval time.Duration
+	// OnError is called when a check fails during Run. The check is retried at the next interval.
+	OnError func(error)
+}
+
+// New create new watcher with given fetcher and store.
+func New(fetcher Fetcher, store Store) *Watcher {
+	return &Watcher{
+		Fetcher:  fetcher,
+		Store:    store,
+		Interval: DefaultInterval,
+	}
+}
+
+// Check fetches notices once and returns the changes since last check.
+// The notices are saved to the store before returning, so the same change is never returned twice.
+// The portal shows only recent notices, so a seen notice is reported as removed only when it is newer than
+// the oldest fetched one; older notices have scrolled off and are kept in the store.
+func (w *Watcher) Check(ctx context.Context) ([]Event, error) {
+	notices, err := w.Fetcher.Notices(ctx)
+	if err != nil {
+		return nil, err
+	}
+	seen, err := w.Store.Load()
+	if err != nil {
+		return nil, err
+	}
+	seen = rekey(seen)
+	current := identify(notices, seen)
+	events, kept := diff(seen, current, oldest(notices))
+	if len(events) == 0 {
+		return nil, nil
+	}
+	for id, n := range kept {
+		current[id] = n
+	}
+	if err := w.Store.Save(current); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+// Run checks notices periodically and calls handler for each change until ctx is done.
+func (w *Watcher) Run(ctx context.Context, handler func(Event)) error {
+	interval := w.Interval
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		events, err := w.Check(ctx)
+		if err != nil && ctx.Err() == nil && w.OnError != nil {
+			w.OnError(err)
+		}
+		for _, e := range events {
+			handler(e)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// detailPrefix marks the keys of the notices identified by their DetailKey.
+const detailPrefix = "detail:"
+
+// key returns the identifier of the notice. The DetailKey given by the portal is used when the notice has one,
+// so that the notice is matched even after its title is edited. Notice.ID is used otherwise.
+func key(n *portal.Notice) string {
+	if n.DetailKey != "" {
+		return detailPrefix + n.DetailKey
+	}
+	return n.ID()
+}
+
+// rekey identifies the seen notices with key, since the store may have them by Notice.ID,
+// or the duplicates by a sequence number such as "<key>-2".
+func rekey(seen map[string]portal.Notice) map[string]portal.Notice {
+	m := make(map[string]portal.Notice, len(seen))
+	for id, n := range seen {
+		k := key(&n)
+		switch {
+		case strings.HasPrefix(id, k+"-") || strings.HasPrefix(id, n.ID()+"-"):
+			id = duplicateKey(k, &n)
+		case n.DetailKey != "" && !strings.HasPrefix(id, k+"#"):
+			id = k
+		}
+		m[id] = n
+	}
+	return m
+}
+
+// identify maps notices by their key. When the same key appears more than once on a page,
+// the one seen before under the key, or the first one, keeps it, and the fingerprint is appended
+// to the keys of the others, so that they are matched by their content regardless of the order.
+func identify(notices []portal.Notice, seen map[string]portal.Notice) map[string]portal.Notice {
+	groups := make(map[string][]portal.Notice)
+	var keys []string
+	for _, n := range notices {
+		id := key(&n)
+		if _, ok := groups[id]; !ok {
+			keys = append(keys, id)
+		}
+		groups[id] = append(groups[id], n)
+	}
+	m := make(map[string]portal.Notice, len(notices))
+	for _, id := range keys {
+		group := groups[id]
+		first := 0
+		if prev, ok := seen[id]; ok {
+			for i := range group {
+				if group[i].Fingerprint() == prev.Fingerprint() {
+					first = i
+					break
+				}
+			}
+		}
+		for i, n := range group {
+			if i == first {
+				m[id] = n
+			} else {
+				m[duplicateKey(id, &n)] = n
+			}
+		}
+	}
+	return m
+}
+
+// duplicateKey returns the key of a notice sharing id with another one on the page.
+func duplicateKey(id string, n *portal.Notice) string {
+	return id + "#" + n.Fingerprint()
+}
+
+// oldest returns the oldest posting date of the notices. It is zero without notices.
+func oldest(notices []portal.Notice) time.Time {
+	var t time.Time
+	for _, n := range notices {
+		if t.IsZero() || n.Date.Before(t) {
+			t = n.Date
+		}
+	}
+	return t
+}
+
+// diff compares the notices. Seen notices missing from current are removed when they are newer than since,
+// and the others, which have scrolled off the portal, are returned as kept.
+func diff(seen, current map[string]portal.Notice, since time.Time) ([]Event, map[string]portal.Notice) {
+	var events []Event
+	for id, n := range current {
+		prev, ok := seen[id]
+		switch {
+		case !ok:
+			events = append(events, Event{Type: Added, ID: id, Notice: n})
+		case prev.Fingerprint() != n.Fingerprint():
+			p := prev
+			events = append(events, Event{Type: Changed, ID: id, Notice: n, Previous: &p})
+		}
+	}
+	kept := make(map[string]portal.Notice)
+	for id, prev := range seen {
+		if _, ok := current[id]; ok {
+			continue
+		}
+		p := prev
+		if since.IsZero() || !p.Date.After(since) {
+			kept[id] = p
+			continue
+		}
+		events = append(events, Event{Type: Removed, ID: id, Notice: p, Previous: &p})
+	}
+	// Keep the order stable; older notices first.
+	sort.Slice(events, func(i, j int) bool {
+		a, b := events[i].Notice, events[j].Notice
+		if !a.Date.Equal(b.Date) {
+			return a.Date.Before(b.Date)
+		}
+		return events[i].ID < events[j].ID
+	})
+	return events, kept
+}
@@ -0,0 +1,162 @@
+package watch
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/StudioAquatan/kitwalk/portal"
+)
+
+type fetcherMock struct {
+	notices []portal.Notice
+}
+
+func (f *fetcherMock) Notices(ctx context.Context) ([]portal.Notice, error) {
+	return f.notices, nil
+}
+
+func check(t *testing.T, err error) {
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func notice(title, body string) portal.Notice {
+	return portal.Notice{
+		Date:     time.Date(2018, time.February, 9, 0, 0, 0, 0, portal.JST),
+		Office:   "学務課",
+		Category: "休講",
+		Title:    title,
+		Body:     body,
+	}
+}
+
+// older returns the notice posted given days before the others.
+func older(n portal.Notice, days int) portal.Notice {
+	n.Date = n.Date.AddDate(0, 0, -days)
+	return n
+}
+
+func TestWatcher_Check(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "kitwalk")
+	check(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "notices.db")
+	store, err := OpenBoltStore(path)
+	check(t, err)
+	old := older(notice("Old", "body"), 7)
+	fetcher := &fetcherMock{notices: []portal.Notice{notice("A", "body"), notice("B", "body"), old}}
+	w := New(fetcher, store)
+
+	events, err := w.Check(context.Background())
+	check(t, err)
+	if len(events) != 3 || events[0].Type != Added || events[1].Type != Added || events[2].Type != Added {
+		t.Errorf("Expect: 3 added events\nActual: %+v\n", events)
+	}
+	events, err = w.Check(context.Background())
+	check(t, err)
+	if len(events) != 0 {
+		t.Errorf("Expect: no events\nActual: %+v\n", events)
+	}
+
+	t.Run("Survive restart", func(t *testing.T) {
+		check(t, store.Close())
+		store, err = OpenBoltStore(path)
+		check(t, err)
+		w.Store = store
+		fetcher.notices = []portal.Notice{notice("A", "modified"), notice("C", "body"), old}
+		events, err = w.Check(context.Background())
+		check(t, err)
+		types := map[string]EventType{}
+		for _, e := range events {
+			types[e.Notice.Title] = e.Type
+		}
+		if len(events) != 3 || types["A"] != Changed || types["B"] != Removed || types["C"] != Added {
+			t.Errorf("Unexpected events %+v", events)
+		}
+	})
+	t.Run("Keep notices scrolled off", func(t *testing.T) {
+		// Old and the older notices on the same day as the oldest shown may be on the next page.
+		fetcher.notices = []portal.Notice{notice("A", "modified"), notice("C", "body")}
+		events, err = w.Check(context.Background())
+		check(t, err)
+		if len(events) != 0 {
+			t.Errorf("Expect: no events\nActual: %+v\n", events)
+		}
+		fetcher.notices = []portal.Notice{older(notice("D", "body"), -1), notice("A", "modified"), notice("C", "body"), old}
+		events, err = w.Check(context.Background())
+		check(t, err)
+		if len(events) != 1 || events[0].Type != Added || events[0].Notice.Title != "D" {
+			t.Errorf("Expect: only D added\nActual: %+v\n", events)
+		}
+		check(t, store.Close())
+	})
+}
+
+func TestWatcher_CheckEdited(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "kitwalk")
+	check(t, err)
+	defer os.RemoveAll(dir)
+	store, err := OpenBoltStore(filepath.Join(dir, "notices.db"))
+	check(t, err)
+	defer store.Close()
+	detail := notice("E", "body")
+	detail.DetailKey = "1001"
+	fetcher := &fetcherMock{notices: []portal.Notice{detail}}
+	w := New(fetcher, store)
+	_, err = w.Check(context.Background())
+	check(t, err)
+
+	edited := detail
+	edited.Title, edited.Body = "E (訂正)", "new body"
+	fetcher.notices = []portal.Notice{edited}
+	events, err := w.Check(context.Background())
+	check(t, err)
+	if len(events) != 1 || events[0].Type != Changed || events[0].Previous.Title != "E" || events[0].Notice.Title != "E (訂正)" {
+		t.Fatalf("Expect: E changed\nActual: %+v\n", events)
+	}
+	events, err = w.Check(context.Background())
+	check(t, err)
+	if len(events) != 0 {
+		t.Errorf("Expect: no events\nActual: %+v\n", events)
+	}
+}
+
+func TestWatcher_CheckDuplicates(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "kitwalk")
+	check(t, err)
+	defer os.RemoveAll(dir)
+	store, err := OpenBoltStore(filepath.Join(dir, "notices.db"))
+	check(t, err)
+	defer store.Close()
+	first, second := notice("D", "first"), notice("D", "second")
+	fetcher := &fetcherMock{notices: []portal.Notice{first, second}}
+	w := New(fetcher, store)
+	events, err := w.Check(context.Background())
+	check(t, err)
+	if len(events) != 2 {
+		t.Fatalf("Expect: 2 added events\nActual: %+v\n", events)
+	}
+
+	fetcher.notices = []portal.Notice{second, first}
+	events, err = w.Check(context.Background())
+	check(t, err)
+	if len(events) != 0 {
+		t.Errorf("Expect: no events when the order changes\nActual: %+v\n", events)
+	}
+
+	t.Run("Migrate sequence numbers", func(t *testing.T) {
+		seen := rekey(map[string]portal.Notice{first.ID(): first, first.ID() + "-2": second})
+		current := identify([]portal.Notice{second, first}, seen)
+		if events, _ := diff(seen, current, time.Time{}); len(events) != 0 {
+			t.Errorf("Expect: no events\nActual: %+v\n", events)
+		}
+	})
+}